@@ -1,6 +1,7 @@
 package appctx
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -33,7 +34,8 @@ type AppContext interface {
 
 type Component interface {
 	ID() string
-	InitFlags()
+	// InitFlags defines the flags of the component on the flag set of the context
+	InitFlags(fs *pflag.FlagSet)
 	Run(AppContext) error
 	Stop() error
 }
@@ -45,12 +47,16 @@ type appContext struct {
 	store      map[string]Component
 	components []Component
 	cmd        *appFlagSet
+	flagSet    *pflag.FlagSet
+	viper      *viper.Viper
+	appLogger  AppLogger
 	logger     Logger
 }
 
 func NewAppContext(opts ...Option) AppContext {
 	app := &appContext{
-		store: make(map[string]Component),
+		store:     make(map[string]Component),
+		appLogger: defaultLogger,
 	}
 
 	app.components = []Component{defaultLogger}
@@ -59,11 +65,13 @@ func NewAppContext(opts ...Option) AppContext {
 		opt(app)
 	}
 
+	app.flagSet = pflag.NewFlagSet(app.name, pflag.ExitOnError)
+	app.viper = viper.New()
 	app.initFlags()
-	app.cmd = newAppFlagSet(app.prefix, app.name, pflag.CommandLine)
+	app.cmd = newAppFlagSet(app.prefix, app.name, app.flagSet, app.viper)
 	app.parseFlags()
 
-	app.logger = defaultLogger.GetLogger(formatLogPrefix(app.prefix, app.name))
+	app.logger = app.appLogger.GetLogger(formatLogPrefix(app.prefix, app.name))
 
 	return app
 }
@@ -80,8 +88,10 @@ func formatLogPrefix(prefix, name string) string {
 	return prefix
 }
 
+// initFlags defines the flags on the flag set of the context,
+// so several contexts can be created concurrently (ex: in tests)
 func (ac *appContext) initFlags() {
-	pflag.StringVar(
+	ac.flagSet.StringVar(
 		&ac.env,
 		"app-env",
 		devEnv,
@@ -89,19 +99,19 @@ func (ac *appContext) initFlags() {
 	)
 
 	for _, c := range ac.components {
-		c.InitFlags()
+		c.InitFlags(ac.flagSet)
 	}
 }
 
 func (ac *appContext) parseFlags() {
 	// Parse all flags have been defined
-	err := ac.cmd.flagSet.Parse(viper.AllKeys())
+	err := ac.cmd.flagSet.Parse(ac.viper.AllKeys())
 	if err != nil {
 		log.Fatal().Msg("Cannot parse command flags")
 	}
 
 	// Bind the command flags to the configuration variables
-	if err := viper.BindPFlags(ac.cmd.flagSet); err != nil {
+	if err := ac.viper.BindPFlags(ac.cmd.flagSet); err != nil {
 		log.Fatal().Err(err).Msg("Error binding flags")
 	}
 
@@ -112,12 +122,12 @@ func (ac *appContext) parseFlags() {
 
 	_, err = os.Stat(envFile)
 	if err == nil {
-		viper.SetConfigFile(envFile)
+		ac.viper.SetConfigFile(envFile)
 
 		// Automatically overrides values with the value of corresponding environment variable if they exist
-		viper.AutomaticEnv()
+		ac.viper.AutomaticEnv()
 
-		if err := viper.ReadInConfig(); err != nil {
+		if err := ac.viper.ReadInConfig(); err != nil {
 			log.Fatal().Err(err).Msgf("Load env(%s) failed", envFile)
 		}
 	} else if envFile != defaultEnvFile {
@@ -172,20 +182,28 @@ func (ac *appContext) Load() error {
 	return nil
 }
 
+// Stop stops the components in reverse order of registration, so dependents stop before their dependencies.
+// All the components are stopped even if some fail
 func (ac *appContext) Stop() error {
-	for i := range ac.components {
+	var errs []error
+	for i := len(ac.components) - 1; i >= 0; i-- {
 		if err := ac.components[i].Stop(); err != nil {
-			return err
+			ac.logger.Errorf(err, "Cannot stop component %s", ac.components[i].ID())
+			errs = append(errs, err)
 		}
 	}
 
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
 	ac.logger.Info("Service context stopped")
 
 	return nil
 }
 
 func (ac *appContext) Logger(prefix string) Logger {
-	return defaultLogger.GetLogger(prefix)
+	return ac.appLogger.GetLogger(prefix)
 }
 
 func (ac *appContext) OutEnv() {
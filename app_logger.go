@@ -28,16 +28,29 @@ type AppLogger interface {
 	GetLogger(prefix string) Logger
 }
 
+// SinkAppLogger is an AppLogger accepting sinks, ex: the AppLoggers of this package
+type SinkAppLogger interface {
+	AppLogger
+
+	// AddSink installs a writer receiving every (redacted) entry as a JSON line.
+	// The returned func removes the sink.
+	AddSink(sink io.Writer) (remove func())
+}
+
 func GlobalLogger() AppLogger {
 	return defaultLogger
 }
 
 type appLogger struct {
-	config   loggerConfig
-	logger   *zerolog.Logger
-	logLevel string
-	logType  string
-	logPath  string
+	config         loggerConfig
+	logger         *zerolog.Logger
+	logLevel       string
+	logType        string
+	logPath        string
+	redact         bool
+	redactKeys     []string
+	redactPatterns []string
+	sinks          *sinkWriter
 }
 
 type loggerConfig struct {
@@ -45,6 +58,25 @@ type loggerConfig struct {
 	defaultLevel string
 	defaultType  string
 	defaultPath  string
+	output       io.Writer
+	isolated     bool
+}
+
+// NewAppLogger creates an AppLogger independent of the global one,
+// writing JSON entries to output (discarded if nil).
+// It does not touch global zerolog settings, so it is safe to use in parallel tests.
+func NewAppLogger(basePrefix string, output io.Writer) AppLogger {
+	if output == nil {
+		output = io.Discard
+	}
+
+	return newAppLogger(&loggerConfig{
+		basePrefix:   basePrefix,
+		defaultLevel: "trace",
+		defaultType:  defaultType,
+		output:       output,
+		isolated:     true,
+	})
 }
 
 func newAppLogger(config *loggerConfig) *appLogger {
@@ -61,16 +93,29 @@ func newAppLogger(config *loggerConfig) *appLogger {
 	}
 
 	level := parseLogLevel(config.defaultLevel)
-	zerolog.SetGlobalLevel(level)
+	if !config.isolated {
+		zerolog.SetGlobalLevel(level)
+	}
 
-	logger := getNewLogger(prdEnv, config.defaultType, config.defaultPath)
-	return &appLogger{
-		config:   *config,
-		logger:   logger,
-		logLevel: config.defaultLevel,
-		logType:  config.defaultType,
-		logPath:  config.defaultPath,
+	l := &appLogger{
+		config:     *config,
+		logLevel:   config.defaultLevel,
+		logType:    config.defaultType,
+		logPath:    config.defaultPath,
+		redact:     true,
+		redactKeys: defaultRedactKeys,
+		sinks:      newSinkWriter(),
 	}
+
+	output := config.output
+	if output == nil {
+		output = getLogWriter(prdEnv, config.defaultType, config.defaultPath)
+	}
+
+	redactor, _ := newRedactor(defaultRedactKeys, nil)
+	l.logger = l.newLogger(output, redactor, level)
+
+	return l
 }
 
 func parseLogLevel(input string) zerolog.Level {
@@ -104,7 +149,7 @@ func getOutputFormat(env string, writer *os.File) io.Writer {
 	return output
 }
 
-func getNewLogger(env, logType, logPath string) *zerolog.Logger {
+func getLogWriter(env, logType, logPath string) io.Writer {
 	var writer *os.File
 
 	switch logType {
@@ -126,8 +171,22 @@ func getNewLogger(env, logType, logPath string) *zerolog.Logger {
 		writer = os.Stdout
 	}
 
-	output := getOutputFormat(env, writer)
-	logger := zerolog.New(output).With().Timestamp().Logger()
+	return getOutputFormat(env, writer)
+}
+
+func (l *appLogger) newLogger(output io.Writer, redactor *redactor, level zerolog.Level) *zerolog.Logger {
+	l.sinks.setPrimary(output)
+
+	var writer io.Writer = l.sinks
+	if redactor != nil {
+		writer = &redactWriter{redactor: redactor, out: writer}
+	}
+
+	logger := zerolog.New(writer).With().Timestamp().Logger()
+	if l.config.isolated {
+		logger = logger.Level(level)
+	}
+
 	return &logger
 }
 
@@ -148,42 +207,90 @@ func (l *appLogger) GetLogger(prefix string) Logger {
 	}
 }
 
+func (l *appLogger) AddSink(sink io.Writer) func() {
+	return l.sinks.add(sink)
+}
+
 func (l *appLogger) ID() string {
 	return "logger"
 }
 
-func (l *appLogger) InitFlags() {
-	pflag.StringVar(
+func (l *appLogger) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(
 		&l.logLevel,
 		"log-level",
 		l.config.defaultLevel,
 		"Log level (panic | fatal | error | warn | info | debug | trace) - Default: trace",
 	)
 
-	pflag.StringVar(
+	fs.StringVar(
 		&l.logType,
 		"log-type",
 		l.config.defaultType,
 		"Log type (stdout | stderr | file) - Default: stdout",
 	)
 
-	pflag.StringVar(
+	fs.StringVar(
 		&l.logPath,
 		"log-path",
 		l.config.defaultPath,
 		"Log path (require if log type is file) - Ex: \"./app.log\"",
 	)
+
+	fs.BoolVar(
+		&l.redact,
+		"log-redact",
+		true,
+		"Log redact sensitive fields (card, password...) and patterns (emails, grouped card numbers) - Default: true",
+	)
+
+	fs.StringSliceVar(
+		&l.redactKeys,
+		"log-redact-keys",
+		defaultRedactKeys,
+		fmt.Sprintf("Log redact keys, matched case-insensitively as substrings - Default: %s", strings.Join(defaultRedactKeys, ",")),
+	)
+
+	fs.StringArrayVar(
+		&l.redactPatterns,
+		"log-redact-patterns",
+		nil,
+		"Log redact additional regular expressions",
+	)
 }
 
 func (l *appLogger) Run(ac AppContext) error {
 	level := parseLogLevel(l.logLevel)
-	zerolog.SetGlobalLevel(level)
+	if !l.config.isolated {
+		zerolog.SetGlobalLevel(level)
+	}
 
-	if level <= zerolog.DebugLevel {
+	// The global settings are left untouched by the isolated loggers
+	if !l.config.isolated && level <= zerolog.DebugLevel {
 		zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
 	}
 
-	l.logger = getNewLogger(ac.GetEnvName(), l.logType, l.logPath)
+	var redactor *redactor
+	if l.redact {
+		var err error
+		redactor, err = newRedactor(l.redactKeys, l.redactPatterns)
+		if err != nil {
+			return err
+		}
+	}
+
+	output := l.config.output
+	if output == nil {
+		output = getLogWriter(ac.GetEnvName(), l.logType, l.logPath)
+	}
+
+	l.logger = l.newLogger(output, redactor, level)
+
+	if !l.config.isolated {
+		// Route the global zerolog logger (used by request loggers) through the same output
+		log.Logger = *l.logger
+	}
+
 	return nil
 }
 
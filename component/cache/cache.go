@@ -5,8 +5,40 @@ import (
 	"time"
 )
 
+// Cache stores encoded copies of the values.
+// A ttl <= 0 uses the default TTL of the cache, the entry never expires if there is none
 type Cache interface {
 	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
 	Get(ctx context.Context, key string, value interface{}) error
 	Delete(ctx context.Context, key string) error
+
+	// MGet decodes the found values into the pointers of values, keyed by cache key, and returns the missing keys
+	MGet(ctx context.Context, values map[string]interface{}) (missing []string, err error)
+	MSet(ctx context.Context, values map[string]interface{}, ttl time.Duration) error
+	MDelete(ctx context.Context, keys ...string) error
+
+	Exists(ctx context.Context, key string) (bool, error)
+	// Expire updates the TTL of the key, ttl <= 0 removes the expiration
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	// TTL returns the remaining time to live of the key, 0 is never expired
+	TTL(ctx context.Context, key string) (time.Duration, error)
+
+	// Incr adds delta to the counter, ttl is applied when the counter is created.
+	// Read the counter with Incr(ctx, key, 0, 0)
+	Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+	Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+
+	DeleteByPrefix(ctx context.Context, prefix string) error
+	// SetWithTags stores the value and tags it, to be invalidated by InvalidateTags
+	SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error
+	// InvalidateTags deletes all keys tagged with any of tags
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+type Stats struct {
+	Size        int
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
 }
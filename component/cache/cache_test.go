@@ -0,0 +1,314 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisCache(t *testing.T, server *miniredis.Miniredis) *redisCache {
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+
+	rdc := NewRedisCache("cache", "", "redis")
+	rdc.setup(client)
+	require.NoError(t, rdc.subscribeInvalidation())
+
+	t.Cleanup(func() {
+		require.NoError(t, rdc.Stop())
+		_ = client.Close()
+	})
+
+	return rdc
+}
+
+func testCaches(t *testing.T) map[string]Cache {
+	rdc := newTestRedisCache(t, miniredis.RunT(t))
+
+	return map[string]Cache{
+		"local": newTestLocalCache(t, 0, evictionLRU),
+		"redis": rdc,
+	}
+}
+
+func TestCacheBatch(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range testCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, c.MSet(ctx, map[string]interface{}{
+				"user:1": user{Name: "alice"},
+				"user:2": user{Name: "bob"},
+			}, time.Minute))
+
+			var u1, u2, u3 user
+			missing, err := c.MGet(ctx, map[string]interface{}{
+				"user:1": &u1,
+				"user:2": &u2,
+				"user:3": &u3,
+			})
+			require.NoError(t, err)
+			require.Equal(t, []string{"user:3"}, missing)
+			require.Equal(t, "alice", u1.Name)
+			require.Equal(t, "bob", u2.Name)
+
+			ok, err := c.Exists(ctx, "user:1")
+			require.NoError(t, err)
+			require.True(t, ok)
+
+			require.NoError(t, c.MDelete(ctx, "user:1", "user:2"))
+
+			ok, err = c.Exists(ctx, "user:1")
+			require.NoError(t, err)
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestCacheExpire(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range testCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, c.Set(ctx, "key", "value", time.Hour))
+			require.NoError(t, c.Expire(ctx, "key", 0))
+
+			ttl, err := c.TTL(ctx, "key")
+			require.NoError(t, err)
+			require.Zero(t, ttl)
+
+			require.NoError(t, c.Expire(ctx, "key", time.Minute))
+
+			ttl, err = c.TTL(ctx, "key")
+			require.NoError(t, err)
+			require.InDelta(t, time.Minute, ttl, float64(time.Second))
+
+			require.ErrorIs(t, c.Expire(ctx, "missing", time.Minute), ErrNotFound)
+			_, err = c.TTL(ctx, "missing")
+			require.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range testCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			// Same TTL for every write, without default TTL 0 is never expired
+			writes := map[string]func(key string, ttl time.Duration) error{
+				"set": func(key string, ttl time.Duration) error {
+					return c.Set(ctx, key, "value", ttl)
+				},
+				"mset": func(key string, ttl time.Duration) error {
+					return c.MSet(ctx, map[string]interface{}{key: "value"}, ttl)
+				},
+				"tags": func(key string, ttl time.Duration) error {
+					return c.SetWithTags(ctx, key, "value", ttl, "tag")
+				},
+				"incr": func(key string, ttl time.Duration) error {
+					_, err := c.Incr(ctx, key, 1, ttl)
+					return err
+				},
+			}
+
+			for write, fn := range writes {
+				require.NoError(t, fn(write+":none", 0), write)
+				ttl, err := c.TTL(ctx, write+":none")
+				require.NoError(t, err)
+				require.Zero(t, ttl, write)
+
+				require.NoError(t, fn(write+":short", time.Millisecond*500), write)
+				ttl, err = c.TTL(ctx, write+":short")
+				require.NoError(t, err)
+				require.InDelta(t, time.Millisecond*500, ttl, float64(time.Millisecond*100), write)
+			}
+		})
+	}
+}
+
+func TestRedisCacheTagTTL(t *testing.T) {
+	ctx := context.Background()
+	rdc := newTestRedisCache(t, miniredis.RunT(t))
+
+	// The tag set lives as long as its longest lived key
+	require.NoError(t, rdc.SetWithTags(ctx, "key1", "value", time.Hour, "tag"))
+	require.NoError(t, rdc.SetWithTags(ctx, "key2", "value", time.Minute, "tag"))
+
+	ttl, err := rdc.client.PTTL(ctx, rdc.tagKey("tag")).Result()
+	require.NoError(t, err)
+	require.InDelta(t, time.Hour, ttl, float64(time.Second))
+
+	require.NoError(t, rdc.SetWithTags(ctx, "key3", "value", 0, "tag"))
+
+	ttl, err = rdc.client.PTTL(ctx, rdc.tagKey("tag")).Result()
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(-1), ttl)
+}
+
+func TestCacheIncr(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range testCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			counter, err := c.Incr(ctx, "counter", 5, time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, int64(5), counter)
+
+			counter, err = c.Decr(ctx, "counter", 2, 0)
+			require.NoError(t, err)
+			require.Equal(t, int64(3), counter)
+
+			ttl, err := c.TTL(ctx, "counter")
+			require.NoError(t, err)
+			require.Greater(t, ttl, time.Duration(0))
+		})
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range testCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, c.SetWithTags(ctx, "user:42:profile", "profile", time.Minute, "user:42"))
+			require.NoError(t, c.SetWithTags(ctx, "user:42:orders", "orders", time.Minute, "user:42", "orders"))
+			require.NoError(t, c.SetWithTags(ctx, "user:7:orders", "orders", time.Minute, "orders"))
+			require.NoError(t, c.Set(ctx, "session:1", "s1", time.Minute))
+			require.NoError(t, c.Set(ctx, "session:2", "s2", time.Minute))
+
+			require.NoError(t, c.InvalidateTags(ctx, "user:42"))
+
+			for key, expected := range map[string]bool{
+				"user:42:profile": false,
+				"user:42:orders":  false,
+				"user:7:orders":   true,
+			} {
+				ok, err := c.Exists(ctx, key)
+				require.NoError(t, err)
+				require.Equal(t, expected, ok, key)
+			}
+
+			require.NoError(t, c.DeleteByPrefix(ctx, "session:"))
+
+			ok, err := c.Exists(ctx, "session:2")
+			require.NoError(t, err)
+			require.False(t, ok)
+
+			ok, err = c.Exists(ctx, "user:7:orders")
+			require.NoError(t, err)
+			require.True(t, ok)
+		})
+	}
+}
+
+func TestRedisCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	instance1 := newTestRedisCache(t, server)
+	instance2 := newTestRedisCache(t, server)
+
+	require.NoError(t, instance1.Set(ctx, "key", "v1", time.Minute))
+
+	// Fill the local tier of the second instance
+	var value string
+	require.NoError(t, instance2.Get(ctx, "key", &value))
+	require.Equal(t, "v1", value)
+
+	require.NoError(t, instance1.Set(ctx, "key", "v2", time.Minute))
+	require.Eventually(t, func() bool {
+		return instance2.Get(ctx, "key", &value) == nil && value == "v2"
+	}, time.Second, time.Millisecond*10)
+
+	require.NoError(t, instance1.Delete(ctx, "key"))
+	require.Eventually(t, func() bool {
+		return errors.Is(instance2.Get(ctx, "key", &value), ErrNotFound)
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestRedisCacheStopAfterClient(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+
+	rdc := NewRedisCache("cache", "", "redis")
+	rdc.setup(client)
+	require.NoError(t, rdc.subscribeInvalidation())
+
+	// The Redis component closed the client first
+	require.NoError(t, client.Close())
+	require.NoError(t, rdc.Stop())
+}
+
+func TestRedisCacheSubscribeFailed(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	rdc := NewRedisCache("cache", "", "redis")
+	rdc.setup(client)
+
+	server.Close()
+	require.Error(t, rdc.subscribeInvalidation())
+
+	// Does not wait for a listener that was never started
+	done := make(chan error, 1)
+	go func() {
+		done <- rdc.Stop()
+	}()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked after a failed subscription")
+	}
+}
+
+func TestCacheKeyspace(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	newCache := func(prefix string, version int) *redisCache {
+		rdc := newTestRedisCache(t, server)
+		rdc.keyPrefix = prefix
+		rdc.keyVersion = version
+		rdc.setNamespace()
+
+		return rdc
+	}
+
+	orders := newCache("orders", 1)
+	users := newCache("users", 1)
+
+	require.NoError(t, orders.Set(ctx, "item:1", "order", time.Minute))
+	require.NoError(t, users.Set(ctx, "item:1", "user", time.Minute))
+	require.True(t, server.Exists("orders:v1:item:1"))
+	require.True(t, server.Exists("users:v1:item:1"))
+
+	var value string
+	require.NoError(t, orders.Get(ctx, "item:1", &value))
+	require.Equal(t, "order", value)
+
+	// Bumping the version hides the entries of the previous schema
+	ordersV2 := newCache("orders", 2)
+	require.ErrorIs(t, ordersV2.Get(ctx, "item:1", &value), ErrNotFound)
+
+	require.NoError(t, users.DeleteByPrefix(ctx, "item:"))
+	require.False(t, server.Exists("users:v1:item:1"))
+	require.True(t, server.Exists("orders:v1:item:1"))
+
+	local := newTestLocalCache(t, 0, evictionLRU)
+	local.keyPrefix = "orders"
+	local.keyVersion = 1
+	local.setNamespace()
+
+	require.NoError(t, local.Set(ctx, "item:1", "order", time.Minute))
+	require.Contains(t, local.store, "orders:v1:item:1")
+	require.NoError(t, local.Get(ctx, "item:1", &value))
+	require.Equal(t, "order", value)
+}
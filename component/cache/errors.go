@@ -0,0 +1,9 @@
+package cache
+
+import "errors"
+
+var (
+	ErrNotFound                   = errors.New("cache: key not found")
+	ErrEvictionPolicyNotSupported = errors.New("cache eviction policy not supported")
+	ErrCodecNotSupported          = errors.New("cache codec not supported")
+)
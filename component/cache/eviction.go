@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+const (
+	evictionLRU = "lru"
+	evictionLFU = "lfu"
+)
+
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt int64 // Unix nano, 0 is never expired
+	tags      []string
+
+	// Eviction bookkeeping
+	element   *list.Element
+	frequency int
+	accessSeq uint64
+	index     int
+}
+
+func (e *entry) isExpired(now int64) bool {
+	return e.expiresAt > 0 && now >= e.expiresAt
+}
+
+// evictor picks the entry to evict when the cache is full
+type evictor interface {
+	add(e *entry)
+	access(e *entry)
+	remove(e *entry)
+	victim() *entry
+}
+
+func newEvictor(policy string) (evictor, error) {
+	switch policy {
+	case evictionLRU:
+		return &lruEvictor{list: list.New()}, nil
+	case evictionLFU:
+		return new(lfuEvictor), nil
+	}
+
+	return nil, ErrEvictionPolicyNotSupported
+}
+
+// Least recently used, the front of the list is the most recently used
+type lruEvictor struct {
+	list *list.List
+}
+
+func (l *lruEvictor) add(e *entry) {
+	e.element = l.list.PushFront(e)
+}
+
+func (l *lruEvictor) access(e *entry) {
+	l.list.MoveToFront(e.element)
+}
+
+func (l *lruEvictor) remove(e *entry) {
+	l.list.Remove(e.element)
+	e.element = nil
+}
+
+func (l *lruEvictor) victim() *entry {
+	back := l.list.Back()
+	if back == nil {
+		return nil
+	}
+
+	return back.Value.(*entry)
+}
+
+// Least frequently used, ties are broken by the least recently used
+type lfuEvictor struct {
+	entries []*entry
+	seq     uint64
+}
+
+func (l *lfuEvictor) add(e *entry) {
+	l.seq++
+	e.frequency = 1
+	e.accessSeq = l.seq
+	heap.Push(l, e)
+}
+
+func (l *lfuEvictor) access(e *entry) {
+	l.seq++
+	e.frequency++
+	e.accessSeq = l.seq
+	heap.Fix(l, e.index)
+}
+
+func (l *lfuEvictor) remove(e *entry) {
+	heap.Remove(l, e.index)
+}
+
+func (l *lfuEvictor) victim() *entry {
+	if len(l.entries) == 0 {
+		return nil
+	}
+
+	return l.entries[0]
+}
+
+// heap.Interface
+func (l *lfuEvictor) Len() int {
+	return len(l.entries)
+}
+
+func (l *lfuEvictor) Less(i, j int) bool {
+	if l.entries[i].frequency == l.entries[j].frequency {
+		return l.entries[i].accessSeq < l.entries[j].accessSeq
+	}
+
+	return l.entries[i].frequency < l.entries[j].frequency
+}
+
+func (l *lfuEvictor) Swap(i, j int) {
+	l.entries[i], l.entries[j] = l.entries[j], l.entries[i]
+	l.entries[i].index = i
+	l.entries[j].index = j
+}
+
+func (l *lfuEvictor) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(l.entries)
+	l.entries = append(l.entries, e)
+}
+
+func (l *lfuEvictor) Pop() interface{} {
+	n := len(l.entries)
+	e := l.entries[n-1]
+	l.entries[n-1] = nil
+	l.entries = l.entries[:n-1]
+	e.index = -1
+
+	return e
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"fmt"
+
+	appctx "github.com/hoangtk0100/app-context"
+)
+
+const (
+	defaultKeyVersion = 1
+	keySeparator      = ":"
+)
+
+// keyspace namespaces the keys as <prefix>:v<version>:<key>,
+// bump the version to invalidate all entries after a value struct change
+type keyspace struct {
+	keyPrefix  string
+	keyVersion int
+	namespace  string
+}
+
+func (ks *keyspace) initKeyspace(ac appctx.AppContext) {
+	if ks.keyPrefix == "" {
+		ks.keyPrefix = ac.GetPrefix()
+	}
+
+	if ks.keyPrefix == "" {
+		ks.keyPrefix = ac.GetName()
+	}
+
+	ks.setNamespace()
+}
+
+func (ks *keyspace) setNamespace() {
+	ks.namespace = ""
+	if ks.keyPrefix != "" {
+		ks.namespace = ks.keyPrefix + keySeparator
+	}
+
+	if ks.keyVersion > 0 {
+		ks.namespace += fmt.Sprintf("v%d%s", ks.keyVersion, keySeparator)
+	}
+}
+
+func (ks *keyspace) key(key string) string {
+	return ks.namespace + key
+}
+
+func (ks *keyspace) keys(keys []string) []string {
+	result := make([]string, len(keys))
+	for i, key := range keys {
+		result[i] = ks.key(key)
+	}
+
+	return result
+}
@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hoangtk0100/app-context/core"
+	"golang.org/x/sync/singleflight"
+)
+
+// Namespace of the negative results, so Get of the key never sees them
+const negativeKeyPrefix = "loader:negative:"
+
+// LoaderFunc loads the value on cache miss, return ErrNotFound to cache a negative result
+type LoaderFunc = core.LoaderFunc
+
+type LoadOption = core.LoadOption
+
+// WithNegativeTTL caches the ErrNotFound of the loader for ttl
+func WithNegativeTTL(ttl time.Duration) LoadOption {
+	return func(opt *core.LoadOptions) {
+		opt.NegativeTTL = ttl
+	}
+}
+
+// WithStaleTTL keeps serving the expired value for ttl while it is reloaded in background
+func WithStaleTTL(ttl time.Duration) LoadOption {
+	return func(opt *core.LoadOptions) {
+		opt.StaleTTL = ttl
+	}
+}
+
+// LoadingCache is a cache-aside cache, implemented by the local and redis caches
+type LoadingCache interface {
+	Cache
+	GetOrLoad(ctx context.Context, key string, dst interface{}, ttl time.Duration, loader LoaderFunc, opts ...LoadOption) error
+}
+
+// cacheLoader collapses concurrent misses of the same key into one loader call.
+// The loaded value is stored as is, so Get and Set of the key share it.
+// With a stale TTL it is kept for ttl + stale TTL, it is stale once its remaining TTL is under the stale TTL
+type cacheLoader struct {
+	cache Cache
+	codec func() Codec
+	group singleflight.Group
+}
+
+func newCacheLoader(cache Cache, codec func() Codec) *cacheLoader {
+	return &cacheLoader{
+		cache: cache,
+		codec: codec,
+	}
+}
+
+func (l *cacheLoader) getOrLoad(ctx context.Context, key string, dst interface{}, ttl time.Duration, loader LoaderFunc, opts ...LoadOption) error {
+	opt := new(core.LoadOptions)
+	for _, o := range opts {
+		o(opt)
+	}
+
+	if ttl <= 0 {
+		opt.StaleTTL = 0
+	}
+
+	err := l.cache.Get(ctx, key, dst)
+	if err == nil {
+		if l.isStale(ctx, key, opt) {
+			// Stale-while-revalidate
+			l.group.DoChan(key, func() (interface{}, error) {
+				return l.load(context.WithoutCancel(ctx), key, ttl, loader, opt)
+			})
+		}
+
+		return nil
+	}
+
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if opt.NegativeTTL > 0 {
+		negative, err := l.cache.Exists(ctx, negativeKeyPrefix+key)
+		if err != nil {
+			return err
+		}
+
+		if negative {
+			return ErrNotFound
+		}
+	}
+
+	data, err, _ := l.group.Do(key, func() (interface{}, error) {
+		return l.load(ctx, key, ttl, loader, opt)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Each caller decodes its own copy of the shared result
+	return l.codec().Unmarshal(data.([]byte), dst)
+}
+
+func (l *cacheLoader) isStale(ctx context.Context, key string, opt *core.LoadOptions) bool {
+	if opt.StaleTTL <= 0 {
+		return false
+	}
+
+	remaining, err := l.cache.TTL(ctx, key)
+
+	return err == nil && remaining > 0 && remaining <= opt.StaleTTL
+}
+
+func (l *cacheLoader) load(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc, opt *core.LoadOptions) ([]byte, error) {
+	value, err := loader(ctx)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) && opt.NegativeTTL > 0 {
+			if err := l.cache.Set(ctx, negativeKeyPrefix+key, true, opt.NegativeTTL); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, err
+	}
+
+	data, err := l.codec().Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := l.cache.Set(ctx, key, value, ttl+opt.StaleTTL); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ LoadingCache        = (*localCache)(nil)
+	_ LoadingCache        = (*redisCache)(nil)
+	_ core.CacheComponent = (*localCache)(nil)
+	_ core.CacheComponent = (*redisCache)(nil)
+)
+
+func testLoadingCaches(t *testing.T) map[string]LoadingCache {
+	return map[string]LoadingCache{
+		"local": newTestLocalCache(t, 0, evictionLRU),
+		"redis": newTestRedisCache(t, miniredis.RunT(t)),
+	}
+}
+
+func TestGetOrLoadSingleflight(t *testing.T) {
+	for name, c := range testLoadingCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			testGetOrLoadSingleflight(t, c)
+		})
+	}
+}
+
+func testGetOrLoadSingleflight(t *testing.T, c LoadingCache) {
+	var calls int32
+	loader := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(time.Millisecond * 50)
+		return user{Name: "alice"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var u user
+			require.NoError(t, c.GetOrLoad(context.Background(), "user", &u, time.Minute, loader))
+			require.Equal(t, "alice", u.Name)
+		}()
+	}
+
+	wg.Wait()
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	var u user
+	require.NoError(t, c.GetOrLoad(context.Background(), "user", &u, time.Minute, loader))
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestGetOrLoadNegative(t *testing.T) {
+	for name, c := range testLoadingCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			testGetOrLoadNegative(t, c)
+		})
+	}
+}
+
+func testGetOrLoadNegative(t *testing.T, c LoadingCache) {
+	var calls int32
+	loader := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, ErrNotFound
+	}
+
+	var u user
+	for i := 0; i < 3; i++ {
+		err := c.GetOrLoad(context.Background(), "user", &u, time.Minute, loader, WithNegativeTTL(time.Minute))
+		require.ErrorIs(t, err, ErrNotFound)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// Without negative caching the loader is called every time
+	for i := 0; i < 2; i++ {
+		require.ErrorIs(t, c.GetOrLoad(context.Background(), "other", &u, time.Minute, loader), ErrNotFound)
+	}
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestGetOrLoadStaleWhileRevalidate(t *testing.T) {
+	c := newTestLocalCache(t, 0, evictionLRU)
+
+	var version int32
+	reloaded := make(chan struct{}, 1)
+	loader := func(ctx context.Context) (interface{}, error) {
+		v := atomic.AddInt32(&version, 1)
+		if v > 1 {
+			select {
+			case reloaded <- struct{}{}:
+			default:
+			}
+		}
+
+		return v, nil
+	}
+
+	var value int32
+	opt := WithStaleTTL(time.Minute)
+	require.NoError(t, c.GetOrLoad(context.Background(), "key", &value, time.Millisecond*10, loader, opt))
+	require.Equal(t, int32(1), value)
+
+	time.Sleep(time.Millisecond * 20)
+
+	// The stale value is served while reloading in background
+	require.NoError(t, c.GetOrLoad(context.Background(), "key", &value, time.Millisecond*10, loader, opt))
+	require.Equal(t, int32(1), value)
+
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("value not reloaded")
+	}
+
+	require.Eventually(t, func() bool {
+		var v int32
+		return c.GetOrLoad(context.Background(), "key", &v, time.Minute, loader, opt) == nil && v >= 2
+	}, time.Second, time.Millisecond*5)
+}
+
+func TestGetOrLoadSharedKey(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range testLoadingCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			loader := func(ctx context.Context) (interface{}, error) {
+				return user{Name: "alice"}, nil
+			}
+
+			// Get reads the loaded value
+			var u user
+			require.NoError(t, c.GetOrLoad(ctx, "user:1", &u, time.Minute, loader, WithStaleTTL(time.Minute)))
+			require.NoError(t, c.Get(ctx, "user:1", &u))
+			require.Equal(t, "alice", u.Name)
+
+			// GetOrLoad reads the value of Set
+			require.NoError(t, c.Set(ctx, "user:2", user{Name: "bob"}, time.Minute))
+			require.NoError(t, c.GetOrLoad(ctx, "user:2", &u, time.Minute, func(ctx context.Context) (interface{}, error) {
+				t.Fatal("loader called on hit")
+				return nil, nil
+			}))
+			require.Equal(t, "bob", u.Name)
+
+			// The negative results are not seen by Get
+			notFound := func(ctx context.Context) (interface{}, error) {
+				return nil, ErrNotFound
+			}
+			require.ErrorIs(t, c.GetOrLoad(ctx, "user:3", &u, time.Minute, notFound, WithNegativeTTL(time.Minute)), ErrNotFound)
+			require.ErrorIs(t, c.Get(ctx, "user:3", &u), ErrNotFound)
+		})
+	}
+}
+
+func TestRedisGetOrLoadStaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	c := newTestRedisCache(t, server)
+	c.localSize = 0
+	c.setup(c.client)
+
+	var version int32
+	loader := func(ctx context.Context) (interface{}, error) {
+		return atomic.AddInt32(&version, 1), nil
+	}
+
+	var value int32
+	opt := WithStaleTTL(time.Minute)
+	require.NoError(t, c.GetOrLoad(ctx, "key", &value, time.Minute, loader, opt))
+	require.Equal(t, int32(1), value)
+
+	// Past the fresh TTL, within the stale TTL
+	server.FastForward(time.Minute + time.Second)
+
+	require.NoError(t, c.GetOrLoad(ctx, "key", &value, time.Minute, loader, opt))
+	require.Equal(t, int32(1), value)
+
+	require.Eventually(t, func() bool {
+		var v int32
+		return c.Get(ctx, "key", &v) == nil && v == 2
+	}, time.Second, time.Millisecond*5)
+}
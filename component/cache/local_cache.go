@@ -2,52 +2,569 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"reflect"
+	"strings"
 	"sync"
 	"time"
+
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultMaxSize         = 0 // 0 is unlimited number of entries
+	defaultEvictionPolicy  = evictionLRU
+	defaultCodec           = codecMsgpack
+	defaultCleanupInterval = time.Minute
 )
 
+type localCacheOpt struct {
+	prefix          string
+	maxSize         int
+	evictionPolicy  string
+	codecName       string
+	defaultTTL      time.Duration
+	cleanupInterval time.Duration
+	keyspace
+	metricsObserver
+}
+
 type localCache struct {
-	store  map[string]interface{}
-	locker *sync.RWMutex
+	id      string
+	logger  appctx.Logger
+	store   map[string]*entry
+	tags    map[string]map[string]struct{}
+	evictor evictor
+	codec   Codec
+	stats   Stats
+	locker  *sync.Mutex
+	stopCh  chan struct{}
+	loader  *cacheLoader
+	*localCacheOpt
+}
+
+func NewLocalCache(id, prefix string) *localCache {
+	// The defaults are always supported
+	evictor, _ := newEvictor(defaultEvictionPolicy)
+	codec, _ := NewCodec(defaultCodec)
+
+	c := &localCache{
+		id:      id,
+		store:   make(map[string]*entry),
+		tags:    make(map[string]map[string]struct{}),
+		evictor: evictor,
+		codec:   codec,
+		locker:  new(sync.Mutex),
+		localCacheOpt: &localCacheOpt{
+			prefix:          strings.TrimSpace(prefix),
+			maxSize:         defaultMaxSize,
+			evictionPolicy:  defaultEvictionPolicy,
+			codecName:       defaultCodec,
+			cleanupInterval: defaultCleanupInterval,
+			keyspace:        keyspace{keyVersion: defaultKeyVersion},
+		},
+	}
+
+	c.loader = newCacheLoader(c, c.getCodec)
+
+	return c
+}
+
+func (c *localCache) ID() string {
+	return c.id
+}
+
+func (c *localCache) InitFlags(fs *pflag.FlagSet) {
+	prefix := c.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.IntVar(&c.maxSize,
+		fmt.Sprintf("%slocal-cache-max-size", prefix),
+		defaultMaxSize,
+		"Local cache max number of entries, 0 is unlimited - Default: 0",
+	)
+
+	fs.StringVar(&c.evictionPolicy,
+		fmt.Sprintf("%slocal-cache-eviction-policy", prefix),
+		defaultEvictionPolicy,
+		fmt.Sprintf("Local cache eviction policy when full (lru | lfu) - Default: %s", defaultEvictionPolicy),
+	)
+
+	fs.StringVar(&c.codecName,
+		fmt.Sprintf("%slocal-cache-codec", prefix),
+		defaultCodec,
+		fmt.Sprintf("Local cache codec to copy values (msgpack | json | gob) - Default: %s", defaultCodec),
+	)
+
+	fs.DurationVar(&c.defaultTTL,
+		fmt.Sprintf("%slocal-cache-default-ttl", prefix),
+		0,
+		"Local cache TTL of entries set without TTL, 0 is never expired - Default: 0",
+	)
+
+	fs.DurationVar(&c.cleanupInterval,
+		fmt.Sprintf("%slocal-cache-cleanup-interval", prefix),
+		defaultCleanupInterval,
+		fmt.Sprintf("Local cache interval to remove expired entries, 0 is disabled - Default: %s", defaultCleanupInterval),
+	)
+
+	fs.StringVar(&c.keyPrefix,
+		fmt.Sprintf("%slocal-cache-key-prefix", prefix),
+		"",
+		"Local cache key prefix - Default: app prefix or name",
+	)
+
+	fs.IntVar(&c.keyVersion,
+		fmt.Sprintf("%slocal-cache-key-version", prefix),
+		defaultKeyVersion,
+		fmt.Sprintf("Local cache key schema version, bump to invalidate all entries - Default: %d", defaultKeyVersion),
+	)
+
+	fs.StringVar(&c.metricsID,
+		fmt.Sprintf("%slocal-cache-metrics-id", prefix),
+		"",
+		"Local cache metrics component ID, empty disables metrics - Ex: metrics",
+	)
+}
+
+func (c *localCache) Run(ac appctx.AppContext) error {
+	c.logger = ac.Logger(c.id)
+
+	evictor, err := newEvictor(c.evictionPolicy)
+	if err != nil {
+		c.logger.Error(err, "Cannot init local cache")
+		return err
+	}
+
+	codec, err := NewCodec(c.codecName)
+	if err != nil {
+		c.logger.Error(err, "Cannot init local cache")
+		return err
+	}
+
+	c.locker.Lock()
+	c.evictor = evictor
+	c.codec = codec
+	c.initKeyspace(ac)
+	c.initObserver(ac, c.id)
+	for _, e := range c.store {
+		evictor.add(e)
+	}
+	c.locker.Unlock()
+
+	if c.cleanupInterval > 0 {
+		c.stopCh = make(chan struct{})
+		go c.cleanup(c.stopCh)
+	}
+
+	return nil
+}
+
+func (c *localCache) Stop() error {
+	if c.stopCh != nil {
+		close(c.stopCh)
+		c.stopCh = nil
+	}
+
+	return nil
+}
+
+func (c *localCache) cleanup(stopCh chan struct{}) {
+	ticker := time.NewTicker(c.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.DeleteExpired()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// Set stores a copy of the value encoded by the codec, ttl <= 0 uses the default TTL
+func (c *localCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) (err error) {
+	defer c.observe("set", time.Now(), &err)
+
+	return c.setWithTags(key, value, ttl)
+}
+
+// SetWithTags stores the value and tags it, to be invalidated by InvalidateTags
+func (c *localCache) SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) (err error) {
+	defer c.observe("set_with_tags", time.Now(), &err)
+
+	return c.setWithTags(key, value, ttl, tags...)
 }
 
-func NewLocalCache() *localCache {
-	return &localCache{
-		store:  make(map[string]interface{}),
-		locker: new(sync.RWMutex),
+func (c *localCache) setWithTags(key string, value interface{}, ttl time.Duration, tags ...string) error {
+	data, err := c.getCodec().Marshal(value)
+	if err != nil {
+		return err
 	}
+
+	expiresAt := c.getExpiresAt(ttl)
+
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	c.put(key, data, expiresAt, tags)
+
+	return nil
 }
 
-func (c *localCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+func (c *localCache) MSet(ctx context.Context, values map[string]interface{}, ttl time.Duration) (err error) {
+	defer c.observe("mset", time.Now(), &err)
+
+	codec := c.getCodec()
+	expiresAt := c.getExpiresAt(ttl)
+
+	items := make(map[string][]byte, len(values))
+	for key, value := range values {
+		data, err := codec.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		items[key] = data
+	}
+
 	c.locker.Lock()
 	defer c.locker.Unlock()
 
-	c.store[key] = value
+	for key, data := range items {
+		c.put(key, data, expiresAt, nil)
+	}
 
 	return nil
 }
 
-func (c *localCache) Get(ctx context.Context, key string, value interface{}) error {
+func (c *localCache) Get(ctx context.Context, key string, value interface{}) (err error) {
+	defer c.observe("get", time.Now(), &err)
+
+	err = c.get(key, value)
+	if err == nil {
+		c.observeRead(1, 0)
+	} else if errors.Is(err, ErrNotFound) {
+		c.observeRead(0, 1)
+	}
+
+	return err
+}
+
+func (c *localCache) get(key string, value interface{}) error {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	e, ok := c.lookup(key)
+	if !ok {
+		c.stats.Misses++
+		return ErrNotFound
+	}
+
+	c.stats.Hits++
+	c.evictor.access(e)
+
+	return c.codec.Unmarshal(e.value, value)
+}
+
+// MGet decodes the found values into the pointers of values, keyed by cache key, and returns the missing keys
+func (c *localCache) MGet(ctx context.Context, values map[string]interface{}) (missing []string, err error) {
+	defer c.observe("mget", time.Now(), &err)
+
+	for key, value := range values {
+		if err := c.get(key, value); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				missing = append(missing, key)
+				continue
+			}
+
+			return nil, err
+		}
+	}
+
+	c.observeRead(len(values)-len(missing), len(missing))
+
+	return missing, nil
+}
+
+func (c *localCache) Exists(ctx context.Context, key string) (_ bool, err error) {
+	defer c.observe("exists", time.Now(), &err)
+
 	c.locker.Lock()
 	defer c.locker.Unlock()
 
-	val, ok := c.store[key]
+	_, ok := c.lookup(key)
+
+	return ok, nil
+}
+
+// Expire updates the TTL of the key, ttl <= 0 removes the expiration
+func (c *localCache) Expire(ctx context.Context, key string, ttl time.Duration) (err error) {
+	defer c.observe("expire", time.Now(), &err)
+
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	e, ok := c.lookup(key)
 	if !ok {
-		return fmt.Errorf("key not found: %s", key)
+		return ErrNotFound
 	}
 
-	reflect.ValueOf(value).Elem().Set(reflect.ValueOf(val).Elem())
+	e.expiresAt = 0
+	if ttl > 0 {
+		e.expiresAt = time.Now().Add(ttl).UnixNano()
+	}
 
 	return nil
 }
 
-func (c *localCache) Delete(ctx context.Context, key string) error {
+// TTL returns the remaining time to live of the key, 0 is never expired
+func (c *localCache) TTL(ctx context.Context, key string) (_ time.Duration, err error) {
+	defer c.observe("ttl", time.Now(), &err)
+
 	c.locker.Lock()
 	defer c.locker.Unlock()
 
-	delete(c.store, key)
+	e, ok := c.lookup(key)
+	if !ok {
+		return 0, ErrNotFound
+	}
+
+	if e.expiresAt == 0 {
+		return 0, nil
+	}
+
+	return time.Until(time.Unix(0, e.expiresAt)), nil
+}
+
+// Incr adds delta to the counter, ttl is applied when the counter is created, the tags are kept
+func (c *localCache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (_ int64, err error) {
+	defer c.observe("incr", time.Now(), &err)
+
+	return c.incr(key, delta, ttl)
+}
+
+func (c *localCache) incr(key string, delta int64, ttl time.Duration) (int64, error) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	var (
+		counter int64
+		tags    []string
+	)
+	expiresAt := c.getExpiresAt(ttl)
+
+	if e, ok := c.lookup(key); ok {
+		if err := c.codec.Unmarshal(e.value, &counter); err != nil {
+			return 0, err
+		}
+
+		expiresAt = e.expiresAt
+		tags = e.tags
+	}
+
+	counter += delta
+
+	data, err := c.codec.Marshal(counter)
+	if err != nil {
+		return 0, err
+	}
+
+	c.put(key, data, expiresAt, tags)
+
+	return counter, nil
+}
+
+func (c *localCache) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (_ int64, err error) {
+	defer c.observe("decr", time.Now(), &err)
+
+	return c.incr(key, -delta, ttl)
+}
+
+// GetOrLoad gets the value, on miss loads and caches it with a single loader call per key.
+// Its reads and writes are also observed
+func (c *localCache) GetOrLoad(ctx context.Context, key string, dst interface{}, ttl time.Duration, loader LoaderFunc, opts ...LoadOption) (err error) {
+	defer c.observe("get_or_load", time.Now(), &err)
+
+	return c.loader.getOrLoad(ctx, key, dst, ttl, loader, opts...)
+}
+
+func (c *localCache) Delete(ctx context.Context, key string) (err error) {
+	defer c.observe("delete", time.Now(), &err)
+
+	return c.mdelete(key)
+}
+
+func (c *localCache) MDelete(ctx context.Context, keys ...string) (err error) {
+	defer c.observe("mdelete", time.Now(), &err)
+
+	return c.mdelete(keys...)
+}
+
+func (c *localCache) mdelete(keys ...string) error {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	for _, key := range keys {
+		if e, ok := c.store[c.key(key)]; ok {
+			c.remove(e)
+		}
+	}
 
 	return nil
 }
+
+func (c *localCache) DeleteByPrefix(ctx context.Context, prefix string) (err error) {
+	defer c.observe("delete_by_prefix", time.Now(), &err)
+
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	prefix = c.key(prefix)
+	for key, e := range c.store {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(e)
+		}
+	}
+
+	return nil
+}
+
+// InvalidateTags deletes all keys tagged with any of tags
+func (c *localCache) InvalidateTags(ctx context.Context, tags ...string) (err error) {
+	defer c.observe("invalidate_tags", time.Now(), &err)
+
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			if e, ok := c.store[key]; ok {
+				c.remove(e)
+			}
+		}
+
+		delete(c.tags, tag)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes all expired entries
+func (c *localCache) DeleteExpired() {
+	now := time.Now().UnixNano()
+
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	for _, e := range c.store {
+		if e.isExpired(now) {
+			c.remove(e)
+			c.stats.Expirations++
+		}
+	}
+}
+
+func (c *localCache) Stats() Stats {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	stats := c.stats
+	stats.Size = len(c.store)
+
+	return stats
+}
+
+func (c *localCache) getCodec() Codec {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	return c.codec
+}
+
+func (c *localCache) getExpiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		ttl = c.defaultTTL
+	}
+
+	if ttl <= 0 {
+		return 0
+	}
+
+	return time.Now().Add(ttl).UnixNano()
+}
+
+// lookup returns the entry if it is not expired, expired entries are removed lazily
+func (c *localCache) lookup(key string) (*entry, bool) {
+	e, ok := c.store[c.key(key)]
+	if ok && e.isExpired(time.Now().UnixNano()) {
+		c.remove(e)
+		c.stats.Expirations++
+		return nil, false
+	}
+
+	return e, ok
+}
+
+func (c *localCache) put(key string, data []byte, expiresAt int64, tags []string) {
+	key = c.key(key)
+	e, ok := c.store[key]
+	if ok {
+		c.untag(e)
+		e.value = data
+		e.expiresAt = expiresAt
+		c.evictor.access(e)
+	} else {
+		if c.maxSize > 0 && len(c.store) >= c.maxSize {
+			c.evict()
+		}
+
+		e = &entry{
+			key:       key,
+			value:     data,
+			expiresAt: expiresAt,
+		}
+
+		c.store[key] = e
+		c.evictor.add(e)
+	}
+
+	e.tags = tags
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+
+		keys[key] = struct{}{}
+	}
+}
+
+func (c *localCache) untag(e *entry) {
+	for _, tag := range e.tags {
+		delete(c.tags[tag], e.key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+
+	e.tags = nil
+}
+
+func (c *localCache) evict() {
+	if e := c.evictor.victim(); e != nil {
+		c.remove(e)
+		c.stats.Evictions++
+	}
+}
+
+func (c *localCache) remove(e *entry) {
+	c.untag(e)
+	c.evictor.remove(e)
+	delete(c.store, e.key)
+}
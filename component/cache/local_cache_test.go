@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLocalCache(t *testing.T, maxSize int, policy string) *localCache {
+	c := NewLocalCache("cache", "")
+	c.maxSize = maxSize
+
+	evictor, err := newEvictor(policy)
+	require.NoError(t, err)
+	c.evictor = evictor
+
+	return c
+}
+
+func set(t *testing.T, c *localCache, key string, value int, ttl time.Duration) {
+	require.NoError(t, c.Set(context.Background(), key, &value, ttl))
+}
+
+func get(c *localCache, key string) (int, bool) {
+	var value int
+	err := c.Get(context.Background(), key, &value)
+	return value, err == nil
+}
+
+func TestLocalCacheTTL(t *testing.T) {
+	c := newTestLocalCache(t, 0, evictionLRU)
+
+	set(t, c, "short", 1, time.Millisecond*10)
+	set(t, c, "forever", 2, 0)
+
+	value, ok := get(c, "short")
+	require.True(t, ok)
+	require.Equal(t, 1, value)
+
+	time.Sleep(time.Millisecond * 20)
+
+	_, ok = get(c, "short")
+	require.False(t, ok)
+
+	value, ok = get(c, "forever")
+	require.True(t, ok)
+	require.Equal(t, 2, value)
+
+	set(t, c, "background", 3, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 20)
+	c.DeleteExpired()
+
+	stats := c.Stats()
+	require.Equal(t, 1, stats.Size)
+	require.Equal(t, uint64(2), stats.Hits)
+	require.Equal(t, uint64(1), stats.Misses)
+	require.Equal(t, uint64(2), stats.Expirations)
+}
+
+func TestLocalCacheLRU(t *testing.T) {
+	c := newTestLocalCache(t, 2, evictionLRU)
+
+	set(t, c, "a", 1, 0)
+	set(t, c, "b", 2, 0)
+	get(c, "a")
+	set(t, c, "c", 3, 0)
+
+	_, ok := get(c, "b")
+	require.False(t, ok)
+	_, ok = get(c, "a")
+	require.True(t, ok)
+	_, ok = get(c, "c")
+	require.True(t, ok)
+	require.Equal(t, uint64(1), c.Stats().Evictions)
+}
+
+func TestLocalCacheLFU(t *testing.T) {
+	c := newTestLocalCache(t, 2, evictionLFU)
+
+	set(t, c, "a", 1, 0)
+	set(t, c, "b", 2, 0)
+	get(c, "a")
+	get(c, "a")
+	get(c, "b")
+	set(t, c, "c", 3, 0)
+
+	_, ok := get(c, "b")
+	require.False(t, ok)
+	_, ok = get(c, "a")
+	require.True(t, ok)
+
+	require.NoError(t, c.Delete(context.Background(), "a"))
+	require.Equal(t, 1, c.Stats().Size)
+}
+
+func TestEvictionPolicyNotSupported(t *testing.T) {
+	_, err := newEvictor("fifo")
+	require.ErrorIs(t, err, ErrEvictionPolicyNotSupported)
+}
+
+type user struct {
+	Name  string
+	Roles []string
+}
+
+func TestLocalCacheCodec(t *testing.T) {
+	ctx := context.Background()
+
+	for _, name := range []string{codecMsgpack, codecJSON, codecGob} {
+		t.Run(name, func(t *testing.T) {
+			c := newTestLocalCache(t, 0, evictionLRU)
+
+			codec, err := NewCodec(name)
+			require.NoError(t, err)
+			c.codec = codec
+
+			u := user{Name: "alice", Roles: []string{"admin"}}
+			require.NoError(t, c.Set(ctx, "user", u, 0))
+
+			// The cached value is a copy
+			u.Roles[0] = "guest"
+
+			var got user
+			require.NoError(t, c.Get(ctx, "user", &got))
+			require.Equal(t, []string{"admin"}, got.Roles)
+
+			got.Name = "bob"
+			var again user
+			require.NoError(t, c.Get(ctx, "user", &again))
+			require.Equal(t, "alice", again.Name)
+
+			var mismatch int
+			require.Error(t, c.Get(ctx, "user", &mismatch))
+
+			require.ErrorIs(t, c.Get(ctx, "missing", &got), ErrNotFound)
+		})
+	}
+
+	_, err := NewCodec("xml")
+	require.ErrorIs(t, err, ErrCodecNotSupported)
+}
+
+func TestLocalCacheIncrKeepsTags(t *testing.T) {
+	ctx := context.Background()
+	c := newTestLocalCache(t, 0, evictionLRU)
+
+	require.NoError(t, c.SetWithTags(ctx, "counter", int64(1), time.Minute, "counters"))
+	counter, err := c.Incr(ctx, "counter", 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), counter)
+
+	require.NoError(t, c.InvalidateTags(ctx, "counters"))
+
+	exists, err := c.Exists(ctx, "counter")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
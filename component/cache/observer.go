@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"errors"
+	"time"
+
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/hoangtk0100/app-context/core"
+)
+
+// metricsObserver reports the operations to the metrics component of the metrics ID flag, if set.
+// A key not found is not an error
+type metricsObserver struct {
+	metricsID string
+	observer  core.CacheObserver
+}
+
+func (o *metricsObserver) initObserver(ac appctx.AppContext, name string) {
+	if o.metricsID == "" {
+		return
+	}
+
+	o.observer = ac.MustGet(o.metricsID).(core.MetricsComponent).CacheObserver(name)
+}
+
+// observe is deferred with the error of the operation
+func (o *metricsObserver) observe(operation string, startTime time.Time, err *error) {
+	if o.observer == nil {
+		return
+	}
+
+	opErr := *err
+	if errors.Is(opErr, ErrNotFound) {
+		opErr = nil
+	}
+
+	o.observer.OnOperation(operation, time.Since(startTime), opErr)
+}
+
+func (o *metricsObserver) observeRead(hits, misses int) {
+	if o.observer != nil {
+		o.observer.OnRead(hits, misses)
+	}
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testObserver struct {
+	mu         sync.Mutex
+	operations map[string]int
+	errors     int
+	hits       int
+	misses     int
+}
+
+func (o *testObserver) OnOperation(operation string, duration time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.operations[operation]++
+	if err != nil {
+		o.errors++
+	}
+}
+
+func (o *testObserver) OnRead(hits, misses int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.hits += hits
+	o.misses += misses
+}
+
+func TestCacheObserver(t *testing.T) {
+	ctx := context.Background()
+
+	for name, c := range testLoadingCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			observer := &testObserver{operations: make(map[string]int)}
+			switch c := c.(type) {
+			case *localCache:
+				c.observer = observer
+			case *redisCache:
+				c.observer = observer
+			}
+
+			var u user
+			require.NoError(t, c.Set(ctx, "user:1", user{Name: "alice"}, time.Minute))
+			require.NoError(t, c.Get(ctx, "user:1", &u))
+			require.ErrorIs(t, c.Get(ctx, "user:2", &u), ErrNotFound)
+
+			_, err := c.MGet(ctx, map[string]interface{}{"user:1": &u, "user:2": &u, "user:3": &u})
+			require.NoError(t, err)
+
+			require.NoError(t, c.Delete(ctx, "user:1"))
+			_, err = c.Decr(ctx, "count", 1, time.Minute)
+			require.NoError(t, err)
+
+			// The reads and writes of the loader are reported as well
+			require.NoError(t, c.GetOrLoad(ctx, "user:4", &u, time.Minute, func(ctx context.Context) (interface{}, error) {
+				return user{Name: "bob"}, nil
+			}))
+
+			// Delegating methods are reported once
+			require.Equal(t, 2, observer.operations["set"])
+			require.Equal(t, 1, observer.operations["delete"])
+			require.Equal(t, 1, observer.operations["decr"])
+			require.Zero(t, observer.operations["set_with_tags"])
+			require.Zero(t, observer.operations["mdelete"])
+			require.Zero(t, observer.operations["incr"])
+
+			require.Equal(t, 3, observer.operations["get"])
+			require.Equal(t, 1, observer.operations["mget"])
+			require.Equal(t, 1, observer.operations["get_or_load"])
+
+			// A key not found is not an error
+			require.Zero(t, observer.errors)
+			require.Equal(t, 2, observer.hits)
+			require.Equal(t, 4, observer.misses)
+		})
+	}
+}
@@ -2,43 +2,623 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
 	"time"
 
 	rdcache "github.com/go-redis/cache/v9"
+	"github.com/google/uuid"
 	appctx "github.com/hoangtk0100/app-context"
 	"github.com/hoangtk0100/app-context/core"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/pflag"
 )
 
+const (
+	tagKeyPrefix  = "tags:"
+	scanBatchSize = 500
+
+	defaultLocalSize           = 1000
+	defaultLocalTTL            = time.Minute
+	defaultInvalidationChannel = "cache:invalidation"
+)
+
+// Keeps the tag set as long as its longest lived key, ARGV[2] <= 0 is never expired
+var tagScript = redis.NewScript(`
+local existed = redis.call("EXISTS", KEYS[1])
+redis.call("SADD", KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl <= 0 then
+	redis.call("PERSIST", KEYS[1])
+	return 0
+end
+local current = redis.call("PTTL", KEYS[1])
+if existed == 0 or (current >= 0 and current < ttl) then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 1
+`)
+
+// Sets the TTL only when the counter has none, so it is applied on creation
+var incrScript = redis.NewScript(`
+local counter = redis.call("INCRBY", KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call("PTTL", KEYS[1]) == -1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return counter
+`)
+
+type redisCacheOpt struct {
+	prefix              string
+	redisDBID           string
+	localSize           int
+	localTTL            time.Duration
+	defaultTTL          time.Duration
+	invalidation        bool
+	invalidationChannel string
+	keyspace
+	metricsObserver
+}
+
+// Two-tier cache: in-process TinyLFU in front of Redis.
+// Writes are broadcast on a Redis channel, so other instances drop their local entries
 type redisCache struct {
-	store *rdcache.Cache
+	id       string
+	source   string
+	logger   appctx.Logger
+	client   redis.UniversalClient
+	store    *rdcache.Cache
+	codec    Codec
+	loader   *cacheLoader
+	pubSub   *redis.PubSub
+	doneChan chan struct{}
+	*redisCacheOpt
+}
+
+type invalidationMessage struct {
+	Source string   `json:"source"`
+	Keys   []string `json:"keys"`
+}
+
+func NewRedisCache(id, prefix, redisDBID string) *redisCache {
+	rdc := &redisCache{
+		id:     id,
+		source: uuid.NewString(),
+		codec:  msgpackCodec{},
+		redisCacheOpt: &redisCacheOpt{
+			prefix:              strings.TrimSpace(prefix),
+			redisDBID:           redisDBID,
+			localSize:           defaultLocalSize,
+			localTTL:            defaultLocalTTL,
+			invalidation:        true,
+			invalidationChannel: defaultInvalidationChannel,
+			keyspace:            keyspace{keyVersion: defaultKeyVersion},
+		},
+	}
+
+	rdc.loader = newCacheLoader(rdc, rdc.getCodec)
+
+	return rdc
+}
+
+func (rdc *redisCache) ID() string {
+	return rdc.id
+}
+
+func (rdc *redisCache) InitFlags(fs *pflag.FlagSet) {
+	prefix := rdc.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.IntVar(&rdc.localSize,
+		fmt.Sprintf("%sredis-cache-local-size", prefix),
+		defaultLocalSize,
+		fmt.Sprintf("Redis cache local tier max number of entries, 0 is disabled - Default: %d", defaultLocalSize),
+	)
+
+	fs.DurationVar(&rdc.localTTL,
+		fmt.Sprintf("%sredis-cache-local-ttl", prefix),
+		defaultLocalTTL,
+		fmt.Sprintf("Redis cache local tier TTL - Default: %s", defaultLocalTTL),
+	)
+
+	fs.DurationVar(&rdc.defaultTTL,
+		fmt.Sprintf("%sredis-cache-default-ttl", prefix),
+		0,
+		"Redis cache TTL of entries set without TTL, 0 is never expired - Default: 0",
+	)
+
+	fs.BoolVar(&rdc.invalidation,
+		fmt.Sprintf("%sredis-cache-invalidation", prefix),
+		true,
+		"Redis cache broadcasts writes to drop the local tier entries of other instances - Default: true",
+	)
+
+	fs.StringVar(&rdc.invalidationChannel,
+		fmt.Sprintf("%sredis-cache-invalidation-channel", prefix),
+		defaultInvalidationChannel,
+		fmt.Sprintf("Redis cache invalidation channel - Default: %s", defaultInvalidationChannel),
+	)
+
+	fs.StringVar(&rdc.keyPrefix,
+		fmt.Sprintf("%sredis-cache-key-prefix", prefix),
+		"",
+		"Redis cache key prefix to not clobber the keys of other services - Default: app prefix or name",
+	)
+
+	fs.IntVar(&rdc.keyVersion,
+		fmt.Sprintf("%sredis-cache-key-version", prefix),
+		defaultKeyVersion,
+		fmt.Sprintf("Redis cache key schema version, bump to invalidate all entries - Default: %d", defaultKeyVersion),
+	)
+
+	fs.StringVar(&rdc.metricsID,
+		fmt.Sprintf("%sredis-cache-metrics-id", prefix),
+		"",
+		"Redis cache metrics component ID, empty disables metrics - Ex: metrics",
+	)
+}
+
+func (rdc *redisCache) Run(ac appctx.AppContext) error {
+	rdc.logger = ac.Logger(rdc.id)
+	rdc.initKeyspace(ac)
+	rdc.initObserver(ac, rdc.id)
+
+	redisDB := ac.MustGet(rdc.redisDBID).(core.RedisDBComponent)
+	rdc.setup(redisDB.GetDB())
+
+	if rdc.isInvalidationEnabled() {
+		if err := rdc.subscribeInvalidation(); err != nil {
+			rdc.logger.Error(err, "Cannot subscribe Redis cache invalidation")
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rdc *redisCache) Stop() error {
+	if rdc.pubSub == nil {
+		return nil
+	}
+
+	err := rdc.pubSub.Close()
+	if rdc.doneChan != nil {
+		<-rdc.doneChan
+	}
+
+	// Closed with the client of the Redis component
+	if errors.Is(err, redis.ErrClosed) || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+
+	return err
+}
+
+func (rdc *redisCache) setup(client redis.UniversalClient) {
+	opt := &rdcache.Options{
+		Redis: client,
+	}
+
+	if rdc.localSize > 0 {
+		opt.LocalCache = rdcache.NewTinyLFU(rdc.localSize, rdc.localTTL)
+	}
+
+	rdc.client = client
+	rdc.store = rdcache.New(opt)
+}
+
+func (rdc *redisCache) isInvalidationEnabled() bool {
+	return rdc.invalidation && rdc.localSize > 0
 }
 
-func NewRedisCache(redisDBComponentName string, appCtx appctx.AppContext) *redisCache {
-	redisDB := appCtx.MustGet(redisDBComponentName).(core.RedisDBComponent)
+func (rdc *redisCache) subscribeInvalidation() error {
+	ctx := context.Background()
+
+	pubSub := rdc.client.Subscribe(ctx, rdc.invalidationChannel)
+	// Wait for the subscription to be confirmed
+	if _, err := pubSub.Receive(ctx); err != nil {
+		_ = pubSub.Close()
+		return err
+	}
+
+	rdc.pubSub = pubSub
+	rdc.doneChan = make(chan struct{})
+
+	go func() {
+		defer close(rdc.doneChan)
+
+		for msg := range pubSub.Channel() {
+			var inv invalidationMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+				continue
+			}
+
+			// Own writes are already applied to the local tier
+			if inv.Source == rdc.source {
+				continue
+			}
+
+			for _, key := range inv.Keys {
+				rdc.store.DeleteFromLocalCache(key)
+			}
+		}
+	}()
+
+	return nil
+}
 
-	c := rdcache.New(&rdcache.Options{
-		Redis:      redisDB.GetDB(),
-		LocalCache: rdcache.NewTinyLFU(1000, time.Minute),
+// invalidate drops the keys from the local tier of all instances
+func (rdc *redisCache) invalidate(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		rdc.store.DeleteFromLocalCache(key)
+	}
+
+	return rdc.broadcast(ctx, keys...)
+}
+
+func (rdc *redisCache) broadcast(ctx context.Context, keys ...string) error {
+	if !rdc.isInvalidationEnabled() || len(keys) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(&invalidationMessage{
+		Source: rdc.source,
+		Keys:   keys,
 	})
+	if err != nil {
+		return err
+	}
+
+	return rdc.client.Publish(ctx, rdc.invalidationChannel, payload).Err()
+}
+
+// Set writes to Redis directly, the local tier of rdcache maps TTLs under 1s to 1h
+func (rdc *redisCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) (err error) {
+	defer rdc.observe("set", time.Now(), &err)
+
+	return rdc.setWithTags(ctx, key, value, ttl)
+}
+
+func (rdc *redisCache) SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) (err error) {
+	defer rdc.observe("set_with_tags", time.Now(), &err)
+
+	return rdc.setWithTags(ctx, key, value, ttl, tags...)
+}
+
+func (rdc *redisCache) setWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error {
+	data, err := rdc.store.Marshal(value)
+	if err != nil {
+		return err
+	}
 
-	return &redisCache{
-		store: c,
+	key = rdc.key(key)
+	ttl = rdc.getTTL(ttl)
+
+	// Not transactional, the key and the tag sets can be on different cluster slots
+	if _, err := rdc.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, data, ttl)
+		for _, tag := range tags {
+			tagKey := rdc.tagKey(tag)
+			tagScript.Eval(ctx, pipe, []string{tagKey}, key, ttl.Milliseconds())
+		}
+
+		return nil
+	}); err != nil {
+		return err
 	}
+
+	return rdc.invalidate(ctx, key)
 }
 
-func (rdc *redisCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
-	return rdc.store.Set(&rdcache.Item{
-		Ctx:   ctx,
-		Key:   key,
-		Value: value,
-		TTL:   ttl,
+func (rdc *redisCache) MSet(ctx context.Context, values map[string]interface{}, ttl time.Duration) (err error) {
+	defer rdc.observe("mset", time.Now(), &err)
+
+	keys := make([]string, 0, len(values))
+	items := make(map[string][]byte, len(values))
+	for key, value := range values {
+		data, err := rdc.store.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		key = rdc.key(key)
+		keys = append(keys, key)
+		items[key] = data
+	}
+
+	ttl = rdc.getTTL(ttl)
+	if _, err := rdc.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, data := range items {
+			pipe.Set(ctx, key, data, ttl)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return rdc.invalidate(ctx, keys...)
+}
+
+func (rdc *redisCache) Get(ctx context.Context, key string, value interface{}) (err error) {
+	defer rdc.observe("get", time.Now(), &err)
+
+	if err := rdc.store.Get(ctx, rdc.key(key), value); err != nil {
+		if errors.Is(err, rdcache.ErrCacheMiss) {
+			rdc.observeRead(0, 1)
+			return ErrNotFound
+		}
+
+		return err
+	}
+
+	rdc.observeRead(1, 0)
+
+	return nil
+}
+
+func (rdc *redisCache) MGet(ctx context.Context, values map[string]interface{}) (missing []string, err error) {
+	defer rdc.observe("mget", time.Now(), &err)
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	// GET by pipeline instead of MGET, the keys can be on different cluster slots
+	cmds := make([]*redis.StringCmd, len(keys))
+	if _, err := rdc.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, rdc.key(key))
+		}
+
+		return nil
+	}); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for i, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if err == redis.Nil {
+			missing = append(missing, keys[i])
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := rdc.store.Unmarshal(data, values[keys[i]]); err != nil {
+			return nil, err
+		}
+	}
+
+	rdc.observeRead(len(keys)-len(missing), len(missing))
+
+	return missing, nil
+}
+
+// GetOrLoad gets the value, on miss loads and caches it with a single loader call per key.
+// Its reads and writes are also observed
+func (rdc *redisCache) GetOrLoad(ctx context.Context, key string, dst interface{}, ttl time.Duration, loader LoaderFunc, opts ...LoadOption) (err error) {
+	defer rdc.observe("get_or_load", time.Now(), &err)
+
+	return rdc.loader.getOrLoad(ctx, key, dst, ttl, loader, opts...)
+}
+
+func (rdc *redisCache) Exists(ctx context.Context, key string) (_ bool, err error) {
+	defer rdc.observe("exists", time.Now(), &err)
+
+	count, err := rdc.client.Exists(ctx, rdc.key(key)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (rdc *redisCache) Expire(ctx context.Context, key string, ttl time.Duration) (err error) {
+	defer rdc.observe("expire", time.Now(), &err)
+
+	var ok bool
+
+	key = rdc.key(key)
+	if ttl > 0 {
+		ok, err = rdc.client.Expire(ctx, key, ttl).Result()
+	} else {
+		ok, err = rdc.client.Persist(ctx, key).Result()
+		if err == nil && !ok {
+			// Persist also returns false for keys without expiration
+			var count int64
+			count, err = rdc.client.Exists(ctx, key).Result()
+			ok = count > 0
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	return rdc.invalidate(ctx, key)
+}
+
+func (rdc *redisCache) TTL(ctx context.Context, key string) (_ time.Duration, err error) {
+	defer rdc.observe("ttl", time.Now(), &err)
+
+	ttl, err := rdc.client.PTTL(ctx, rdc.key(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// -2 is missing key, -1 is never expired
+	switch ttl {
+	case -2:
+		return 0, ErrNotFound
+	case -1:
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+func (rdc *redisCache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (_ int64, err error) {
+	defer rdc.observe("incr", time.Now(), &err)
+
+	return rdc.incr(ctx, key, delta, ttl)
+}
+
+func (rdc *redisCache) incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return incrScript.Run(ctx, rdc.client, []string{rdc.key(key)}, delta, rdc.getTTL(ttl).Milliseconds()).Int64()
+}
+
+func (rdc *redisCache) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (_ int64, err error) {
+	defer rdc.observe("decr", time.Now(), &err)
+
+	return rdc.incr(ctx, key, -delta, ttl)
+}
+
+func (rdc *redisCache) Delete(ctx context.Context, key string) (err error) {
+	defer rdc.observe("delete", time.Now(), &err)
+
+	return rdc.mdelete(ctx, key)
+}
+
+func (rdc *redisCache) MDelete(ctx context.Context, keys ...string) (err error) {
+	defer rdc.observe("mdelete", time.Now(), &err)
+
+	return rdc.mdelete(ctx, keys...)
+}
+
+func (rdc *redisCache) mdelete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	keys = rdc.keys(keys)
+	if err := rdc.unlink(ctx, keys); err != nil {
+		return err
+	}
+
+	return rdc.invalidate(ctx, keys...)
+}
+
+// DeleteByPrefix scans the keys by batch instead of KEYS to not block Redis
+func (rdc *redisCache) DeleteByPrefix(ctx context.Context, prefix string) (err error) {
+	defer rdc.observe("delete_by_prefix", time.Now(), &err)
+
+	match := escapePattern(rdc.key(prefix)) + "*"
+
+	deleteKeys := func(ctx context.Context, client redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(ctx, cursor, match, scanBatchSize).Result()
+			if err != nil {
+				return err
+			}
+
+			if len(keys) > 0 {
+				if err := rdc.unlink(ctx, keys); err != nil {
+					return err
+				}
+
+				if err := rdc.invalidate(ctx, keys...); err != nil {
+					return err
+				}
+			}
+
+			cursor = next
+			if cursor == 0 {
+				return nil
+			}
+		}
+	}
+
+	// SCAN only iterates the keys of one node
+	if cluster, ok := rdc.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return deleteKeys(ctx, client)
+		})
+	}
+
+	return deleteKeys(ctx, rdc.client)
+}
+
+func (rdc *redisCache) InvalidateTags(ctx context.Context, tags ...string) (err error) {
+	defer rdc.observe("invalidate_tags", time.Now(), &err)
+
+	for _, tag := range tags {
+		tagKey := rdc.tagKey(tag)
+
+		keys, err := rdc.client.SMembers(ctx, tagKey).Result()
+		if err != nil {
+			return err
+		}
+
+		if err := rdc.unlink(ctx, append(keys, tagKey)); err != nil {
+			return err
+		}
+
+		if err := rdc.invalidate(ctx, keys...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unlink deletes the keys by pipeline, multi-key commands fail across cluster slots
+func (rdc *redisCache) unlink(ctx context.Context, keys []string) error {
+	_, err := rdc.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Unlink(ctx, key)
+		}
+
+		return nil
 	})
+
+	return err
+}
+
+// getTTL applies the default TTL, 0 is never expired
+func (rdc *redisCache) getTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		ttl = rdc.defaultTTL
+	}
+
+	if ttl <= 0 {
+		return 0
+	}
+
+	return ttl
+}
+
+func (rdc *redisCache) tagKey(tag string) string {
+	return rdc.key(tagKeyPrefix + tag)
 }
 
-func (rdc *redisCache) Get(ctx context.Context, key string, value interface{}) error {
-	return rdc.store.Get(ctx, key, value)
+func (rdc *redisCache) getCodec() Codec {
+	return rdc.codec
 }
 
-func (rdc *redisCache) Delete(ctx context.Context, key string) error {
-	return rdc.store.Delete(ctx, key)
+func escapePattern(pattern string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		"*", `\*`,
+		"?", `\?`,
+		"[", `\[`,
+		"]", `\]`,
+	).Replace(pattern)
 }
@@ -5,7 +5,9 @@ import (
 	"fmt"
 
 	appctx "github.com/hoangtk0100/app-context"
+	"github.com/hoangtk0100/app-context/component/tracing"
 	"github.com/hoangtk0100/app-context/core"
+	"github.com/hoangtk0100/app-context/logadapter"
 	"github.com/spf13/pflag"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -17,9 +19,11 @@ const (
 )
 
 type config struct {
-	address     string
-	tlsCertFile string
-	dialOpts    []grpc.DialOption
+	address       string
+	tlsCertFile   string
+	enableTracing bool
+	metricsID     string
+	dialOpts      []grpc.DialOption
 }
 
 type grpcClient struct {
@@ -41,29 +45,44 @@ func (gc *grpcClient) ID() string {
 	return gc.id
 }
 
-func (gc *grpcClient) InitFlags() {
+func (gc *grpcClient) InitFlags(fs *pflag.FlagSet) {
 	prefix := gc.prefix
 	if prefix != "" {
 		prefix = "-" + prefix
 	}
 
-	pflag.StringVar(
+	fs.StringVar(
 		&gc.address,
 		fmt.Sprintf("grpc%s-client-address", prefix),
 		defaultClientAddress,
 		fmt.Sprintf("GRPC client%s address - Default: %s", prefix, defaultClientAddress),
 	)
 
-	pflag.StringVar(
+	fs.StringVar(
 		&gc.tlsCertFile,
 		fmt.Sprintf("grpc%s-client-tls-cert-file", prefix),
 		"",
 		fmt.Sprintf("GRPC client%s TLS cert file", prefix),
 	)
+
+	fs.BoolVar(
+		&gc.enableTracing,
+		fmt.Sprintf("grpc%s-client-enable-tracing", prefix),
+		false,
+		fmt.Sprintf("GRPC client%s enable tracing (OpenTelemetry) - Default: false", prefix),
+	)
+
+	fs.StringVar(
+		&gc.metricsID,
+		fmt.Sprintf("grpc%s-client-metrics-id", prefix),
+		"",
+		fmt.Sprintf("GRPC client%s metrics component ID, empty disables metrics - Ex: metrics", prefix),
+	)
 }
 
 func (gc *grpcClient) Run(ac appctx.AppContext) error {
 	gc.logger = ac.Logger(gc.id)
+	logadapter.SetGrpcLogger(ac.Logger("grpc"))
 
 	if gc.tlsCertFile != "" {
 		creds, err := credentials.NewClientTLSFromFile(gc.tlsCertFile, "")
@@ -80,6 +99,18 @@ func (gc *grpcClient) Run(ac appctx.AppContext) error {
 	}
 
 	gc.dialOpts = append(gc.dialOpts, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(1024*1024*10)))
+
+	if gc.enableTracing {
+		gc.dialOpts = append(gc.dialOpts,
+			grpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor()),
+			grpc.WithChainStreamInterceptor(tracing.StreamClientInterceptor()),
+		)
+	}
+
+	if gc.metricsID != "" {
+		gc.dialOpts = append(gc.dialOpts, ac.MustGet(gc.metricsID).(core.MetricsComponent).GRPCClientDialOptions()...)
+	}
+
 	gc.logger.Infof("Init GRPC%s client", gc.getPrefixedValue())
 	return nil
 }
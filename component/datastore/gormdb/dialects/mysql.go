@@ -6,6 +6,6 @@ import (
 )
 
 // Ex: username:password@tcp(localhost:3306)/db_name?charset=utf8mb4&parseTime=True&loc=Local
-func MySqlDB(source string) (db *gorm.DB, err error) {
-	return gorm.Open(mysql.Open(source), &gorm.Config{})
+func MySqlDB(source string, opts ...gorm.Option) (db *gorm.DB, err error) {
+	return gorm.Open(mysql.Open(source), opts...)
 }
@@ -6,6 +6,6 @@ import (
 )
 
 // Ex: /tmp/gorm.db
-func SQLiteDB(source string) (db *gorm.DB, err error) {
-	return gorm.Open(sqlite.Open(source), &gorm.Config{})
+func SQLiteDB(source string, opts ...gorm.Option) (db *gorm.DB, err error) {
+	return gorm.Open(sqlite.Open(source), opts...)
 }
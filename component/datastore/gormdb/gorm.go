@@ -8,6 +8,9 @@ import (
 
 	appctx "github.com/hoangtk0100/app-context"
 	"github.com/hoangtk0100/app-context/component/datastore/gormdb/dialects"
+	"github.com/hoangtk0100/app-context/component/tracing"
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/hoangtk0100/app-context/logadapter"
 	"github.com/spf13/pflag"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -30,6 +33,8 @@ type gormOpt struct {
 	maxOpenConns    int
 	maxIdleConns    int
 	connMaxIdleTime int
+	enableTracing   bool
+	metricsID       string
 }
 
 type gormDB struct {
@@ -52,46 +57,60 @@ func (gdb *gormDB) ID() string {
 	return gdb.id
 }
 
-func (gdb *gormDB) InitFlags() {
+func (gdb *gormDB) InitFlags(fs *pflag.FlagSet) {
 	prefix := gdb.prefix
 	if prefix != "" {
 		prefix += "-"
 	}
 
-	pflag.StringVar(
+	fs.StringVar(
 		&gdb.dbDriver,
 		"db-driver",
 		"postgres",
 		"Database driver (postgres | mysql | sqlite | mssql) - Default: postgres",
 	)
 
-	pflag.StringVar(
+	fs.StringVar(
 		&gdb.source,
 		fmt.Sprintf("%sdb-source", prefix),
 		"",
 		"Database connection string",
 	)
 
-	pflag.IntVar(
+	fs.IntVar(
 		&gdb.maxOpenConns,
 		fmt.Sprintf("%sdb-max-open-conns", prefix),
 		30,
 		"Maximum number of open connections to the database - Default: 30",
 	)
 
-	pflag.IntVar(
+	fs.IntVar(
 		&gdb.maxIdleConns,
 		fmt.Sprintf("%sdb-max-ide-conns", prefix),
 		10,
 		"Maximum number of database connections in the idle - Default: 10",
 	)
 
-	pflag.IntVar(
+	fs.IntVar(
 		&gdb.connMaxIdleTime,
 		fmt.Sprintf("%sdb-max-conn-ide-time", prefix),
 		3600,
 		"Maximum amount of time a connection may be idle in seconds - Default: 3600",
 	)
+
+	fs.BoolVar(
+		&gdb.enableTracing,
+		fmt.Sprintf("%sdb-enable-tracing", prefix),
+		false,
+		"Database enable tracing (OpenTelemetry) - Default: false",
+	)
+
+	fs.StringVar(
+		&gdb.metricsID,
+		fmt.Sprintf("%sdb-metrics-id", prefix),
+		"",
+		"Database metrics component ID, empty disables metrics - Ex: metrics",
+	)
 }
 
 func (gdb *gormDB) isDisabled() bool {
@@ -119,6 +138,20 @@ func (gdb *gormDB) Run(ac appctx.AppContext) error {
 		return err
 	}
 
+	if gdb.enableTracing {
+		if err := gdb.db.Use(tracing.GormPlugin()); err != nil {
+			gdb.logger.Error(err, "Cannot enable database tracing")
+			return err
+		}
+	}
+
+	if gdb.metricsID != "" {
+		if err := gdb.db.Use(ac.MustGet(gdb.metricsID).(core.MetricsComponent).GormPlugin(gdb.id)); err != nil {
+			gdb.logger.Error(err, "Cannot enable database metrics")
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -163,15 +196,19 @@ func getDBDriver(driver string) gormDBDriver {
 }
 
 func (gdb *gormDB) getDBConn(driver gormDBDriver) (dbConn *gorm.DB, err error) {
+	config := &gorm.Config{
+		Logger: logadapter.NewGormLogger(gdb.logger),
+	}
+
 	switch driver {
 	case gormDBDriverMySQL:
-		return dialects.MySqlDB(gdb.source)
+		return dialects.MySqlDB(gdb.source, config)
 	case gormDBDriverPostgres:
-		return dialects.PostgresDB(gdb.source)
+		return dialects.PostgresDB(gdb.source, config)
 	case gormDBDriverSQLite:
-		return dialects.SQLiteDB(gdb.source)
+		return dialects.SQLiteDB(gdb.source, config)
 	case gormDBDriverMSSQL:
-		return dialects.MSSqlDB(gdb.source)
+		return dialects.MSSqlDB(gdb.source, config)
 	}
 
 	return nil, nil
@@ -0,0 +1,9 @@
+package redisdb
+
+import "errors"
+
+var (
+	ErrModeNotSupported   = errors.New("redis mode not supported")
+	ErrMasterNameRequired = errors.New("redis sentinel master name required")
+	ErrInvalidCACert      = errors.New("redis TLS CA certificate invalid")
+)
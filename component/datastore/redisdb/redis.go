@@ -2,10 +2,17 @@ package redisdb
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	appctx "github.com/hoangtk0100/app-context"
+	"github.com/hoangtk0100/app-context/component/tracing"
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/hoangtk0100/app-context/logadapter"
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/pflag"
 )
@@ -13,19 +20,57 @@ import (
 const (
 	defaultPoolSize     = 0 // 0 is unlimited number of socket connections
 	defaultMinIdleConns = 10
+	defaultPingTimeout  = time.Second * 5
+
+	modeStandalone = "standalone"
+	modeSentinel   = "sentinel"
+	modeCluster    = "cluster"
 )
 
 // <user>:<password>@<host>:<port>/<db_number>
 type redisDBOpt struct {
-	prefix       string
-	url          string
-	poolSize     int
-	minIdleConns int
+	prefix        string
+	mode          string
+	url           string
+	addrs         []string
+	masterName    string
+	username      string
+	password      string
+	db            int
+	sentinelPass  string
+	poolSize      int
+	minIdleConns  int
+	enableTracing bool
+	metricsID     string
+	pingTimeout   time.Duration
+	*timeoutOpt
+	*tlsOpt
+}
+
+// 0 keeps the go-redis default, or the value of the URL query in standalone mode
+type timeoutOpt struct {
+	dialTimeout     time.Duration
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	poolTimeout     time.Duration
+	maxRetries      int
+	connMaxIdleTime time.Duration
+	connMaxLifetime time.Duration
+}
+
+type tlsOpt struct {
+	enabled            bool
+	caFile             string
+	certFile           string
+	keyFile            string
+	serverName         string
+	insecureSkipVerify bool
 }
 
 type redisDB struct {
 	id     string
-	client *redis.Client
+	client redis.UniversalClient
+	closed bool
 	logger appctx.Logger
 	*redisDBOpt
 }
@@ -35,8 +80,12 @@ func NewRedisDB(id, prefix string) *redisDB {
 		id: id,
 		redisDBOpt: &redisDBOpt{
 			prefix:       strings.TrimSpace(prefix),
+			mode:         modeStandalone,
 			poolSize:     defaultPoolSize,
 			minIdleConns: defaultMinIdleConns,
+			pingTimeout:  defaultPingTimeout,
+			timeoutOpt:   new(timeoutOpt),
+			tlsOpt:       new(tlsOpt),
 		},
 	}
 }
@@ -45,32 +94,174 @@ func (r *redisDB) ID() string {
 	return r.id
 }
 
-func (r *redisDB) InitFlags() {
+func (r *redisDB) InitFlags(fs *pflag.FlagSet) {
 	prefix := r.prefix
 	if prefix != "" {
 		prefix += "-"
 	}
 
-	pflag.StringVar(&r.url,
+	fs.StringVar(&r.mode,
+		fmt.Sprintf("%smode", prefix),
+		modeStandalone,
+		fmt.Sprintf("Redis mode (standalone | sentinel | cluster) - Default: %s", modeStandalone),
+	)
+
+	fs.StringVar(&r.url,
 		fmt.Sprintf("%surl", prefix),
 		"redis://localhost:6379",
-		"Redis connection-string - Ex: redis:<user>:<password>@<host>:<port>/<db_name>",
+		"Redis connection-string of standalone mode - Ex: redis:<user>:<password>@<host>:<port>/<db_name>",
+	)
+
+	fs.StringSliceVar(&r.addrs,
+		fmt.Sprintf("%saddrs", prefix),
+		nil,
+		"Redis sentinel or cluster node addresses - Ex: host1:26379,host2:26379",
 	)
 
-	pflag.IntVar(&r.poolSize,
+	fs.StringVar(&r.masterName,
+		fmt.Sprintf("%smaster-name", prefix),
+		"",
+		"Redis sentinel master name",
+	)
+
+	fs.StringVar(&r.username,
+		fmt.Sprintf("%susername", prefix),
+		"",
+		"Redis username of sentinel or cluster mode",
+	)
+
+	fs.StringVar(&r.password,
+		fmt.Sprintf("%spassword", prefix),
+		"",
+		"Redis password of sentinel or cluster mode",
+	)
+
+	fs.IntVar(&r.db,
+		fmt.Sprintf("%sdb", prefix),
+		0,
+		"Redis database number of sentinel mode - Default: 0",
+	)
+
+	fs.StringVar(&r.sentinelPass,
+		fmt.Sprintf("%ssentinel-password", prefix),
+		"",
+		"Redis password of the sentinel nodes",
+	)
+
+	fs.IntVar(&r.poolSize,
 		fmt.Sprintf("%spool-size", prefix),
 		defaultPoolSize,
 		"Redis pool size",
 	)
 
-	pflag.IntVar(&r.minIdleConns,
+	fs.IntVar(&r.minIdleConns,
 		fmt.Sprintf("%spool-min-idle", prefix),
 		defaultMinIdleConns,
 		"Redis min idle connections",
 	)
+
+	fs.DurationVar(&r.dialTimeout,
+		fmt.Sprintf("%sdial-timeout", prefix),
+		0,
+		"Redis dial timeout - Default: 5s",
+	)
+
+	fs.DurationVar(&r.readTimeout,
+		fmt.Sprintf("%sread-timeout", prefix),
+		0,
+		"Redis read timeout, -1 is no timeout - Default: 3s",
+	)
+
+	fs.DurationVar(&r.writeTimeout,
+		fmt.Sprintf("%swrite-timeout", prefix),
+		0,
+		"Redis write timeout, -1 is no timeout - Default: read timeout",
+	)
+
+	fs.DurationVar(&r.poolTimeout,
+		fmt.Sprintf("%spool-timeout", prefix),
+		0,
+		"Redis timeout to wait for a free connection of the pool - Default: read timeout + 1s",
+	)
+
+	fs.IntVar(&r.maxRetries,
+		fmt.Sprintf("%smax-retries", prefix),
+		0,
+		"Redis max retries of failed commands, -1 is no retry - Default: 3",
+	)
+
+	fs.DurationVar(&r.connMaxIdleTime,
+		fmt.Sprintf("%sconn-max-idle-time", prefix),
+		0,
+		"Redis max idle time of connections, -1 is no limit - Default: 30m",
+	)
+
+	fs.DurationVar(&r.connMaxLifetime,
+		fmt.Sprintf("%sconn-max-lifetime", prefix),
+		0,
+		"Redis max age of connections - Default: no limit",
+	)
+
+	fs.DurationVar(&r.pingTimeout,
+		fmt.Sprintf("%sping-timeout", prefix),
+		defaultPingTimeout,
+		fmt.Sprintf("Redis timeout of the connection test at startup - Default: %s", defaultPingTimeout),
+	)
+
+	fs.BoolVar(&r.enableTracing,
+		fmt.Sprintf("%senable-tracing", prefix),
+		false,
+		"Redis enable tracing (OpenTelemetry) - Default: false",
+	)
+
+	fs.StringVar(&r.metricsID,
+		fmt.Sprintf("%smetrics-id", prefix),
+		"",
+		"Redis metrics component ID, empty disables metrics - Ex: metrics",
+	)
+
+	fs.BoolVar(&r.tlsOpt.enabled,
+		fmt.Sprintf("%stls", prefix),
+		false,
+		"Redis enable TLS, also enabled by rediss:// URL - Default: false",
+	)
+
+	fs.StringVar(&r.caFile,
+		fmt.Sprintf("%stls-ca-file", prefix),
+		"",
+		"Redis TLS CA certificate file",
+	)
+
+	fs.StringVar(&r.certFile,
+		fmt.Sprintf("%stls-cert-file", prefix),
+		"",
+		"Redis TLS client certificate file",
+	)
+
+	fs.StringVar(&r.keyFile,
+		fmt.Sprintf("%stls-key-file", prefix),
+		"",
+		"Redis TLS client key file",
+	)
+
+	fs.StringVar(&r.serverName,
+		fmt.Sprintf("%stls-server-name", prefix),
+		"",
+		"Redis TLS server name - Default: host of the address",
+	)
+
+	fs.BoolVar(&r.insecureSkipVerify,
+		fmt.Sprintf("%stls-insecure-skip-verify", prefix),
+		false,
+		"Redis TLS skip verification of the server certificate - Default: false",
+	)
 }
 
 func (r *redisDB) isDisabled() bool {
+	if r.mode == modeSentinel || r.mode == modeCluster {
+		return len(r.addrs) == 0
+	}
+
 	return r.url == ""
 }
 
@@ -80,35 +271,179 @@ func (r *redisDB) Run(ac appctx.AppContext) error {
 	}
 
 	r.logger = ac.Logger(r.id)
+	logadapter.SetRedisLogger(r.logger)
 
-	opt, err := redis.ParseURL(r.url)
+	client, err := r.newClient()
 	if err != nil {
-		r.logger.Error(err, "Cannot parse Redis URL")
+		r.logger.Error(err, "Cannot create Redis client")
 		return err
 	}
 
-	opt.PoolSize = r.poolSize
-	opt.MinIdleConns = r.minIdleConns
-
-	client := redis.NewClient(opt)
+	if r.enableTracing {
+		client.AddHook(tracing.RedisHook())
+	}
 
 	// Test connection
-	if err := client.Ping(context.Background()).Err(); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), r.pingTimeout)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
 		r.logger.Error(err, "Cannot connect Redis ")
+		_ = client.Close()
 		return err
 	}
 
+	if r.metricsID != "" {
+		if err := ac.MustGet(r.metricsID).(core.MetricsComponent).RegisterRedis(r.id, client); err != nil {
+			r.logger.Error(err, "Cannot enable Redis metrics")
+			_ = client.Close()
+			return err
+		}
+	}
+
 	r.client = client
 
-	r.logger.Infof("Connect Redis on %s", opt.Addr)
+	r.logger.Infof("Connect Redis in %s mode", r.mode)
 
 	return nil
 }
 
+func (r *redisDB) newClient() (redis.UniversalClient, error) {
+	tlsConfig, err := r.getTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.mode {
+	case modeStandalone, "":
+		opt, err := redis.ParseURL(r.url)
+		if err != nil {
+			return nil, err
+		}
+
+		opt.PoolSize = r.poolSize
+		opt.MinIdleConns = r.minIdleConns
+		if tlsConfig != nil {
+			opt.TLSConfig = tlsConfig
+		}
+
+		setIfNotZero(&opt.DialTimeout, r.dialTimeout)
+		setIfNotZero(&opt.ReadTimeout, r.readTimeout)
+		setIfNotZero(&opt.WriteTimeout, r.writeTimeout)
+		setIfNotZero(&opt.PoolTimeout, r.poolTimeout)
+		setIfNotZero(&opt.MaxRetries, r.maxRetries)
+		setIfNotZero(&opt.ConnMaxIdleTime, r.connMaxIdleTime)
+		setIfNotZero(&opt.ConnMaxLifetime, r.connMaxLifetime)
+		// Respect the deadlines of the contexts, ex: the ping timeout
+		opt.ContextTimeoutEnabled = true
+
+		return redis.NewClient(opt), nil
+
+	case modeSentinel:
+		if r.masterName == "" {
+			return nil, ErrMasterNameRequired
+		}
+
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       r.masterName,
+			SentinelAddrs:    r.addrs,
+			SentinelPassword: r.sentinelPass,
+			Username:         r.username,
+			Password:         r.password,
+			DB:               r.db,
+			PoolSize:         r.poolSize,
+			MinIdleConns:     r.minIdleConns,
+			DialTimeout:      r.dialTimeout,
+			ReadTimeout:      r.readTimeout,
+			WriteTimeout:     r.writeTimeout,
+			PoolTimeout:      r.poolTimeout,
+			MaxRetries:       r.maxRetries,
+			ConnMaxIdleTime:  r.connMaxIdleTime,
+			ConnMaxLifetime:  r.connMaxLifetime,
+			TLSConfig:        tlsConfig,
+			// Respect the deadlines of the contexts, ex: the ping timeout
+			ContextTimeoutEnabled: true,
+		}), nil
+
+	case modeCluster:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:           r.addrs,
+			Username:        r.username,
+			Password:        r.password,
+			PoolSize:        r.poolSize,
+			MinIdleConns:    r.minIdleConns,
+			DialTimeout:     r.dialTimeout,
+			ReadTimeout:     r.readTimeout,
+			WriteTimeout:    r.writeTimeout,
+			PoolTimeout:     r.poolTimeout,
+			MaxRetries:      r.maxRetries,
+			ConnMaxIdleTime: r.connMaxIdleTime,
+			ConnMaxLifetime: r.connMaxLifetime,
+			TLSConfig:       tlsConfig,
+			// Respect the deadlines of the contexts, ex: the ping timeout
+			ContextTimeoutEnabled: true,
+		}), nil
+	}
+
+	return nil, ErrModeNotSupported
+}
+
+// getTLSConfig returns nil if TLS is disabled
+func (r *redisDB) getTLSConfig() (*tls.Config, error) {
+	if !r.tlsOpt.enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         r.serverName,
+		InsecureSkipVerify: r.insecureSkipVerify,
+	}
+
+	if r.caFile != "" {
+		ca, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, ErrInvalidCACert
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if r.certFile != "" || r.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// Stop closes the client, which is kept so the late callers (ex: lock renewals) get redis.ErrClosed
 func (r *redisDB) Stop() error {
-	return nil
+	if r.client == nil || r.closed {
+		return nil
+	}
+
+	r.closed = true
+
+	return r.client.Close()
+}
+
+func setIfNotZero[T comparable](dst *T, value T) {
+	var zero T
+	if value != zero {
+		*dst = value
+	}
 }
 
-func (r *redisDB) GetDB() *redis.Client {
+func (r *redisDB) GetDB() redis.UniversalClient {
 	return r.client
 }
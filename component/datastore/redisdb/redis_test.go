@@ -0,0 +1,106 @@
+package redisdb
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+type testAppContext struct {
+	appctx.AppContext
+}
+
+func (testAppContext) Logger(prefix string) appctx.Logger {
+	return appctx.NewAppLogger("test", io.Discard).GetLogger(prefix)
+}
+
+// closeClient stops the background dialing of the client at the end of the test
+func closeClient(t *testing.T, client redis.UniversalClient) {
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+}
+
+func TestNewClient(t *testing.T) {
+	r := NewRedisDB("redis", "")
+	r.url = "rediss://localhost:6379/1"
+
+	client, err := r.newClient()
+	require.NoError(t, err)
+	closeClient(t, client)
+	require.IsType(t, &redis.Client{}, client)
+	require.NotNil(t, client.(*redis.Client).Options().TLSConfig)
+
+	r.mode = modeSentinel
+	r.addrs = []string{"localhost:26379"}
+	_, err = r.newClient()
+	require.ErrorIs(t, err, ErrMasterNameRequired)
+
+	r.masterName = "master"
+	client, err = r.newClient()
+	require.NoError(t, err)
+	closeClient(t, client)
+	require.IsType(t, &redis.Client{}, client)
+
+	r.mode = modeCluster
+	r.tlsOpt.enabled = true
+	r.serverName = "redis.internal"
+	client, err = r.newClient()
+	require.NoError(t, err)
+	closeClient(t, client)
+	require.IsType(t, &redis.ClusterClient{}, client)
+	require.Equal(t, "redis.internal", client.(*redis.ClusterClient).Options().TLSConfig.ServerName)
+
+	r.mode = "replica"
+	_, err = r.newClient()
+	require.ErrorIs(t, err, ErrModeNotSupported)
+
+	r.caFile = "missing.pem"
+	_, err = r.newClient()
+	require.Error(t, err)
+}
+
+func TestRunAndStop(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	r := NewRedisDB("redis", "")
+	r.url = "redis://" + server.Addr()
+	r.readTimeout = time.Second
+	r.connMaxLifetime = time.Minute
+
+	require.NoError(t, r.Run(testAppContext{}))
+	require.Equal(t, time.Second, r.GetDB().(*redis.Client).Options().ReadTimeout)
+	require.Equal(t, time.Minute, r.GetDB().(*redis.Client).Options().ConnMaxLifetime)
+
+	client := r.GetDB()
+	require.NoError(t, r.Stop())
+	require.Same(t, client, r.GetDB())
+	require.ErrorIs(t, client.Ping(context.Background()).Err(), redis.ErrClosed)
+	require.NoError(t, r.Stop())
+}
+
+func TestRunPingTimeout(t *testing.T) {
+	// Accepts connections but never replies
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	r := NewRedisDB("redis", "")
+	r.url = "redis://" + listener.Addr().String()
+	r.readTimeout = -1
+	r.maxRetries = -1
+	r.pingTimeout = time.Millisecond * 100
+
+	startTime := time.Now()
+	require.Error(t, r.Run(testAppContext{}))
+	require.Less(t, time.Since(startTime), time.Second)
+}
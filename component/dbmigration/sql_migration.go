@@ -35,14 +35,14 @@ func (m *dbMigrator) ID() string {
 	return m.id
 }
 
-func (m *dbMigrator) InitFlags() {
-	pflag.StringVar(&m.dbSource,
+func (m *dbMigrator) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&m.dbSource,
 		"db-migration-source",
 		"",
 		"Database connection string",
 	)
 
-	pflag.StringVar(&m.migrationURL,
+	fs.StringVar(&m.migrationURL,
 		"db-migration-url",
 		"",
 		"Database migration url - Default: file://migration",
@@ -0,0 +1,21 @@
+package dedup
+
+import (
+	"context"
+	"time"
+
+	"github.com/hoangtk0100/app-context/core"
+)
+
+// Store records the processed message keys, it implements core.DedupStore
+type Store interface {
+	// Claim leases the key to owner for ttl, core.DedupProcessing if it is leased to another owner
+	// and core.DedupDone if it is completed
+	Claim(ctx context.Context, key, owner string, ttl time.Duration) (core.DedupState, error)
+	// Extend sets the ttl of the lease, ex: renewed while the message is processed. Ignored if owner lost the lease
+	Extend(ctx context.Context, key, owner string, ttl time.Duration) error
+	// Complete marks the leased key as processed for ttl. Ignored if owner lost the lease
+	Complete(ctx context.Context, key, owner string, ttl time.Duration) error
+	// Release forgets the leased key, ex: the processing failed and the message is redelivered. Ignored if owner lost the lease
+	Release(ctx context.Context, key, owner string) error
+}
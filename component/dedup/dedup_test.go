@@ -0,0 +1,194 @@
+package dedup
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hoangtk0100/app-context/component/datastore/gormdb/dialects"
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type redisDB struct {
+	client redis.UniversalClient
+}
+
+func (db *redisDB) GetDB() redis.UniversalClient {
+	return db.client
+}
+
+type gormDB struct {
+	db *gorm.DB
+}
+
+func (db *gormDB) GetDB() *gorm.DB {
+	return db.db
+}
+
+func testStores(t *testing.T) map[string]Store {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	db, err := dialects.SQLiteDB(filepath.Join(t.TempDir(), "dedup.db"))
+	require.NoError(t, err)
+
+	return map[string]Store{
+		"local": NewLocalStore(0),
+		"redis": NewRedisStore(&redisDB{client: client}),
+		"gorm":  NewGormStore(&gormDB{db: db}, ""),
+	}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			state, err := store.Claim(ctx, "order.created:1", "a", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, core.DedupClaimed, state)
+
+			// Leased to a until completed or released
+			state, err = store.Claim(ctx, "order.created:1", "b", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, core.DedupProcessing, state)
+
+			require.NoError(t, store.Release(ctx, "order.created:1", "a"))
+
+			state, err = store.Claim(ctx, "order.created:1", "b", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, core.DedupClaimed, state)
+
+			require.NoError(t, store.Complete(ctx, "order.created:1", "b", time.Minute))
+
+			state, err = store.Claim(ctx, "order.created:1", "c", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, core.DedupDone, state)
+
+			// A completed key is not released
+			require.NoError(t, store.Release(ctx, "order.created:1", "b"))
+
+			state, err = store.Claim(ctx, "order.created:1", "c", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, core.DedupDone, state)
+		})
+	}
+}
+
+func TestStoreOwner(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			state, err := store.Claim(ctx, "order.created:1", "a", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, core.DedupClaimed, state)
+
+			// Another owner cannot release, extend or complete the lease
+			require.NoError(t, store.Release(ctx, "order.created:1", "b"))
+			require.NoError(t, store.Extend(ctx, "order.created:1", "b", time.Minute))
+			require.NoError(t, store.Complete(ctx, "order.created:1", "b", time.Minute))
+
+			state, err = store.Claim(ctx, "order.created:1", "b", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, core.DedupProcessing, state)
+		})
+	}
+}
+
+func TestStoreExpired(t *testing.T) {
+	ctx := context.Background()
+
+	// miniredis does not expire keys by itself
+	stores := testStores(t)
+	delete(stores, "redis")
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			state, err := store.Claim(ctx, "order.created:1", "a", time.Millisecond*50)
+			require.NoError(t, err)
+			require.Equal(t, core.DedupClaimed, state)
+
+			time.Sleep(time.Millisecond * 100)
+
+			state, err = store.Claim(ctx, "order.created:1", "b", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, core.DedupClaimed, state)
+
+			// The expired lease of a is reclaimed by b
+			require.NoError(t, store.Release(ctx, "order.created:1", "a"))
+
+			state, err = store.Claim(ctx, "order.created:1", "c", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, core.DedupProcessing, state)
+		})
+	}
+}
+
+func TestStoreExtend(t *testing.T) {
+	ctx := context.Background()
+
+	stores := testStores(t)
+	delete(stores, "redis")
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			state, err := store.Claim(ctx, "order.created:1", "a", time.Millisecond*50)
+			require.NoError(t, err)
+			require.Equal(t, core.DedupClaimed, state)
+			require.NoError(t, store.Extend(ctx, "order.created:1", "a", time.Minute))
+
+			time.Sleep(time.Millisecond * 100)
+
+			state, err = store.Claim(ctx, "order.created:1", "b", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, core.DedupProcessing, state)
+		})
+	}
+}
+
+func TestLocalStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocalStore(2)
+
+	for _, key := range []string{"1", "2", "3"} {
+		state, err := store.Claim(ctx, key, "a", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, core.DedupClaimed, state)
+	}
+
+	// The least recently claimed key is evicted
+	state, err := store.Claim(ctx, "1", "a", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, core.DedupClaimed, state)
+
+	state, err = store.Claim(ctx, "3", "a", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, core.DedupProcessing, state)
+}
+
+func TestGormStoreDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := dialects.SQLiteDB(filepath.Join(t.TempDir(), "dedup.db"))
+	require.NoError(t, err)
+
+	store := NewGormStore(&gormDB{db: db}, "")
+	_, err = store.Claim(ctx, "1", "a", -time.Second)
+	require.NoError(t, err)
+	_, err = store.Claim(ctx, "2", "a", time.Minute)
+	require.NoError(t, err)
+
+	require.NoError(t, store.DeleteExpired(ctx))
+
+	var count int64
+	require.NoError(t, db.Table(defaultTable).Count(&count).Error)
+	require.Equal(t, int64(1), count)
+}
@@ -0,0 +1,140 @@
+package dedup
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hoangtk0100/app-context/core"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultTable = "dedup_keys"
+
+// dedupKey is a row of the store table
+type dedupKey struct {
+	Key       string    `gorm:"column:message_key;primaryKey;size:255"`
+	Owner     string    `gorm:"column:owner;not null;default:'';size:64"`
+	Done      bool      `gorm:"column:done;not null;default:false"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null;index"`
+}
+
+type gormStore struct {
+	gormDB     core.GormDBComponent
+	table      string
+	migrate    sync.Once
+	migrateErr error
+}
+
+// NewGormStore creates a store shared by instances in a table created on first use, empty table is "dedup_keys".
+// Expired rows are reclaimed by Claim, DeleteExpired removes them
+func NewGormStore(gormDB core.GormDBComponent, table string) *gormStore {
+	if table == "" {
+		table = defaultTable
+	}
+
+	return &gormStore{
+		gormDB: gormDB,
+		table:  table,
+	}
+}
+
+func (s *gormStore) setup() error {
+	s.migrate.Do(func() {
+		s.migrateErr = s.gormDB.GetDB().Table(s.table).AutoMigrate(&dedupKey{})
+	})
+
+	return s.migrateErr
+}
+
+func (s *gormStore) db(ctx context.Context) *gorm.DB {
+	return s.gormDB.GetDB().WithContext(ctx).Table(s.table)
+}
+
+func (s *gormStore) Claim(ctx context.Context, key, owner string, ttl time.Duration) (core.DedupState, error) {
+	if err := s.setup(); err != nil {
+		return core.DedupClaimed, err
+	}
+
+	now := time.Now().UTC()
+
+	result := s.db(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&dedupKey{
+		Key:       key,
+		Owner:     owner,
+		ExpiresAt: now.Add(ttl),
+	})
+	if result.Error != nil {
+		return core.DedupClaimed, result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		return core.DedupClaimed, nil
+	}
+
+	// Recorded, reclaim it only if expired
+	result = s.db(ctx).Where("message_key = ? AND expires_at <= ?", key, now).Updates(map[string]interface{}{
+		"owner":      owner,
+		"done":       false,
+		"expires_at": now.Add(ttl),
+	})
+	if result.Error != nil {
+		return core.DedupClaimed, result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		return core.DedupClaimed, nil
+	}
+
+	var recorded dedupKey
+	if err := s.db(ctx).Where("message_key = ?", key).Take(&recorded).Error; err != nil {
+		return core.DedupClaimed, err
+	}
+
+	if recorded.Done {
+		return core.DedupDone, nil
+	}
+
+	return core.DedupProcessing, nil
+}
+
+// leased selects the key if it is leased to owner
+func (s *gormStore) leased(ctx context.Context, key, owner string) *gorm.DB {
+	return s.db(ctx).Where("message_key = ? AND owner = ? AND done = ? AND expires_at > ?", key, owner, false, time.Now().UTC())
+}
+
+func (s *gormStore) Extend(ctx context.Context, key, owner string, ttl time.Duration) error {
+	if err := s.setup(); err != nil {
+		return err
+	}
+
+	return s.leased(ctx, key, owner).Update("expires_at", time.Now().UTC().Add(ttl)).Error
+}
+
+func (s *gormStore) Complete(ctx context.Context, key, owner string, ttl time.Duration) error {
+	if err := s.setup(); err != nil {
+		return err
+	}
+
+	return s.leased(ctx, key, owner).Updates(map[string]interface{}{
+		"done":       true,
+		"expires_at": time.Now().UTC().Add(ttl),
+	}).Error
+}
+
+func (s *gormStore) Release(ctx context.Context, key, owner string) error {
+	if err := s.setup(); err != nil {
+		return err
+	}
+
+	return s.leased(ctx, key, owner).Delete(&dedupKey{}).Error
+}
+
+// DeleteExpired removes the expired keys, ex: periodically
+func (s *gormStore) DeleteExpired(ctx context.Context) error {
+	if err := s.setup(); err != nil {
+		return err
+	}
+
+	return s.db(ctx).Where("expires_at <= ?", time.Now().UTC()).Delete(&dedupKey{}).Error
+}
@@ -0,0 +1,126 @@
+package dedup
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hoangtk0100/app-context/core"
+)
+
+const defaultLocalSize = 10000
+
+type localEntry struct {
+	key       string
+	owner     string
+	done      bool
+	expiresAt time.Time
+}
+
+// In-memory LRU, the least recently claimed keys are evicted when full
+type localStore struct {
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	locker  *sync.Mutex
+}
+
+// NewLocalStore creates an in-memory store for a single instance, size <= 0 is 10000
+func NewLocalStore(size int) *localStore {
+	if size <= 0 {
+		size = defaultLocalSize
+	}
+
+	return &localStore{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		locker:  new(sync.Mutex),
+	}
+}
+
+func (s *localStore) Claim(ctx context.Context, key, owner string, ttl time.Duration) (core.DedupState, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	now := time.Now()
+	if element, ok := s.entries[key]; ok {
+		entry := element.Value.(*localEntry)
+		if now.Before(entry.expiresAt) {
+			if entry.done {
+				return core.DedupDone, nil
+			}
+
+			return core.DedupProcessing, nil
+		}
+
+		entry.owner = owner
+		entry.done = false
+		entry.expiresAt = now.Add(ttl)
+		s.order.MoveToFront(element)
+
+		return core.DedupClaimed, nil
+	}
+
+	s.entries[key] = s.order.PushFront(&localEntry{key: key, owner: owner, expiresAt: now.Add(ttl)})
+
+	for s.order.Len() > s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*localEntry).key)
+	}
+
+	return core.DedupClaimed, nil
+}
+
+// leased returns the entry of key if it is leased to owner
+func (s *localStore) leased(key, owner string) (*list.Element, bool) {
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*localEntry)
+	if entry.done || entry.owner != owner || !time.Now().Before(entry.expiresAt) {
+		return nil, false
+	}
+
+	return element, true
+}
+
+func (s *localStore) Extend(ctx context.Context, key, owner string, ttl time.Duration) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if element, ok := s.leased(key, owner); ok {
+		element.Value.(*localEntry).expiresAt = time.Now().Add(ttl)
+	}
+
+	return nil
+}
+
+func (s *localStore) Complete(ctx context.Context, key, owner string, ttl time.Duration) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if element, ok := s.leased(key, owner); ok {
+		entry := element.Value.(*localEntry)
+		entry.done = true
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	return nil
+}
+
+func (s *localStore) Release(ctx context.Context, key, owner string) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if element, ok := s.leased(key, owner); ok {
+		s.order.Remove(element)
+		delete(s.entries, key)
+	}
+
+	return nil
+}
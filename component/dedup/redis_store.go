@@ -0,0 +1,88 @@
+package dedup
+
+import (
+	"context"
+	"time"
+
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix = "dedup:"
+	// Value of a processed key, the leased keys hold the owner
+	doneValue = "done"
+)
+
+var (
+	// Returns 0 if claimed, 1 if leased to another owner, 2 if done
+	claimScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 0
+end
+if redis.call("GET", KEYS[1]) == ARGV[3] then
+	return 2
+end
+return 1
+`)
+
+	extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+	completeScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[3], "PX", ARGV[2])
+	return 1
+end
+return 0
+`)
+
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+)
+
+type redisStore struct {
+	redisDB core.RedisDBComponent
+}
+
+// NewRedisStore creates a store shared by instances, the keys are "dedup:<key>" with the TTL
+// holding the owner of the lease, "done" once processed
+func NewRedisStore(redisDB core.RedisDBComponent) *redisStore {
+	return &redisStore{redisDB: redisDB}
+}
+
+func (s *redisStore) Claim(ctx context.Context, key, owner string, ttl time.Duration) (core.DedupState, error) {
+	state, err := claimScript.Run(ctx, s.redisDB.GetDB(), []string{keyPrefix + key}, owner, ttl.Milliseconds(), doneValue).Int()
+	if err != nil {
+		return core.DedupClaimed, err
+	}
+
+	switch state {
+	case 0:
+		return core.DedupClaimed, nil
+	case 2:
+		return core.DedupDone, nil
+	default:
+		return core.DedupProcessing, nil
+	}
+}
+
+func (s *redisStore) Extend(ctx context.Context, key, owner string, ttl time.Duration) error {
+	return extendScript.Run(ctx, s.redisDB.GetDB(), []string{keyPrefix + key}, owner, ttl.Milliseconds()).Err()
+}
+
+func (s *redisStore) Complete(ctx context.Context, key, owner string, ttl time.Duration) error {
+	return completeScript.Run(ctx, s.redisDB.GetDB(), []string{keyPrefix + key}, owner, ttl.Milliseconds(), doneValue).Err()
+}
+
+func (s *redisStore) Release(ctx context.Context, key, owner string) error {
+	return releaseScript.Run(ctx, s.redisDB.GetDB(), []string{keyPrefix + key}, owner).Err()
+}
@@ -0,0 +1,9 @@
+package lock
+
+import "errors"
+
+var (
+	ErrNotAcquired = errors.New("lock not acquired")
+	ErrNotHeld     = errors.New("lock not held")
+	ErrInvalidTTL  = errors.New("lock ttl must be at least 10ms")
+)
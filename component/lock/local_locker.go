@@ -0,0 +1,85 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type lease struct {
+	owner     string
+	expiresAt time.Time
+}
+
+type localStore struct {
+	leases map[string]*lease
+	tokens map[string]int64
+	locker *sync.Mutex
+}
+
+// NewLocalLocker creates an in-memory locker for tests and single instance
+func NewLocalLocker() *locker {
+	return &locker{
+		store: &localStore{
+			leases: make(map[string]*lease),
+			tokens: make(map[string]int64),
+			locker: new(sync.Mutex),
+		},
+	}
+}
+
+func (s *localStore) acquire(ctx context.Context, key, owner string, ttl time.Duration) (int64, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if _, ok := s.get(key); ok {
+		return 0, nil
+	}
+
+	s.leases[key] = &lease{
+		owner:     owner,
+		expiresAt: time.Now().Add(ttl),
+	}
+	s.tokens[key]++
+
+	return s.tokens[key], nil
+}
+
+func (s *localStore) renew(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	l, ok := s.get(key)
+	if !ok || l.owner != owner {
+		return false, nil
+	}
+
+	l.expiresAt = time.Now().Add(ttl)
+
+	return true, nil
+}
+
+func (s *localStore) release(ctx context.Context, key, owner string) (bool, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	l, ok := s.get(key)
+	if !ok || l.owner != owner {
+		return false, nil
+	}
+
+	delete(s.leases, key)
+
+	return true, nil
+}
+
+// get returns the lease if not expired
+func (s *localStore) get(key string) (*lease, bool) {
+	l, ok := s.leases[key]
+	if ok && !time.Now().Before(l.expiresAt) {
+		delete(s.leases, key)
+		return nil, false
+	}
+
+	return l, ok
+}
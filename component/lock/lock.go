@@ -0,0 +1,231 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultTTL           = time.Second * 10
+	defaultRetryInterval = time.Millisecond * 100
+	minTTL               = time.Millisecond * 10
+)
+
+type Locker interface {
+	Acquire(ctx context.Context, key string, opts ...Option) (*Lock, error)
+	TryAcquire(ctx context.Context, key string, opts ...Option) (*Lock, error)
+}
+
+// store keeps the leases, the owner is a unique value per lock acquisition
+type store interface {
+	// acquire returns the fencing token, 0 if the lock is held by another owner
+	acquire(ctx context.Context, key, owner string, ttl time.Duration) (int64, error)
+	renew(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	release(ctx context.Context, key, owner string) (bool, error)
+}
+
+type Option func(*lockOpt)
+
+type lockOpt struct {
+	ttl           time.Duration
+	retryInterval time.Duration
+	autoRenew     bool
+}
+
+// WithTTL sets the lease duration, at least 10ms - Default: 10s
+func WithTTL(ttl time.Duration) Option {
+	return func(opt *lockOpt) {
+		opt.ttl = ttl
+	}
+}
+
+// WithRetryInterval sets the interval between attempts of Acquire - Default: 100ms
+func WithRetryInterval(interval time.Duration) Option {
+	return func(opt *lockOpt) {
+		opt.retryInterval = interval
+	}
+}
+
+// WithAutoRenew renews the lease every ttl/3 until released - Default: true
+func WithAutoRenew(autoRenew bool) Option {
+	return func(opt *lockOpt) {
+		opt.autoRenew = autoRenew
+	}
+}
+
+type locker struct {
+	store store
+}
+
+// Acquire blocks until the lock is acquired or ctx is done
+func (l *locker) Acquire(ctx context.Context, key string, opts ...Option) (*Lock, error) {
+	opt := getOpt(opts)
+
+	for {
+		lock, err := l.tryAcquire(ctx, key, opt)
+		if err != ErrNotAcquired {
+			return lock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(opt.retryInterval):
+		}
+	}
+}
+
+// TryAcquire returns ErrNotAcquired if the lock is held by another owner
+func (l *locker) TryAcquire(ctx context.Context, key string, opts ...Option) (*Lock, error) {
+	return l.tryAcquire(ctx, key, getOpt(opts))
+}
+
+func (l *locker) tryAcquire(ctx context.Context, key string, opt *lockOpt) (*Lock, error) {
+	if opt.ttl < minTTL {
+		return nil, ErrInvalidTTL
+	}
+
+	owner := uuid.NewString()
+	acquiredAt := time.Now()
+
+	token, err := l.store.acquire(ctx, key, owner, opt.ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if token == 0 {
+		return nil, ErrNotAcquired
+	}
+
+	lock := &Lock{
+		key:      key,
+		owner:    owner,
+		token:    token,
+		ttl:      opt.ttl,
+		store:    l.store,
+		lostChan: make(chan struct{}),
+		stopChan: make(chan struct{}),
+	}
+
+	if opt.autoRenew {
+		go lock.renewLoop(acquiredAt)
+	} else {
+		go lock.expireAt(acquiredAt.Add(opt.ttl))
+	}
+
+	return lock, nil
+}
+
+func getOpt(opts []Option) *lockOpt {
+	opt := &lockOpt{
+		ttl:           defaultTTL,
+		retryInterval: defaultRetryInterval,
+		autoRenew:     true,
+	}
+
+	for _, o := range opts {
+		o(opt)
+	}
+
+	return opt
+}
+
+type Lock struct {
+	key      string
+	owner    string
+	token    int64
+	ttl      time.Duration
+	store    store
+	lostChan chan struct{}
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Token is the fencing token, it increases on every acquisition of the key.
+// Pass it to the protected resource to reject writes of stale lock holders
+func (l *Lock) Token() int64 {
+	return l.token
+}
+
+// Lost is closed when the lease is taken by another owner or expires without renewal,
+// without auto renew it is closed once the TTL is over unless released before
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lostChan
+}
+
+// Release returns ErrNotHeld if the lease already expired or was taken by another owner
+func (l *Lock) Release(ctx context.Context) error {
+	l.stopOnce.Do(func() {
+		close(l.stopChan)
+	})
+
+	ok, err := l.store.release(ctx, l.key, l.owner)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrNotHeld
+	}
+
+	return nil
+}
+
+// expireAt closes the lost channel at expiresAt, the lease is not renewed
+func (l *Lock) expireAt(expiresAt time.Time) {
+	timer := time.NewTimer(time.Until(expiresAt))
+	defer timer.Stop()
+
+	select {
+	case <-l.stopChan:
+	case <-timer.C:
+		close(l.lostChan)
+	}
+}
+
+// renewLoop retries on error until the lease expires, lastRenew is the time the last successful renewal was sent
+func (l *Lock) renewLoop(lastRenew time.Time) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		expiresAt := lastRenew.Add(l.ttl)
+
+		select {
+		case <-l.stopChan:
+			return
+		case <-time.After(time.Until(expiresAt)):
+			close(l.lostChan)
+			return
+		case <-ticker.C:
+			start := time.Now()
+
+			deadline := start.Add(l.ttl / 3)
+			if expiresAt.Before(deadline) {
+				deadline = expiresAt
+			}
+
+			ctx, cancel := context.WithDeadline(context.Background(), deadline)
+			ok, err := l.store.renew(ctx, l.key, l.owner, l.ttl)
+			cancel()
+
+			if err != nil {
+				continue
+			}
+
+			if !ok {
+				close(l.lostChan)
+				return
+			}
+
+			lastRenew = start
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+type redisDB struct {
+	client redis.UniversalClient
+}
+
+func (db *redisDB) GetDB() redis.UniversalClient {
+	return db.client
+}
+
+func testLockers(t *testing.T) map[string]Locker {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return map[string]Locker{
+		"local": NewLocalLocker(),
+		"redis": NewRedisLocker(&redisDB{client: client}),
+	}
+}
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+
+	for name, locker := range testLockers(t) {
+		t.Run(name, func(t *testing.T) {
+			lock, err := locker.TryAcquire(ctx, "order:1")
+			require.NoError(t, err)
+			require.Equal(t, int64(1), lock.Token())
+
+			_, err = locker.TryAcquire(ctx, "order:1")
+			require.ErrorIs(t, err, ErrNotAcquired)
+
+			timeoutCtx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+			defer cancel()
+			_, err = locker.Acquire(timeoutCtx, "order:1", WithRetryInterval(time.Millisecond*10))
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+
+			require.NoError(t, lock.Release(ctx))
+			require.ErrorIs(t, lock.Release(ctx), ErrNotHeld)
+
+			// The fencing token increases on every acquisition
+			lock, err = locker.TryAcquire(ctx, "order:1")
+			require.NoError(t, err)
+			require.Equal(t, int64(2), lock.Token())
+			require.NoError(t, lock.Release(ctx))
+		})
+	}
+}
+
+func TestLockMutualExclusion(t *testing.T) {
+	ctx := context.Background()
+
+	for name, locker := range testLockers(t) {
+		t.Run(name, func(t *testing.T) {
+			var (
+				wg      sync.WaitGroup
+				holders int
+				tokens  []int64
+				mu      sync.Mutex
+			)
+
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					lock, err := locker.Acquire(ctx, "counter", WithRetryInterval(time.Millisecond))
+					require.NoError(t, err)
+
+					mu.Lock()
+					holders++
+					require.Equal(t, 1, holders)
+					tokens = append(tokens, lock.Token())
+					mu.Unlock()
+
+					time.Sleep(time.Millisecond * 5)
+
+					mu.Lock()
+					holders--
+					mu.Unlock()
+
+					require.NoError(t, lock.Release(ctx))
+				}()
+			}
+
+			wg.Wait()
+			require.ElementsMatch(t, []int64{1, 2, 3, 4, 5}, tokens)
+		})
+	}
+}
+
+func TestLockAutoRenew(t *testing.T) {
+	ctx := context.Background()
+	locker := NewLocalLocker()
+
+	lock, err := locker.TryAcquire(ctx, "job", WithTTL(time.Millisecond*30))
+	require.NoError(t, err)
+
+	// Held after several lease durations
+	time.Sleep(time.Millisecond * 100)
+	_, err = locker.TryAcquire(ctx, "job")
+	require.ErrorIs(t, err, ErrNotAcquired)
+	require.NoError(t, lock.Release(ctx))
+
+	expiring, err := locker.TryAcquire(ctx, "job", WithTTL(time.Millisecond*30), WithAutoRenew(false))
+	require.NoError(t, err)
+
+	// Lost once the TTL is over
+	select {
+	case <-expiring.Lost():
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("lock not lost after the TTL")
+	}
+
+	lock, err = locker.TryAcquire(ctx, "job")
+	require.NoError(t, err)
+	require.ErrorIs(t, expiring.Release(ctx), ErrNotHeld)
+	require.NoError(t, lock.Release(ctx))
+
+	// Released before the TTL, not lost
+	released, err := locker.TryAcquire(ctx, "report", WithTTL(time.Millisecond*30), WithAutoRenew(false))
+	require.NoError(t, err)
+	require.NoError(t, released.Release(ctx))
+
+	select {
+	case <-released.Lost():
+		t.Fatal("released lock lost")
+	case <-time.After(time.Millisecond * 50):
+	}
+}
+
+// unavailableStore fails to renew, ex: Redis is down
+type unavailableStore struct {
+	store
+}
+
+func (s *unavailableStore) renew(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestLockLostOnExpiry(t *testing.T) {
+	ctx := context.Background()
+	locker := &locker{store: &unavailableStore{store: NewLocalLocker().store}}
+
+	lock, err := locker.TryAcquire(ctx, "job", WithTTL(time.Millisecond*30))
+	require.NoError(t, err)
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("lock not lost after the lease expired")
+	}
+
+	_, err = locker.TryAcquire(ctx, "job", WithTTL(0))
+	require.ErrorIs(t, err, ErrInvalidTTL)
+
+	_, err = locker.Acquire(ctx, "job", WithTTL(time.Millisecond))
+	require.ErrorIs(t, err, ErrInvalidTTL)
+}
+
+func TestRedisLockClientClosed(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	locker := NewRedisLocker(&redisDB{client: client})
+
+	lock, err := locker.TryAcquire(ctx, "job", WithTTL(time.Millisecond*30))
+	require.NoError(t, err)
+
+	// The Redis component keeps the closed client after Stop, so the renewals fail until the lease expires
+	require.NoError(t, client.Close())
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("lock not lost after the client was closed")
+	}
+
+	require.ErrorIs(t, lock.Release(ctx), redis.ErrClosed)
+}
@@ -0,0 +1,66 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix   = "lock:"
+	fenceSuffix = ":fence"
+)
+
+// lockKey uses a hash tag, so the lock and fence keys are in the same cluster slot
+func lockKey(key string) string {
+	return keyPrefix + "{" + key + "}"
+}
+
+var (
+	acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+	renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+)
+
+type redisStore struct {
+	redisDB core.RedisDBComponent
+}
+
+// NewRedisLocker creates a distributed locker, the fencing tokens are kept in "lock:{<key>}:fence"
+func NewRedisLocker(redisDB core.RedisDBComponent) *locker {
+	return &locker{
+		store: &redisStore{redisDB: redisDB},
+	}
+}
+
+func (s *redisStore) acquire(ctx context.Context, key, owner string, ttl time.Duration) (int64, error) {
+	lockKey := lockKey(key)
+	return acquireScript.Run(ctx, s.redisDB.GetDB(), []string{lockKey, lockKey + fenceSuffix}, owner, ttl.Milliseconds()).Int64()
+}
+
+func (s *redisStore) renew(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	return renewScript.Run(ctx, s.redisDB.GetDB(), []string{lockKey(key)}, owner, ttl.Milliseconds()).Bool()
+}
+
+func (s *redisStore) release(ctx context.Context, key, owner string) (bool, error) {
+	return releaseScript.Run(ctx, s.redisDB.GetDB(), []string{lockKey(key)}, owner).Bool()
+}
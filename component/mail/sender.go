@@ -5,6 +5,7 @@ import (
 	"net/smtp"
 
 	appctx "github.com/hoangtk0100/app-context"
+	"github.com/hoangtk0100/app-context/core"
 	"github.com/jordan-wright/email"
 	"github.com/spf13/pflag"
 )
@@ -17,6 +18,7 @@ const (
 type emailOpt struct {
 	smtpServer string
 	smtpPort   int
+	metricsID  string
 }
 
 type emailSender struct {
@@ -25,6 +27,8 @@ type emailSender struct {
 	address  string
 	password string
 	logger   appctx.Logger
+	// sender sends through SMTP, wrapped by the metrics component if enabled
+	sender core.EmailComponent
 	*emailOpt
 }
 
@@ -39,40 +43,52 @@ func (es *emailSender) ID() string {
 	return es.id
 }
 
-func (es *emailSender) InitFlags() {
-	pflag.StringVar(&es.name,
+func (es *emailSender) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&es.name,
 		"email-sender-name",
 		"",
 		"Email sender name",
 	)
 
-	pflag.StringVar(&es.address,
+	fs.StringVar(&es.address,
 		"email-sender-address",
 		"",
 		"Email sender address",
 	)
 
-	pflag.StringVar(&es.password,
+	fs.StringVar(&es.password,
 		"email-sender-password",
 		"",
 		"Email sender password",
 	)
 
-	pflag.StringVar(&es.smtpServer,
+	fs.StringVar(&es.smtpServer,
 		"email-smtp-server",
 		defaultSMTPServer,
 		fmt.Sprintf("Email SMTP server - Default: %s (Gmail)", defaultSMTPServer),
 	)
 
-	pflag.IntVar(&es.smtpPort,
+	fs.IntVar(&es.smtpPort,
 		"email-smtp-port",
 		defaultSMTPPort,
 		fmt.Sprintf("Email SMTP port - Default: %d", defaultSMTPPort),
 	)
+
+	fs.StringVar(&es.metricsID,
+		"email-metrics-id",
+		"",
+		"Email metrics component ID, empty disables metrics - Ex: metrics",
+	)
 }
 
 func (es *emailSender) Run(ac appctx.AppContext) error {
 	es.logger = ac.Logger(es.id)
+
+	es.sender = (*smtpSender)(es)
+	if es.metricsID != "" {
+		es.sender = ac.MustGet(es.metricsID).(core.MetricsComponent).WrapEmailSender(es.sender)
+	}
+
 	return nil
 }
 
@@ -87,6 +103,19 @@ func (es *emailSender) SendEmail(
 	cc []string,
 	bcc []string,
 	attachments []string,
+) error {
+	return es.sender.SendEmail(subject, content, to, cc, bcc, attachments)
+}
+
+type smtpSender emailSender
+
+func (es *smtpSender) SendEmail(
+	subject string,
+	content string,
+	to []string,
+	cc []string,
+	bcc []string,
+	attachments []string,
 ) error {
 	e := email.NewEmail()
 	e.From = fmt.Sprintf("%s <%s>", es.name, es.address)
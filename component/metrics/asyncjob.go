@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type jobObserver struct {
+	retries  *prometheus.CounterVec
+	failures *prometheus.CounterVec
+}
+
+func (m *metrics) newJobObserver() *jobObserver {
+	return &jobObserver{
+		retries: register(m, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.namespace,
+			Subsystem: "asyncjob",
+			Name:      "retries_total",
+			Help:      "Total number of job retries.",
+		}, []string{"job"})),
+		failures: register(m, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.namespace,
+			Subsystem: "asyncjob",
+			Name:      "failures_total",
+			Help:      "Total number of jobs failed after all retries.",
+		}, []string{"job"})),
+	}
+}
+
+func (o *jobObserver) OnRetry(name string, retryIndex int) {
+	o.retries.WithLabelValues(name).Inc()
+}
+
+func (o *jobObserver) OnFailed(name string, err error) {
+	o.failures.WithLabelValues(name).Inc()
+}
@@ -0,0 +1,235 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	cachecomp "github.com/hoangtk0100/app-context/component/cache"
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type cacheObserver struct {
+	name       string
+	operations *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	requests   *prometheus.CounterVec
+}
+
+// CacheObserver counts the cache operations, observes their latency
+// and counts the reads by result (hit ratio = hits / (hits + misses))
+func (m *metrics) CacheObserver(name string) core.CacheObserver {
+	return &cacheObserver{
+		name: name,
+		operations: register(m, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.namespace,
+			Subsystem: "cache",
+			Name:      "operations_total",
+			Help:      "Total number of cache operations.",
+		}, []string{"name", "operation", "status"})),
+		duration: register(m, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: m.namespace,
+			Subsystem: "cache",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of cache operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"name", "operation"})),
+		requests: register(m, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.namespace,
+			Subsystem: "cache",
+			Name:      "requests_total",
+			Help:      "Total number of cache reads by result (hit | miss | error).",
+		}, []string{"name", "result"})),
+	}
+}
+
+func (o *cacheObserver) OnOperation(operation string, duration time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+
+		// Hits and misses are reported by OnRead
+		if operation == "get" || operation == "mget" {
+			o.requests.WithLabelValues(o.name, "error").Inc()
+		}
+	}
+
+	o.operations.WithLabelValues(o.name, operation, status).Inc()
+	o.duration.WithLabelValues(o.name, operation).Observe(duration.Seconds())
+}
+
+func (o *cacheObserver) OnRead(hits, misses int) {
+	if hits > 0 {
+		o.requests.WithLabelValues(o.name, "hit").Add(float64(hits))
+	}
+
+	if misses > 0 {
+		o.requests.WithLabelValues(o.name, "miss").Add(float64(misses))
+	}
+}
+
+type cache struct {
+	core.CacheComponent
+	observer core.CacheObserver
+}
+
+// WrapCache instruments all the operations of the cache like CacheObserver,
+// the built-in caches are instrumented with their metrics ID flag instead
+func (m *metrics) WrapCache(name string, c core.CacheComponent) core.CacheComponent {
+	return &cache{
+		CacheComponent: c,
+		observer:       m.CacheObserver(name),
+	}
+}
+
+func (c *cache) observe(operation string, startTime time.Time, err error) {
+	if errors.Is(err, cachecomp.ErrNotFound) {
+		err = nil
+	}
+
+	c.observer.OnOperation(operation, time.Since(startTime), err)
+}
+
+func (c *cache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	startTime := time.Now()
+	err := c.CacheComponent.Set(ctx, key, value, ttl)
+	c.observe("set", startTime, err)
+
+	return err
+}
+
+func (c *cache) Get(ctx context.Context, key string, value interface{}) error {
+	startTime := time.Now()
+	err := c.CacheComponent.Get(ctx, key, value)
+	c.observe("get", startTime, err)
+
+	if err == nil {
+		c.observer.OnRead(1, 0)
+	} else if errors.Is(err, cachecomp.ErrNotFound) {
+		c.observer.OnRead(0, 1)
+	}
+
+	return err
+}
+
+func (c *cache) Delete(ctx context.Context, key string) error {
+	startTime := time.Now()
+	err := c.CacheComponent.Delete(ctx, key)
+	c.observe("delete", startTime, err)
+
+	return err
+}
+
+func (c *cache) MGet(ctx context.Context, values map[string]interface{}) ([]string, error) {
+	startTime := time.Now()
+	missing, err := c.CacheComponent.MGet(ctx, values)
+	c.observe("mget", startTime, err)
+
+	if err == nil {
+		c.observer.OnRead(len(values)-len(missing), len(missing))
+	}
+
+	return missing, err
+}
+
+func (c *cache) MSet(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	startTime := time.Now()
+	err := c.CacheComponent.MSet(ctx, values, ttl)
+	c.observe("mset", startTime, err)
+
+	return err
+}
+
+func (c *cache) MDelete(ctx context.Context, keys ...string) error {
+	startTime := time.Now()
+	err := c.CacheComponent.MDelete(ctx, keys...)
+	c.observe("mdelete", startTime, err)
+
+	return err
+}
+
+func (c *cache) Exists(ctx context.Context, key string) (bool, error) {
+	startTime := time.Now()
+	exists, err := c.CacheComponent.Exists(ctx, key)
+	c.observe("exists", startTime, err)
+
+	return exists, err
+}
+
+func (c *cache) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	startTime := time.Now()
+	err := c.CacheComponent.Expire(ctx, key, ttl)
+	c.observe("expire", startTime, err)
+
+	return err
+}
+
+func (c *cache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	startTime := time.Now()
+	ttl, err := c.CacheComponent.TTL(ctx, key)
+	c.observe("ttl", startTime, err)
+
+	return ttl, err
+}
+
+func (c *cache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	startTime := time.Now()
+	value, err := c.CacheComponent.Incr(ctx, key, delta, ttl)
+	c.observe("incr", startTime, err)
+
+	return value, err
+}
+
+func (c *cache) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	startTime := time.Now()
+	value, err := c.CacheComponent.Decr(ctx, key, delta, ttl)
+	c.observe("decr", startTime, err)
+
+	return value, err
+}
+
+func (c *cache) DeleteByPrefix(ctx context.Context, prefix string) error {
+	startTime := time.Now()
+	err := c.CacheComponent.DeleteByPrefix(ctx, prefix)
+	c.observe("delete_by_prefix", startTime, err)
+
+	return err
+}
+
+func (c *cache) SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error {
+	startTime := time.Now()
+	err := c.CacheComponent.SetWithTags(ctx, key, value, ttl, tags...)
+	c.observe("set_with_tags", startTime, err)
+
+	return err
+}
+
+func (c *cache) InvalidateTags(ctx context.Context, tags ...string) error {
+	startTime := time.Now()
+	err := c.CacheComponent.InvalidateTags(ctx, tags...)
+	c.observe("invalidate_tags", startTime, err)
+
+	return err
+}
+
+// GetOrLoad is counted as a miss when the loader is called or a negative result is cached, as a hit otherwise
+func (c *cache) GetOrLoad(ctx context.Context, key string, dst interface{}, ttl time.Duration, loader core.LoaderFunc, opts ...core.LoadOption) error {
+	var loaded atomic.Bool
+
+	startTime := time.Now()
+	err := c.CacheComponent.GetOrLoad(ctx, key, dst, ttl, func(ctx context.Context) (interface{}, error) {
+		loaded.Store(true)
+		return loader(ctx)
+	}, opts...)
+	c.observe("get_or_load", startTime, err)
+
+	if loaded.Load() || errors.Is(err, cachecomp.ErrNotFound) {
+		c.observer.OnRead(0, 1)
+	} else if err == nil {
+		c.observer.OnRead(1, 0)
+	}
+
+	return err
+}
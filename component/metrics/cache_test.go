@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	cachecomp "github.com/hoangtk0100/app-context/component/cache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapCache(t *testing.T) {
+	ctx := context.Background()
+	m := NewMetrics("metrics")
+	c := m.WrapCache("users", cachecomp.NewLocalCache("cache", ""))
+
+	loader := func(ctx context.Context) (interface{}, error) {
+		return "carol", nil
+	}
+
+	var name string
+	require.NoError(t, c.Set(ctx, "user:1", "alice", time.Minute))
+	require.NoError(t, c.SetWithTags(ctx, "user:2", "bob", time.Minute, "users"))
+	require.NoError(t, c.MSet(ctx, map[string]interface{}{"user:3": "carol"}, time.Minute))
+	require.NoError(t, c.Get(ctx, "user:1", &name))
+	require.ErrorIs(t, c.Get(ctx, "user:4", &name), cachecomp.ErrNotFound)
+
+	missing, err := c.MGet(ctx, map[string]interface{}{"user:1": &name, "user:2": &name, "user:4": &name})
+	require.NoError(t, err)
+	require.Len(t, missing, 1)
+
+	// Missed then hit
+	require.NoError(t, c.GetOrLoad(ctx, "user:5", &name, time.Minute, loader))
+	require.NoError(t, c.GetOrLoad(ctx, "user:5", &name, time.Minute, loader))
+
+	_, err = c.Exists(ctx, "user:1")
+	require.NoError(t, err)
+	require.NoError(t, c.Expire(ctx, "user:1", time.Hour))
+	_, err = c.TTL(ctx, "user:1")
+	require.NoError(t, err)
+	_, err = c.Incr(ctx, "count", 2, time.Minute)
+	require.NoError(t, err)
+	_, err = c.Decr(ctx, "count", 1, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, c.InvalidateTags(ctx, "users"))
+	require.NoError(t, c.Delete(ctx, "user:1"))
+	require.NoError(t, c.MDelete(ctx, "user:3"))
+	require.NoError(t, c.DeleteByPrefix(ctx, "user:"))
+
+	expected := `
+# HELP cache_requests_total Total number of cache reads by result (hit | miss | error).
+# TYPE cache_requests_total counter
+cache_requests_total{name="users",result="hit"} 4
+cache_requests_total{name="users",result="miss"} 3
+`
+	require.NoError(t, testutil.GatherAndCompare(m.GetRegistry(), strings.NewReader(expected), "cache_requests_total"))
+
+	// Each operation is counted once, a key not found is not an error
+	count, err := testutil.GatherAndCount(m.GetRegistry(), "cache_operations_total")
+	require.NoError(t, err)
+	require.Equal(t, 15, count)
+
+	observer := c.(*cache).observer.(*cacheObserver)
+	require.Equal(t, 2.0, testutil.ToFloat64(observer.operations.WithLabelValues("users", "get", "ok")))
+	require.Equal(t, 2.0, testutil.ToFloat64(observer.operations.WithLabelValues("users", "get_or_load", "ok")))
+}
+
+func TestCacheObserverError(t *testing.T) {
+	m := NewMetrics("metrics")
+	observer := m.CacheObserver("users").(*cacheObserver)
+
+	observer.OnOperation("get", time.Millisecond, context.DeadlineExceeded)
+	observer.OnOperation("set", time.Millisecond, context.DeadlineExceeded)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(observer.requests.WithLabelValues("users", "error")))
+	require.Equal(t, 1.0, testutil.ToFloat64(observer.operations.WithLabelValues("users", "set", "error")))
+}
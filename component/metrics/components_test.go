@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	appctx "github.com/hoangtk0100/app-context"
+	cachecomp "github.com/hoangtk0100/app-context/component/cache"
+	grpcclient "github.com/hoangtk0100/app-context/component/client/grpc"
+	"github.com/hoangtk0100/app-context/component/datastore/gormdb"
+	"github.com/hoangtk0100/app-context/component/datastore/redisdb"
+	"github.com/hoangtk0100/app-context/component/mail"
+	"github.com/hoangtk0100/app-context/component/pubsub"
+	ginserver "github.com/hoangtk0100/app-context/component/server/gin"
+	"github.com/hoangtk0100/app-context/util/asyncjob"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type testAppContext struct {
+	appctx.AppContext
+	m *metrics
+}
+
+func (testAppContext) GetName() string {
+	return "test"
+}
+
+func (testAppContext) GetPrefix() string {
+	return ""
+}
+
+func (testAppContext) Logger(prefix string) appctx.Logger {
+	return appctx.NewAppLogger("test", io.Discard).GetLogger(prefix)
+}
+
+func (ac testAppContext) MustGet(id string) interface{} {
+	if id != ac.m.ID() {
+		panic("component not found: " + id)
+	}
+
+	return ac.m
+}
+
+// runComponent sets the flags of the component and runs it
+func runComponent(t *testing.T, ac testAppContext, c appctx.Component, flags map[string]string) {
+	fs := pflag.NewFlagSet(t.Name(), pflag.ContinueOnError)
+	c.InitFlags(fs)
+	for name, value := range flags {
+		require.NoError(t, fs.Set(name, value))
+	}
+
+	require.NoError(t, c.Run(ac))
+	t.Cleanup(func() {
+		_ = c.Stop()
+	})
+}
+
+func TestComponentMetricsID(t *testing.T) {
+	ctx := context.Background()
+	m := NewMetrics("metrics")
+	ac := testAppContext{m: m}
+
+	t.Run("gin", func(t *testing.T) {
+		server := ginserver.NewServer("gin")
+		runComponent(t, ac, server, map[string]string{"gin-metrics-id": "metrics"})
+
+		server.GetRouter().GET("/ping", func(ctx *gin.Context) {
+			ctx.Status(http.StatusNoContent)
+		})
+		server.GetRouter().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+		expected := `
+# HELP http_requests_total Total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="/ping",status="204"} 1
+`
+		require.NoError(t, testutil.GatherAndCompare(m.GetRegistry(), strings.NewReader(expected), "http_requests_total"))
+
+		// Served on the router
+		rec := httptest.NewRecorder()
+		server.GetRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, defaultPath, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(), "http_requests_total")
+	})
+
+	t.Run("grpc-client", func(t *testing.T) {
+		client := grpcclient.NewClient("grpc-client", "")
+		runComponent(t, ac, client, map[string]string{"grpc-client-metrics-id": "metrics"})
+
+		conn := client.Dial()
+		require.NotNil(t, conn)
+		defer conn.Close()
+
+		// Fails as no server is listening, the call is counted anyway
+		ctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+		defer cancel()
+		require.Error(t, conn.Invoke(ctx, "/test.Service/Ping", &emptypb.Empty{}, &emptypb.Empty{}))
+
+		count, err := testutil.GatherAndCount(m.GetRegistry(), "grpc_client_started_total")
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+	})
+
+	t.Run("gorm", func(t *testing.T) {
+		db := gormdb.NewGormDB("main-db", "")
+		runComponent(t, ac, db, map[string]string{
+			"db-driver":     "sqlite",
+			"db-source":     "file::memory:",
+			"db-metrics-id": "metrics",
+		})
+
+		var count int
+		require.NoError(t, db.GetDB().Raw("SELECT 1").Scan(&count).Error)
+
+		count, err := testutil.GatherAndCount(m.GetRegistry(), "db_query_duration_seconds", "go_sql_open_connections")
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+	})
+
+	t.Run("redis", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		runComponent(t, ac, redisdb.NewRedisDB("redis", ""), map[string]string{
+			"url":        "redis://" + server.Addr(),
+			"metrics-id": "metrics",
+		})
+
+		count, err := testutil.GatherAndCount(m.GetRegistry(), "redis_pool_total_connections")
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+	})
+
+	t.Run("mail", func(t *testing.T) {
+		sender := mail.NewEmailSender("email")
+		runComponent(t, ac, sender, map[string]string{"email-metrics-id": "metrics"})
+
+		// Fails before connecting to the SMTP server
+		require.Error(t, sender.SendEmail("Welcome", "Hello", []string{"bob@example.com"}, nil, nil, []string{"missing.pdf"}))
+
+		expected := `
+# HELP mail_sent_total Total number of sent emails.
+# TYPE mail_sent_total counter
+mail_sent_total{status="error"} 1
+`
+		require.NoError(t, testutil.GatherAndCompare(m.GetRegistry(), strings.NewReader(expected), "mail_sent_total"))
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		c := cachecomp.NewLocalCache("local-cache", "")
+		runComponent(t, ac, c, map[string]string{"local-cache-metrics-id": "metrics"})
+
+		var value string
+		require.NoError(t, c.Set(ctx, "key", "value", time.Minute))
+		require.NoError(t, c.Get(ctx, "key", &value))
+
+		expected := `
+# HELP cache_requests_total Total number of cache reads by result (hit | miss | error).
+# TYPE cache_requests_total counter
+cache_requests_total{name="local-cache",result="hit"} 1
+`
+		require.NoError(t, testutil.GatherAndCompare(m.GetRegistry(), strings.NewReader(expected), "cache_requests_total"))
+	})
+
+	t.Run("pubsub", func(t *testing.T) {
+		ps := pubsub.NewLocalPubSub("pubsub")
+		runComponent(t, ac, ps, map[string]string{"local-pubsub-metrics-id": "metrics"})
+
+		require.NoError(t, ps.Publish(ctx, "order.created", pubsub.NewMessage(1)))
+
+		expected := `
+# HELP pubsub_published_total Total number of published messages.
+# TYPE pubsub_published_total counter
+pubsub_published_total{name="pubsub",status="ok",topic="order.created"} 1
+`
+		require.NoError(t, testutil.GatherAndCompare(m.GetRegistry(), strings.NewReader(expected), "pubsub_published_total"))
+	})
+}
+
+func TestStopObservers(t *testing.T) {
+	m := NewMetrics("metrics")
+	runComponent(t, testAppContext{m: m}, m, nil)
+	require.NoError(t, m.Stop())
+
+	// The job retries are not observed once stopped
+	job := asyncjob.NewJob(func(ctx context.Context) error {
+		return errors.New("failed")
+	}, asyncjob.WithName("send-email"), asyncjob.WithRetryDurations([]time.Duration{time.Millisecond}))
+	require.Error(t, asyncjob.NewGroup(false, job).Run(context.Background()))
+
+	count, err := testutil.GatherAndCount(m.GetRegistry(), "asyncjob_retries_total")
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/hoangtk0100/app-context/component/pubsub"
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type dedupStore struct {
+	core.DedupStore
+	name   string
+	claims *prometheus.CounterVec
+}
+
+// WrapDedupStore counts the claims of the deduplication store by result,
+// the duplicates skipped by the subscriber engines are counted without it
+func (m *metrics) WrapDedupStore(name string, store core.DedupStore) core.DedupStore {
+	return &dedupStore{
+		DedupStore: store,
+		name:       name,
+		claims: register(m, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.namespace,
+			Subsystem: "pubsub",
+			Name:      "dedup_claims_total",
+			Help:      "Total number of message deduplication claims by result (claimed | processing | duplicate | error).",
+		}, []string{"name", "result"})),
+	}
+}
+
+func (s *dedupStore) Claim(ctx context.Context, key, owner string, ttl time.Duration) (core.DedupState, error) {
+	state, err := s.DedupStore.Claim(ctx, key, owner, ttl)
+
+	result := "claimed"
+	if err != nil {
+		result = "error"
+	} else if state == core.DedupProcessing {
+		result = "processing"
+	} else if state == core.DedupDone {
+		result = "duplicate"
+	}
+
+	s.claims.WithLabelValues(s.name, result).Inc()
+
+	return state, err
+}
+
+type subscriberObserver struct {
+	duplicates *prometheus.CounterVec
+}
+
+func (m *metrics) newSubscriberObserver() *subscriberObserver {
+	return &subscriberObserver{
+		duplicates: register(m, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.namespace,
+			Subsystem: "pubsub",
+			Name:      "duplicates_skipped_total",
+			Help:      "Total number of duplicate messages skipped by the subscriber engines.",
+		}, []string{"engine", "topic"})),
+	}
+}
+
+func (o *subscriberObserver) OnDuplicate(engine string, topic pubsub.Topic) {
+	o.duplicates.WithLabelValues(engine, string(topic)).Inc()
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/hoangtk0100/app-context/component/datastore/gormdb/dialects"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type user struct {
+	ID   uint64 `gorm:"primaryKey"`
+	Name string
+}
+
+func TestGormPlugin(t *testing.T) {
+	m := NewMetrics("metrics")
+	plugin := m.GormPlugin("main")
+
+	db, err := dialects.SQLiteDB(filepath.Join(t.TempDir(), "metrics.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.Use(plugin))
+
+	// The pool stats of the same database name are registered once
+	other, err := dialects.SQLiteDB(filepath.Join(t.TempDir(), "other.db"))
+	require.NoError(t, err)
+	require.NoError(t, other.Use(m.GormPlugin("main")))
+
+	require.NoError(t, db.AutoMigrate(&user{}))
+	require.NoError(t, db.Create(&user{Name: "alice"}).Error)
+
+	var u1, u2 user
+	require.NoError(t, db.First(&u1).Error)
+	require.ErrorIs(t, db.First(&u2, 2).Error, gorm.ErrRecordNotFound)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, defaultPath, nil))
+
+	// A record not found is not an error
+	require.Contains(t, rec.Body.String(), `db_query_duration_seconds_count{db_name="main",operation="create",status="ok"} 1`)
+	require.Contains(t, rec.Body.String(), `db_query_duration_seconds_count{db_name="main",operation="query",status="ok"} 2`)
+	require.Contains(t, rec.Body.String(), `go_sql_open_connections{db_name="main"}`)
+}
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"google.golang.org/grpc"
+)
+
+func (m *metrics) clientMetrics() *grpc_prometheus.ClientMetrics {
+	clientMetrics := grpc_prometheus.NewClientMetrics()
+	clientMetrics.EnableClientHandlingTimeHistogram()
+
+	return register(m, clientMetrics)
+}
+
+// GRPCClientDialOptions instruments the GRPC client calls, ex: client.Dial(m.GRPCClientDialOptions()...)
+func (m *metrics) GRPCClientDialOptions() []grpc.DialOption {
+	clientMetrics := m.clientMetrics()
+
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(clientMetrics.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(clientMetrics.StreamClientInterceptor()),
+	}
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// GinMiddleware counts requests and observes their latency by method, route and status
+func (m *metrics) GinMiddleware() gin.HandlerFunc {
+	requests := register(m, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: m.namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests.",
+	}, []string{"method", "route", "status"}))
+
+	duration := register(m, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: m.namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"}))
+
+	return func(ctx *gin.Context) {
+		startTime := time.Now()
+		ctx.Next()
+
+		// Use the route pattern to keep the cardinality low
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		method := ctx.Request.Method
+		requests.WithLabelValues(method, route, strconv.Itoa(ctx.Writer.Status())).Inc()
+		duration.WithLabelValues(method, route).Observe(time.Since(startTime).Seconds())
+	}
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type emailSender struct {
+	core.EmailComponent
+	sent     *prometheus.CounterVec
+	duration prometheus.Histogram
+}
+
+// WrapEmailSender counts the sent emails by status and observes the send latency
+func (m *metrics) WrapEmailSender(es core.EmailComponent) core.EmailComponent {
+	return &emailSender{
+		EmailComponent: es,
+		sent: register(m, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.namespace,
+			Subsystem: "mail",
+			Name:      "sent_total",
+			Help:      "Total number of sent emails.",
+		}, []string{"status"})),
+		duration: register(m, prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: m.namespace,
+			Subsystem: "mail",
+			Name:      "send_duration_seconds",
+			Help:      "Latency of sending emails.",
+			Buckets:   prometheus.DefBuckets,
+		})),
+	}
+}
+
+func (es *emailSender) SendEmail(
+	subject string,
+	content string,
+	to []string,
+	cc []string,
+	bcc []string,
+	attachments []string,
+) error {
+	startTime := time.Now()
+	err := es.EmailComponent.SendEmail(subject, content, to, cc, bcc, attachments)
+	es.duration.Observe(time.Since(startTime).Seconds())
+
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+
+	es.sent.WithLabelValues(status).Inc()
+
+	return err
+}
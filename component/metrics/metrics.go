@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/hoangtk0100/app-context/util/asyncjob"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultPath            = "/metrics"
+	defaultShutdownTimeout = time.Second * 5
+)
+
+type config struct {
+	address   string
+	path      string
+	namespace string
+}
+
+type metrics struct {
+	id       string
+	logger   appctx.Logger
+	registry *prometheus.Registry
+	server   *http.Server
+	*config
+}
+
+func NewMetrics(id string) *metrics {
+	return &metrics{
+		id:       id,
+		registry: prometheus.NewRegistry(),
+		config:   new(config),
+	}
+}
+
+func (m *metrics) ID() string {
+	return m.id
+}
+
+func (m *metrics) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(
+		&m.address,
+		"metrics-address",
+		"",
+		"Metrics server address, empty to serve on Gin/GRPC gateway only - Ex: :9090",
+	)
+
+	fs.StringVar(
+		&m.path,
+		"metrics-path",
+		defaultPath,
+		fmt.Sprintf("Metrics path - Default: %s", defaultPath),
+	)
+
+	fs.StringVar(
+		&m.namespace,
+		"metrics-namespace",
+		"",
+		"Metrics namespace prepended to all metric names - Ex: app",
+	)
+}
+
+func (m *metrics) Run(ac appctx.AppContext) error {
+	m.logger = ac.Logger(m.id)
+
+	if err := m.registerAll(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		// Filled by the GRPC server with metrics enabled
+		grpc_prometheus.DefaultServerMetrics,
+	); err != nil {
+		return err
+	}
+
+	asyncjob.SetObserver(m.newJobObserver())
+	core.SetSubscriberObserver(m.newSubscriberObserver())
+
+	if m.address != "" {
+		mux := http.NewServeMux()
+		mux.Handle(m.path, m.Handler())
+
+		m.server = &http.Server{
+			Addr:    m.address,
+			Handler: mux,
+		}
+
+		go func() {
+			m.logger.Infof("Start metrics server at %s%s", m.address, m.path)
+			if err := m.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				m.logger.Error(err, "Metrics server closed unexpectedly")
+			}
+		}()
+	}
+
+	return nil
+}
+
+// Stop removes the async job and subscriber observers installed by Run
+func (m *metrics) Stop() error {
+	asyncjob.SetObserver(nil)
+	core.SetSubscriberObserver(nil)
+
+	if m.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+
+	return m.server.Shutdown(ctx)
+}
+
+func (m *metrics) GetRegistry() *prometheus.Registry {
+	return m.registry
+}
+
+func (m *metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// RegisterGin serves the metrics on the Gin router
+func (m *metrics) RegisterGin(router gin.IRoutes) {
+	router.GET(m.getPath(), gin.WrapH(m.Handler()))
+}
+
+// RegisterGateway serves the metrics on the GRPC gateway mux
+func (m *metrics) RegisterGateway(mux *runtime.ServeMux) error {
+	handler := m.Handler()
+
+	return mux.HandlePath(http.MethodGet, m.getPath(), func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func (m *metrics) getPath() string {
+	if m.path == "" {
+		return defaultPath
+	}
+
+	return m.path
+}
+
+func (m *metrics) registerAll(cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if err := m.registry.Register(c); err != nil {
+			var are prometheus.AlreadyRegisteredError
+			if errors.As(err, &are) {
+				continue
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// register returns the already registered collector if any,
+// so instrumentation helpers can be called several times.
+// A collector failing to register is still returned, but not exported
+func register[T prometheus.Collector](m *metrics, c T) T {
+	if err := m.registry.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+
+		if m.logger != nil {
+			m.logger.Error(err, "Cannot register metrics collector")
+		}
+	}
+
+	return c
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGinMiddleware(t *testing.T) {
+	m := NewMetrics("metrics")
+	m.namespace = "app"
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(m.GinMiddleware())
+	m.RegisterGin(router)
+	router.GET("/users/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusNotFound)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/2", nil))
+
+	expected := `
+# HELP app_http_requests_total Total number of HTTP requests.
+# TYPE app_http_requests_total counter
+app_http_requests_total{method="GET",route="/users/:id",status="404"} 2
+`
+	require.NoError(t, testutil.GatherAndCompare(m.GetRegistry(), strings.NewReader(expected), "app_http_requests_total"))
+
+	// Calling the helper again reuses the registered collectors
+	require.NotPanics(t, func() { m.GinMiddleware() })
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, defaultPath, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "app_http_request_duration_seconds")
+}
+
+func TestJobObserver(t *testing.T) {
+	m := NewMetrics("metrics")
+	observer := m.newJobObserver()
+
+	observer.OnRetry("send-email", 1)
+	observer.OnRetry("send-email", 2)
+	observer.OnFailed("send-email", nil)
+
+	require.Equal(t, 2.0, testutil.ToFloat64(observer.retries.WithLabelValues("send-email")))
+	require.Equal(t, 1.0, testutil.ToFloat64(observer.failures.WithLabelValues("send-email")))
+}
+
+func TestRegisterConflict(t *testing.T) {
+	m := NewMetrics("metrics")
+	m.GinMiddleware()
+
+	// Same name with other labels, the collector is usable but not exported
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total number of HTTP requests.",
+	}, []string{"route"})
+
+	require.NotPanics(t, func() {
+		require.Same(t, requests, register(m, requests))
+	})
+
+	requests.WithLabelValues("/users").Inc()
+	require.Equal(t, 1.0, testutil.ToFloat64(requests.WithLabelValues("/users")))
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/hoangtk0100/app-context/component/pubsub"
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type pubSubObserver struct {
+	name      string
+	published *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+	consumed  *prometheus.CounterVec
+	lag       *prometheus.HistogramVec
+}
+
+// PubSubObserver counts the published and consumed messages, observes the publish latency
+// and the consume lag (time between message creation and delivery to the subscriber)
+func (m *metrics) PubSubObserver(name string) pubsub.Observer {
+	return &pubSubObserver{
+		name: name,
+		published: register(m, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.namespace,
+			Subsystem: "pubsub",
+			Name:      "published_total",
+			Help:      "Total number of published messages.",
+		}, []string{"name", "topic", "status"})),
+		duration: register(m, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: m.namespace,
+			Subsystem: "pubsub",
+			Name:      "publish_duration_seconds",
+			Help:      "Latency of publishing messages.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"name", "topic"})),
+		consumed: register(m, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.namespace,
+			Subsystem: "pubsub",
+			Name:      "consumed_total",
+			Help:      "Total number of consumed messages.",
+		}, []string{"name", "topic"})),
+		lag: register(m, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: m.namespace,
+			Subsystem: "pubsub",
+			Name:      "consume_lag_seconds",
+			Help:      "Time between the creation of messages and their delivery to subscribers.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"name", "topic"})),
+	}
+}
+
+func (o *pubSubObserver) OnPublish(topic pubsub.Topic, duration time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+
+	o.published.WithLabelValues(o.name, string(topic), status).Inc()
+	o.duration.WithLabelValues(o.name, string(topic)).Observe(duration.Seconds())
+}
+
+func (o *pubSubObserver) OnDeliver(msg *pubsub.Message) {
+	topic := string(msg.Topic())
+
+	o.consumed.WithLabelValues(o.name, topic).Inc()
+	o.lag.WithLabelValues(o.name, topic).Observe(time.Since(msg.CreatedAt()).Seconds())
+}
+
+type pubSub struct {
+	core.PubSubComponent
+	observer pubsub.Observer
+}
+
+// WrapPubSub instruments the backend like PubSubObserver,
+// the built-in backends are instrumented with their metrics ID flag instead
+func (m *metrics) WrapPubSub(name string, ps core.PubSubComponent) core.PubSubComponent {
+	return &pubSub{
+		PubSubComponent: ps,
+		observer:        m.PubSubObserver(name),
+	}
+}
+
+func (ps *pubSub) Publish(ctx context.Context, topic pubsub.Topic, msg *pubsub.Message) error {
+	startTime := time.Now()
+	err := ps.PubSubComponent.Publish(ctx, topic, msg)
+	ps.observer.OnPublish(topic, time.Since(startTime), err)
+
+	return err
+}
+
+// Request is counted as a published message, the backend must support request/reply
+func (ps *pubSub) Request(ctx context.Context, topic pubsub.Topic, msg *pubsub.Message) (*pubsub.Message, error) {
+	requester, ok := ps.PubSubComponent.(core.RequesterComponent)
+	if !ok {
+		return nil, pubsub.ErrRequestNotSupported
+	}
+
+	startTime := time.Now()
+	reply, err := requester.Request(ctx, topic, msg)
+	ps.observer.OnPublish(topic, time.Since(startTime), err)
+
+	return reply, err
+}
+
+func (ps *pubSub) Subscribe(ctx context.Context, topic pubsub.Topic) (<-chan *pubsub.Message, func()) {
+	ch, unsubscribe := ps.PubSubComponent.Subscribe(ctx, topic)
+	return ps.observe(topic, ch, unsubscribe)
+}
+
+func (ps *pubSub) QueueSubscribe(ctx context.Context, topic pubsub.Topic, group string) (<-chan *pubsub.Message, func()) {
+	ch, unsubscribe := ps.PubSubComponent.QueueSubscribe(ctx, topic, group)
+	return ps.observe(topic, ch, unsubscribe)
+}
+
+// observe forwards the messages and counts them until unsubscribed
+func (ps *pubSub) observe(topic pubsub.Topic, ch <-chan *pubsub.Message, unsubscribe func()) (<-chan *pubsub.Message, func()) {
+	out := make(chan *pubsub.Message)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(out)
+
+		for {
+			select {
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				ps.observer.OnDeliver(msg)
+
+				select {
+				case out <- msg:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return out, func() {
+		unsubscribe()
+		close(done)
+	}
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoangtk0100/app-context/component/pubsub"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapPubSub(t *testing.T) {
+	ctx := context.Background()
+	m := NewMetrics("metrics")
+
+	local := pubsub.NewLocalPubSub("pubsub")
+	require.NoError(t, local.Run(testAppContext{m: m}))
+	t.Cleanup(func() {
+		_ = local.Stop()
+	})
+
+	ps := m.WrapPubSub("events", local)
+	ch, unsubscribe := ps.Subscribe(ctx, "order.created")
+	defer unsubscribe()
+
+	require.NoError(t, ps.Publish(ctx, "order.created", pubsub.NewMessage(1)))
+
+	select {
+	case msg := <-ch:
+		require.Equal(t, 1, msg.Data())
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+
+	expected := `
+# HELP pubsub_consumed_total Total number of consumed messages.
+# TYPE pubsub_consumed_total counter
+pubsub_consumed_total{name="events",topic="order.created"} 1
+# HELP pubsub_published_total Total number of published messages.
+# TYPE pubsub_published_total counter
+pubsub_published_total{name="events",status="ok",topic="order.created"} 1
+`
+	require.NoError(t, testutil.GatherAndCompare(m.GetRegistry(), strings.NewReader(expected), "pubsub_published_total", "pubsub_consumed_total"))
+}
+
+func TestPubSubObserver(t *testing.T) {
+	m := NewMetrics("metrics")
+	observer := m.PubSubObserver("events").(*pubSubObserver)
+
+	observer.OnPublish("order.created", time.Millisecond, errors.New("timeout"))
+
+	msg := pubsub.NewMessage(1)
+	msg.SetTopic("order.created")
+	observer.OnDeliver(msg)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(observer.published.WithLabelValues("events", "order.created", "error")))
+	require.Equal(t, 1.0, testutil.ToFloat64(observer.consumed.WithLabelValues("events", "order.created")))
+
+	count, err := testutil.GatherAndCount(m.GetRegistry(), "pubsub_publish_duration_seconds", "pubsub_consume_lag_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
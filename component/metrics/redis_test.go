@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	m := NewMetrics("metrics")
+	m.namespace = "app"
+	require.NoError(t, m.RegisterRedis("cache", client))
+
+	// Registering the same name again is a no-op
+	require.NoError(t, m.RegisterRedis("cache", client))
+	require.NoError(t, m.RegisterRedis("session", client))
+
+	count, err := testutil.GatherAndCount(m.GetRegistry(), "app_redis_pool_total_connections")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
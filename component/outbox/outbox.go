@@ -0,0 +1,371 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/hoangtk0100/app-context/component/pubsub"
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/spf13/pflag"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultTable           = "outbox_events"
+	defaultPollInterval    = time.Second
+	defaultBatchSize       = 100
+	defaultRetention       = time.Hour * 24
+	defaultCleanupInterval = time.Minute * 10
+	defaultMaxAttempts     = 10
+	defaultPublishTimeout  = time.Second * 10
+	maxErrorLength         = 1024
+)
+
+// event is a row of the outbox table
+type event struct {
+	ID        uint64     `gorm:"column:id;primaryKey;autoIncrement"`
+	MessageID string     `gorm:"column:message_id;size:64;not null"`
+	Topic     string     `gorm:"column:topic;size:255;not null"`
+	Message   []byte     `gorm:"column:message;not null"`
+	Attempts  int        `gorm:"column:attempts;not null;default:0"`
+	LastError string     `gorm:"column:last_error;size:1024"`
+	CreatedAt time.Time  `gorm:"column:created_at;not null"`
+	SentAt    *time.Time `gorm:"column:sent_at;index"`
+	FailedAt  *time.Time `gorm:"column:failed_at"`
+	// LockedUntil leases the message to a relay while it is published outside the transaction
+	LockedUntil *time.Time `gorm:"column:locked_until"`
+}
+
+type outboxOpt struct {
+	prefix          string
+	gormDBID        string
+	pubSubID        string
+	table           string
+	pollInterval    time.Duration
+	batchSize       int
+	retention       time.Duration
+	cleanupInterval time.Duration
+	maxAttempts     int
+	publishTimeout  time.Duration
+	autoMigrate     bool
+}
+
+// Transactional outbox: messages are written to the outbox table in the transaction of the business data,
+// then the relay publishes them at least once and marks them sent.
+// A batch is locked and leased in a short transaction, then published outside it so a slow broker holds no row locks.
+// The messages of a relay stopped before marking them are published again once the lease expires.
+// Messages are published in order with a single relay only, several instances relay concurrently.
+// Messages failing max attempts times are marked failed (failed_at) and skipped, they are kept for inspection.
+// Sent rows are deleted after the retention
+type outbox struct {
+	id       string
+	logger   appctx.Logger
+	db       *gorm.DB
+	ps       core.PubSubComponent
+	doneChan chan struct{}
+	wg       sync.WaitGroup
+	*outboxOpt
+}
+
+func NewOutbox(id, prefix, gormDBID, pubSubID string) *outbox {
+	return &outbox{
+		id: id,
+		outboxOpt: &outboxOpt{
+			prefix:   strings.TrimSpace(prefix),
+			gormDBID: gormDBID,
+			pubSubID: pubSubID,
+		},
+	}
+}
+
+func (o *outbox) ID() string {
+	return o.id
+}
+
+func (o *outbox) InitFlags(fs *pflag.FlagSet) {
+	prefix := o.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.StringVar(&o.table,
+		fmt.Sprintf("%soutbox-table", prefix),
+		defaultTable,
+		fmt.Sprintf("Outbox table name - Default: %s", defaultTable),
+	)
+
+	fs.DurationVar(&o.pollInterval,
+		fmt.Sprintf("%soutbox-poll-interval", prefix),
+		defaultPollInterval,
+		fmt.Sprintf("Outbox interval to poll the unsent messages - Default: %s", defaultPollInterval),
+	)
+
+	fs.IntVar(&o.batchSize,
+		fmt.Sprintf("%soutbox-batch-size", prefix),
+		defaultBatchSize,
+		fmt.Sprintf("Outbox max number of messages published per poll - Default: %d", defaultBatchSize),
+	)
+
+	fs.DurationVar(&o.retention,
+		fmt.Sprintf("%soutbox-retention", prefix),
+		defaultRetention,
+		fmt.Sprintf("Outbox time to keep the sent messages before deleting them - Default: %s", defaultRetention),
+	)
+
+	fs.DurationVar(&o.cleanupInterval,
+		fmt.Sprintf("%soutbox-cleanup-interval", prefix),
+		defaultCleanupInterval,
+		fmt.Sprintf("Outbox interval to delete the sent messages past the retention - Default: %s", defaultCleanupInterval),
+	)
+
+	fs.IntVar(&o.maxAttempts,
+		fmt.Sprintf("%soutbox-max-attempts", prefix),
+		defaultMaxAttempts,
+		fmt.Sprintf("Outbox max number of publish attempts before marking a message failed, 0 is unlimited - Default: %d", defaultMaxAttempts),
+	)
+
+	fs.DurationVar(&o.publishTimeout,
+		fmt.Sprintf("%soutbox-publish-timeout", prefix),
+		defaultPublishTimeout,
+		fmt.Sprintf("Outbox timeout to publish a message, a batch is leased for its publish timeouts - Default: %s", defaultPublishTimeout),
+	)
+
+	fs.BoolVar(&o.autoMigrate,
+		fmt.Sprintf("%soutbox-auto-migrate", prefix),
+		true,
+		"Outbox creates the table if not exists - Default: true",
+	)
+}
+
+func (o *outbox) Run(ac appctx.AppContext) error {
+	o.logger = ac.Logger(o.id)
+
+	db := ac.MustGet(o.gormDBID).(core.GormDBComponent)
+	ps := ac.MustGet(o.pubSubID).(core.PubSubComponent)
+
+	if err := o.setup(db.GetDB(), ps); err != nil {
+		o.logger.Error(err, "Cannot setup outbox table")
+		return err
+	}
+
+	o.doneChan = make(chan struct{})
+	o.wg.Add(1)
+	go o.run(o.doneChan)
+
+	return nil
+}
+
+func (o *outbox) Stop() error {
+	if o.doneChan == nil {
+		return nil
+	}
+
+	close(o.doneChan)
+	o.wg.Wait()
+	o.doneChan = nil
+
+	return nil
+}
+
+func (o *outbox) setup(db *gorm.DB, ps core.PubSubComponent) error {
+	o.db = db
+	o.ps = ps
+
+	if !o.autoMigrate {
+		return nil
+	}
+
+	return db.Table(o.table).AutoMigrate(&event{})
+}
+
+// Publish writes the message to the outbox in tx, it is published once tx is committed
+func (o *outbox) Publish(tx *gorm.DB, topic pubsub.Topic, msg *pubsub.Message) error {
+	msg.SetTopic(topic)
+
+	data, err := pubsub.MarshalMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	return tx.Table(o.table).Create(&event{
+		MessageID: msg.ID(),
+		Topic:     string(topic),
+		Message:   data,
+		CreatedAt: time.Now().UTC(),
+	}).Error
+}
+
+func (o *outbox) run(doneChan <-chan struct{}) {
+	defer o.wg.Done()
+
+	pollTicker := time.NewTicker(o.pollInterval)
+	defer pollTicker.Stop()
+
+	cleanupTicker := time.NewTicker(o.cleanupInterval)
+	defer cleanupTicker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-doneChan
+		cancel()
+	}()
+
+	for {
+		select {
+		case <-doneChan:
+			return
+		case <-pollTicker.C:
+			// Drain the backlog without waiting for the next tick
+			for {
+				count, err := o.relay(ctx)
+				if err != nil {
+					o.logger.Error(err, "Cannot relay outbox messages")
+				}
+
+				if err != nil || count < o.batchSize {
+					break
+				}
+			}
+		case <-cleanupTicker.C:
+			if err := o.cleanup(ctx); err != nil {
+				o.logger.Error(err, "Cannot clean up outbox messages")
+			}
+		}
+	}
+}
+
+// pending selects a batch of the unsent messages which have not failed and are not leased.
+// Rows are locked with SKIP LOCKED where supported, so several instances can relay at once
+func (o *outbox) pending(tx *gorm.DB, now time.Time) *gorm.DB {
+	query := tx.Table(o.table).
+		Where("sent_at IS NULL AND failed_at IS NULL AND (locked_until IS NULL OR locked_until < ?)", now).
+		Order("id").
+		Limit(o.batchSize)
+
+	switch tx.Dialector.Name() {
+	case "postgres", "mysql":
+		query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+	}
+
+	return query
+}
+
+// claim leases a batch of pending messages for the time to publish all of them,
+// the row locks are released when its transaction is committed
+func (o *outbox) claim(ctx context.Context) ([]event, error) {
+	var events []event
+
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		if err := o.pending(tx, now).Find(&events).Error; err != nil {
+			return err
+		}
+
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]uint64, len(events))
+		for index := range events {
+			ids[index] = events[index].ID
+		}
+
+		lockedUntil := now.Add(o.publishTimeout * time.Duration(len(events)+1))
+
+		return tx.Table(o.table).Where("id IN ?", ids).Update("locked_until", lockedUntil).Error
+	})
+
+	return events, err
+}
+
+// relay publishes a batch of pending messages by ID, it stops at the first failure to keep the order
+func (o *outbox) relay(ctx context.Context) (int, error) {
+	events, err := o.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for index := range events {
+		evt := &events[index]
+
+		msg := pubsub.UnmarshalMessage(evt.Message)
+		if err := o.publish(ctx, pubsub.Topic(evt.Topic), msg); err != nil {
+			// Stopped, the lease expires and the messages are published by the next relay
+			if ctx.Err() != nil {
+				return count, ctx.Err()
+			}
+
+			o.logger.Errorf(err, "Cannot publish outbox message %s", evt.MessageID)
+
+			lastErr := err.Error()
+			if len(lastErr) > maxErrorLength {
+				lastErr = lastErr[:maxErrorLength]
+			}
+
+			values := map[string]interface{}{
+				"attempts":     gorm.Expr("attempts + 1"),
+				"last_error":   lastErr,
+				"locked_until": nil,
+			}
+
+			// Skipped from now on, so it does not block the next messages
+			if o.maxAttempts > 0 && evt.Attempts+1 >= o.maxAttempts {
+				o.logger.Warnf("Outbox message %s failed after %d attempts", evt.MessageID, evt.Attempts+1)
+				values["failed_at"] = time.Now().UTC()
+			}
+
+			// Release the rest of the batch to publish it in order at the next poll
+			ids := make([]uint64, 0, len(events)-index-1)
+			for _, next := range events[index+1:] {
+				ids = append(ids, next.ID)
+			}
+
+			return count, o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				if err := tx.Table(o.table).Where("id = ?", evt.ID).Updates(values).Error; err != nil || len(ids) == 0 {
+					return err
+				}
+
+				return tx.Table(o.table).Where("id IN ?", ids).Update("locked_until", nil).Error
+			})
+		}
+
+		// Published but not marked if the update fails, so published again once leased again: at least once
+		if err := o.db.WithContext(ctx).Table(o.table).Where("id = ?", evt.ID).Updates(map[string]interface{}{
+			"attempts":     gorm.Expr("attempts + 1"),
+			"sent_at":      time.Now().UTC(),
+			"locked_until": nil,
+		}).Error; err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// publish bounds the publish time by the timeout, so the batch is published within its lease
+func (o *outbox) publish(ctx context.Context, topic pubsub.Topic, msg *pubsub.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, o.publishTimeout)
+	defer cancel()
+
+	return o.ps.Publish(ctx, topic, msg)
+}
+
+// cleanup deletes the sent messages past the retention
+func (o *outbox) cleanup(ctx context.Context) error {
+	before := time.Now().UTC().Add(-o.retention)
+
+	return o.db.WithContext(ctx).Table(o.table).
+		Where("sent_at IS NOT NULL AND sent_at <= ?", before).
+		Delete(&event{}).Error
+}
@@ -0,0 +1,337 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/hoangtk0100/app-context/component/datastore/gormdb/dialects"
+	"github.com/hoangtk0100/app-context/component/pubsub"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type testPubSub struct {
+	locker    sync.Mutex
+	messages  []*pubsub.Message
+	failTimes int
+	// block holds the publish until closed or the ctx is done
+	block chan struct{}
+}
+
+func (ps *testPubSub) Publish(ctx context.Context, topic pubsub.Topic, msg *pubsub.Message) error {
+	if ps.block != nil {
+		select {
+		case <-ps.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	ps.locker.Lock()
+	defer ps.locker.Unlock()
+
+	if ps.failTimes > 0 {
+		ps.failTimes--
+		return errors.New("broker down")
+	}
+
+	msg.SetTopic(topic)
+	ps.messages = append(ps.messages, msg)
+
+	return nil
+}
+
+func (ps *testPubSub) Subscribe(ctx context.Context, topic pubsub.Topic) (<-chan *pubsub.Message, func()) {
+	return nil, func() {}
+}
+
+func (ps *testPubSub) QueueSubscribe(ctx context.Context, topic pubsub.Topic, group string) (<-chan *pubsub.Message, func()) {
+	return nil, func() {}
+}
+
+func (ps *testPubSub) published() []*pubsub.Message {
+	ps.locker.Lock()
+	defer ps.locker.Unlock()
+
+	return append([]*pubsub.Message(nil), ps.messages...)
+}
+
+type order struct {
+	ID     uint64 `gorm:"primaryKey"`
+	Status string
+}
+
+func newTestOutbox(t *testing.T) (*outbox, *gorm.DB, *testPubSub) {
+	db, err := dialects.SQLiteDB(filepath.Join(t.TempDir(), "outbox.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&order{}))
+
+	ps := new(testPubSub)
+
+	o := NewOutbox("outbox", "", "db", "pubsub")
+	o.logger = appctx.NewAppLogger("test", io.Discard).GetLogger(o.id)
+	o.table = defaultTable
+	o.pollInterval = time.Millisecond * 20
+	o.batchSize = 2
+	o.retention = time.Hour
+	o.cleanupInterval = time.Hour
+	o.maxAttempts = defaultMaxAttempts
+	o.publishTimeout = time.Second
+	o.autoMigrate = true
+	require.NoError(t, o.setup(db, ps))
+
+	return o, db, ps
+}
+
+func countUnsent(t *testing.T, o *outbox) int64 {
+	var count int64
+	require.NoError(t, o.db.Table(o.table).Where("sent_at IS NULL").Count(&count).Error)
+
+	return count
+}
+
+func TestOutboxRelay(t *testing.T) {
+	ctx := context.Background()
+	o, db, ps := newTestOutbox(t)
+
+	var ids []string
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		for i := 1; i <= 3; i++ {
+			if err := tx.Create(&order{ID: uint64(i), Status: "created"}).Error; err != nil {
+				return err
+			}
+
+			msg := pubsub.NewMessage(map[string]interface{}{"id": i})
+			msg.SetHeader("source", "test")
+			ids = append(ids, msg.ID())
+
+			if err := o.Publish(tx, "order.created", msg); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}))
+
+	// Rolled back with the business data
+	_ = db.Transaction(func(tx *gorm.DB) error {
+		require.NoError(t, o.Publish(tx, "order.created", pubsub.NewMessage(map[string]interface{}{"id": 4})))
+		return errors.New("rollback")
+	})
+
+	count, err := o.relay(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	count, err = o.relay(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Zero(t, countUnsent(t, o))
+
+	published := ps.published()
+	require.Len(t, published, 3)
+	for index, msg := range published {
+		require.Equal(t, ids[index], msg.ID())
+		require.Equal(t, pubsub.Topic("order.created"), msg.Topic())
+		require.Equal(t, "test", msg.Header("source"))
+		require.Equal(t, float64(index+1), msg.Data().(map[string]interface{})["id"])
+	}
+
+	// Sent rows past the retention are deleted
+	o.retention = 0
+	require.NoError(t, o.cleanup(ctx))
+
+	var total int64
+	require.NoError(t, db.Table(o.table).Count(&total).Error)
+	require.Zero(t, total)
+}
+
+func TestOutboxRetry(t *testing.T) {
+	ctx := context.Background()
+	o, db, ps := newTestOutbox(t)
+	ps.failTimes = 1
+
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return o.Publish(tx, "order.created", pubsub.NewMessage(map[string]interface{}{"id": 1}))
+	}))
+
+	count, err := o.relay(ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	var evt event
+	require.NoError(t, db.Table(o.table).First(&evt).Error)
+	require.Equal(t, 1, evt.Attempts)
+	require.Equal(t, "broker down", evt.LastError)
+	require.Nil(t, evt.SentAt)
+
+	// Published by the background relay once the broker is back
+	o.doneChan = make(chan struct{})
+	o.wg.Add(1)
+	go o.run(o.doneChan)
+	defer func() {
+		require.NoError(t, o.Stop())
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(ps.published()) == 1
+	}, time.Second*3, time.Millisecond*20)
+	require.Eventually(t, func() bool {
+		return countUnsent(t, o) == 0
+	}, time.Second*3, time.Millisecond*20)
+}
+
+func TestOutboxMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	o, db, ps := newTestOutbox(t)
+	o.maxAttempts = 2
+	ps.failTimes = 2
+
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		if err := o.Publish(tx, "order.created", pubsub.NewMessage(map[string]interface{}{"id": 1})); err != nil {
+			return err
+		}
+
+		return o.Publish(tx, "order.created", pubsub.NewMessage(map[string]interface{}{"id": 2}))
+	}))
+
+	for i := 0; i < 2; i++ {
+		count, err := o.relay(ctx)
+		require.NoError(t, err)
+		require.Zero(t, count)
+	}
+
+	// The failed message does not block the next ones
+	count, err := o.relay(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Len(t, ps.published(), 1)
+	require.Equal(t, float64(2), ps.published()[0].Data().(map[string]interface{})["id"])
+
+	var evt event
+	require.NoError(t, db.Table(o.table).Order("id").First(&evt).Error)
+	require.Equal(t, 2, evt.Attempts)
+	require.NotNil(t, evt.FailedAt)
+	require.Nil(t, evt.SentAt)
+
+	count, err = o.relay(ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestOutboxLease(t *testing.T) {
+	ctx := context.Background()
+	o, db, ps := newTestOutbox(t)
+	ps.block = make(chan struct{})
+
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return o.Publish(tx, "order.created", pubsub.NewMessage(map[string]interface{}{"id": 1}))
+	}))
+
+	relayed := make(chan int)
+	go func() {
+		count, err := o.relay(ctx)
+		require.NoError(t, err)
+		relayed <- count
+	}()
+
+	// Leased while published, so neither locked nor relayed again by another instance
+	require.Eventually(t, func() bool {
+		var evt event
+		require.NoError(t, db.Table(o.table).First(&evt).Error)
+		return evt.LockedUntil != nil
+	}, time.Second*3, time.Millisecond*20)
+
+	events, err := o.claim(ctx)
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	// No row lock is held while published
+	require.NoError(t, db.Table(o.table).Where("id > 0").Update("last_error", "").Error)
+
+	close(ps.block)
+	require.Equal(t, 1, <-relayed)
+
+	var evt event
+	require.NoError(t, db.Table(o.table).First(&evt).Error)
+	require.NotNil(t, evt.SentAt)
+	require.Nil(t, evt.LockedUntil)
+
+	// The messages of a stopped relay are claimed again once the lease expires
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return o.Publish(tx, "order.created", pubsub.NewMessage(map[string]interface{}{"id": 2}))
+	}))
+	require.NoError(t, db.Table(o.table).Where("sent_at IS NULL").Update("locked_until", time.Now().UTC().Add(-time.Second)).Error)
+
+	events, err = o.claim(ctx)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+}
+
+func TestOutboxPublishTimeout(t *testing.T) {
+	ctx := context.Background()
+	o, db, ps := newTestOutbox(t)
+	o.publishTimeout = time.Millisecond * 50
+	ps.block = make(chan struct{})
+
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		if err := o.Publish(tx, "order.created", pubsub.NewMessage(map[string]interface{}{"id": 1})); err != nil {
+			return err
+		}
+
+		return o.Publish(tx, "order.created", pubsub.NewMessage(map[string]interface{}{"id": 2}))
+	}))
+
+	count, err := o.relay(ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// The batch is released to be published in order at the next poll
+	var events []event
+	require.NoError(t, db.Table(o.table).Order("id").Find(&events).Error)
+	require.Len(t, events, 2)
+	require.Equal(t, 1, events[0].Attempts)
+	require.Contains(t, events[0].LastError, context.DeadlineExceeded.Error())
+	require.Nil(t, events[0].LockedUntil)
+	require.Zero(t, events[1].Attempts)
+	require.Nil(t, events[1].LockedUntil)
+}
+
+func TestOutboxPendingLocking(t *testing.T) {
+	o, _, _ := newTestOutbox(t)
+
+	dialectors := map[string]gorm.Dialector{
+		"postgres": postgres.New(postgres.Config{DSN: "host=localhost"}),
+		"mysql":    mysql.New(mysql.Config{DSN: "user@tcp(localhost:3306)/db", SkipInitializeWithVersion: true}),
+	}
+
+	for name, dialector := range dialectors {
+		t.Run(name, func(t *testing.T) {
+			db, err := gorm.Open(dialector, &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+			require.NoError(t, err)
+
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				var events []event
+				return o.pending(tx, time.Now()).Find(&events)
+			})
+			require.Contains(t, sql, "sent_at IS NULL AND failed_at IS NULL AND (locked_until IS NULL OR locked_until <")
+			require.True(t, strings.HasSuffix(sql, "LIMIT 2 FOR UPDATE SKIP LOCKED"), sql)
+		})
+	}
+
+	// Not supported by SQLite
+	sql := o.db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var events []event
+		return o.pending(tx, time.Now()).Find(&events)
+	})
+	require.NotContains(t, sql, "FOR UPDATE")
+}
@@ -0,0 +1,147 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// envelope is the wire format of a message, the data is encoded by a codec.
+// A JSON payload is embedded as is into data to stay readable by any consumer,
+// a binary payload (protobuf, msgpack) is base64-encoded into payload
+type envelope struct {
+	ID          string            `json:"id"`
+	Topic       Topic             `json:"topic"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	ContentType string            `json:"content_type"`
+	Data        json.RawMessage   `json:"data,omitempty"`
+	Payload     []byte            `json:"payload,omitempty"`
+}
+
+// encodeEnvelope encodes the message with its own content type if set, the default codec otherwise
+func encodeEnvelope(msg *Message, codec Codec) ([]byte, error) {
+	if msg.contentType != "" {
+		var err error
+		if codec, err = codecOf(msg.contentType); err != nil {
+			return nil, err
+		}
+	}
+
+	payload := msg.payload
+	if payload == nil {
+		var err error
+		if payload, err = codec.Marshal(msg.data); err != nil {
+			return nil, err
+		}
+	}
+
+	env := envelope{
+		ID:          msg.id,
+		Topic:       msg.topic,
+		Headers:     msg.headers,
+		CreatedAt:   msg.createdAt,
+		ContentType: codec.ContentType(),
+	}
+
+	// A raw payload received without envelope may not be valid JSON
+	if env.ContentType == ContentTypeJSON && json.Valid(payload) {
+		env.Data = payload
+	} else {
+		env.Payload = payload
+	}
+
+	return json.Marshal(env)
+}
+
+// MarshalMessage encodes the message with its envelope, the data in JSON if the message has no content type.
+// Ex: to store a message and publish it later, with the same ID
+func MarshalMessage(msg *Message) ([]byte, error) {
+	return encodeEnvelope(msg, jsonCodec{})
+}
+
+func UnmarshalMessage(data []byte) *Message {
+	return decodeEnvelope(data)
+}
+
+// decodeEnvelope falls back to a JSON payload without envelope, as sent by older publishers
+func decodeEnvelope(data []byte) *Message {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.ContentType == "" || env.ID == "" {
+		msg := NewMessage(nil)
+		msg.contentType = ContentTypeJSON
+		msg.payload = data
+		msg.data = decodeData(msg)
+
+		return msg
+	}
+
+	msg := &Message{
+		id:          env.ID,
+		topic:       env.Topic,
+		headers:     env.Headers,
+		createdAt:   env.CreatedAt,
+		contentType: env.ContentType,
+		payload:     env.Payload,
+	}
+
+	if env.Data != nil {
+		msg.payload = env.Data
+	}
+
+	if msg.headers == nil {
+		msg.headers = make(map[string]string)
+	}
+
+	msg.data = decodeData(msg)
+
+	return msg
+}
+
+// decodeData keeps Data() working without a type, ex: map[string]interface{} of a JSON object
+func decodeData(msg *Message) interface{} {
+	codec, err := codecOf(msg.contentType)
+	if err != nil || codec.ContentType() == ContentTypeProtobuf {
+		return nil
+	}
+
+	var data interface{}
+	_ = codec.Unmarshal(msg.payload, &data)
+
+	return data
+}
+
+// Decode decodes the message data into T, the same way for every backend
+func Decode[T any](msg *Message) (T, error) {
+	var value T
+	if data, ok := msg.data.(T); ok {
+		return data, nil
+	}
+
+	codec, err := codecOf(msg.contentType)
+	if err != nil {
+		return value, err
+	}
+
+	// Published locally, encode the data to convert it
+	payload := msg.payload
+	if payload == nil {
+		if payload, err = codec.Marshal(msg.data); err != nil {
+			return value, err
+		}
+	}
+
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Pointer {
+		err = codec.Unmarshal(payload, &value)
+		return value, err
+	}
+
+	// Unmarshal into a new value as a codec may require the pointer type, ex: protobuf
+	ptr := reflect.New(typ.Elem())
+	if err := codec.Unmarshal(payload, ptr.Interface()); err != nil {
+		return value, err
+	}
+
+	return ptr.Interface().(T), nil
+}
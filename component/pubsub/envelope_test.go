@@ -0,0 +1,135 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type orderCreated struct {
+	OrderID string  `json:"order_id" msgpack:"order_id"`
+	Amount  float64 `json:"amount" msgpack:"amount"`
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	for _, name := range []string{CodecJSON, CodecMsgpack} {
+		t.Run(name, func(t *testing.T) {
+			codec, err := NewCodec(name)
+			require.NoError(t, err)
+
+			msg := NewMessage(orderCreated{OrderID: "o-1", Amount: 9.5})
+			msg.SetTopic("order.created")
+			msg.SetHeader("source", "test")
+
+			data, err := encodeEnvelope(msg, codec)
+			require.NoError(t, err)
+
+			received := decodeEnvelope(data)
+			require.Equal(t, msg.ID(), received.ID())
+			require.Equal(t, msg.Topic(), received.Topic())
+			require.Equal(t, "test", received.Header("source"))
+			require.True(t, msg.CreatedAt().Equal(received.CreatedAt()))
+			require.Equal(t, codec.ContentType(), received.ContentType())
+
+			order, err := Decode[orderCreated](received)
+			require.NoError(t, err)
+			require.Equal(t, orderCreated{OrderID: "o-1", Amount: 9.5}, order)
+
+			ptr, err := Decode[*orderCreated](received)
+			require.NoError(t, err)
+			require.Equal(t, "o-1", ptr.OrderID)
+		})
+	}
+}
+
+func TestEnvelopeProtobuf(t *testing.T) {
+	codec, err := NewCodec(CodecJSON)
+	require.NoError(t, err)
+
+	// The message content type overrides the codec of the backend
+	msg := NewMessage(wrapperspb.String("o-1"))
+	msg.SetContentType(ContentTypeProtobuf)
+
+	data, err := encodeEnvelope(msg, codec)
+	require.NoError(t, err)
+
+	received := decodeEnvelope(data)
+	require.Equal(t, ContentTypeProtobuf, received.ContentType())
+
+	value, err := Decode[*wrapperspb.StringValue](received)
+	require.NoError(t, err)
+	require.Equal(t, "o-1", value.GetValue())
+
+	_, err = encodeEnvelope(NewMessage("o-1"), protobufCodec{})
+	require.ErrorIs(t, err, ErrNotProtoMessage)
+}
+
+func TestEnvelopeJSONData(t *testing.T) {
+	msg := NewMessage(orderCreated{OrderID: "o-1", Amount: 9.5})
+
+	data, err := encodeEnvelope(msg, jsonCodec{})
+	require.NoError(t, err)
+
+	// The JSON data is embedded as is, not base64-encoded
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &fields))
+	require.JSONEq(t, `{"order_id":"o-1","amount":9.5}`, string(fields["data"]))
+	require.NotContains(t, fields, "payload")
+
+	// Envelopes with a base64-encoded JSON payload are still decoded
+	legacy := []byte(`{"id":"m-1","topic":"order.created","created_at":"2024-01-01T00:00:00Z",` +
+		`"content_type":"application/json","payload":"eyJvcmRlcl9pZCI6Im8tMSJ9"}`)
+	received := decodeEnvelope(legacy)
+	require.Equal(t, "m-1", received.ID())
+
+	order, err := Decode[orderCreated](received)
+	require.NoError(t, err)
+	require.Equal(t, "o-1", order.OrderID)
+}
+
+func TestEnvelopeLegacyPayload(t *testing.T) {
+	received := decodeEnvelope([]byte(`{"order_id":"o-1","amount":9.5}`))
+	require.NotEmpty(t, received.ID())
+	require.Equal(t, "o-1", received.Data().(map[string]interface{})["order_id"])
+
+	order, err := Decode[orderCreated](received)
+	require.NoError(t, err)
+	require.Equal(t, 9.5, order.Amount)
+}
+
+func TestDecodeBackends(t *testing.T) {
+	ctx := context.Background()
+
+	local := NewLocalPubSub("pubsub")
+	require.NoError(t, local.Run(testAppContext{}))
+
+	nats := NewNatsPubSub("nats")
+	nats.url = runNatsServer(t).ClientURL()
+	nats.codecName = CodecMsgpack
+	require.NoError(t, nats.Run(testAppContext{}))
+
+	for name, ps := range map[string]PubSub{"local": local, "nats": nats} {
+		t.Run(name, func(t *testing.T) {
+			ch, unsubscribe := ps.Subscribe(ctx, "order.created")
+			defer unsubscribe()
+
+			msg := NewMessage(orderCreated{OrderID: "o-1", Amount: 9.5})
+			require.NoError(t, ps.Publish(ctx, "order.created", msg))
+
+			received := receive(t, ch)
+			require.Equal(t, msg.ID(), received.ID())
+			require.Equal(t, Topic("order.created"), received.Topic())
+
+			order, err := Decode[orderCreated](received)
+			require.NoError(t, err)
+			require.Equal(t, orderCreated{OrderID: "o-1", Amount: 9.5}, order)
+
+			fields, err := Decode[map[string]interface{}](received)
+			require.NoError(t, err)
+			require.Equal(t, "o-1", fields["order_id"])
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package pubsub
+
+import "errors"
+
+var (
+	ErrStorageNotSupported = errors.New("jetstream storage not supported")
+	ErrSubjectsRequired    = errors.New("jetstream stream subjects required")
+	ErrCodecNotSupported   = errors.New("codec not supported")
+	ErrNotProtoMessage     = errors.New("value is not a protobuf message")
+
+	ErrOverflowPolicyNotSupported = errors.New("overflow policy not supported")
+	ErrInvalidBufferSize          = errors.New("buffer size must be at least 1")
+	ErrPubSubClosed               = errors.New("pubsub closed")
+	ErrWildcardPublish            = errors.New("topic with wildcards cannot be published")
+	ErrWildcardNotSupported       = errors.New("wildcard subscriptions not supported")
+
+	ErrNoResponders        = errors.New("no responders of the request")
+	ErrNoReplyTopic        = errors.New("message has no reply topic")
+	ErrRequestNotSupported = errors.New("request not supported")
+)
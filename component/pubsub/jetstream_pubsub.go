@@ -0,0 +1,468 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/nats-io/nats.go"
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultStreamName    = "EVENTS"
+	defaultStorage       = "file"
+	defaultAckWait       = time.Second * 30
+	defaultMaxDeliver    = 5
+	defaultMaxAckPending = 1000
+	defaultFetchBatch    = 10
+	defaultFetchWait     = time.Second
+	// Ephemeral consumers left by a crashed instance are removed after it
+	defaultInactiveThreshold = time.Minute
+)
+
+type jetStreamOpt struct {
+	url            string
+	stream         string
+	subjects       []string
+	storage        string
+	replicas       int
+	maxAge         time.Duration
+	durable        string
+	deliverPolicy  string
+	ackWait        time.Duration
+	maxDeliver     int
+	maxAckPending  int
+	fetchBatchSize int
+	codecName      string
+}
+
+// Persistent pub/sub on NATS JetStream, messages are kept in a stream until acknowledged
+// by the consumers of each topic: ephemeral per subscription, durable per queue group
+type jetStreamPubSub struct {
+	id         string
+	connection *nats.Conn
+	js         nats.JetStreamContext
+	codec      Codec
+	logger     appctx.Logger
+	metricsObserver
+	*jetStreamOpt
+}
+
+func NewJetStreamPubSub(id string) *jetStreamPubSub {
+	return &jetStreamPubSub{
+		id:           id,
+		jetStreamOpt: new(jetStreamOpt),
+	}
+}
+
+func (ps *jetStreamPubSub) ID() string {
+	return ps.id
+}
+
+func (ps *jetStreamPubSub) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(
+		&ps.url,
+		"jetstream-url",
+		nats.DefaultURL,
+		fmt.Sprintf("JetStream NATS URL - Ex: %s", nats.DefaultURL),
+	)
+
+	fs.StringVar(
+		&ps.stream,
+		"jetstream-stream",
+		defaultStreamName,
+		fmt.Sprintf("JetStream stream name, created if not exists - Default: %s", defaultStreamName),
+	)
+
+	fs.StringSliceVar(
+		&ps.subjects,
+		"jetstream-subjects",
+		nil,
+		"JetStream stream subjects (topics) - Ex: user.>,order.*",
+	)
+
+	fs.StringVar(
+		&ps.storage,
+		"jetstream-storage",
+		defaultStorage,
+		fmt.Sprintf("JetStream stream storage (file | memory) - Default: %s", defaultStorage),
+	)
+
+	fs.IntVar(
+		&ps.replicas,
+		"jetstream-replicas",
+		1,
+		"JetStream stream replicas - Default: 1",
+	)
+
+	fs.DurationVar(
+		&ps.maxAge,
+		"jetstream-max-age",
+		0,
+		"JetStream stream max age of messages, 0 is unlimited - Default: 0",
+	)
+
+	fs.StringVar(
+		&ps.durable,
+		"jetstream-durable",
+		"",
+		"JetStream durable consumer group of QueueSubscribe with an empty group, the consumer of a topic is <group>_<topic> - Default: app name",
+	)
+
+	fs.StringVar(
+		&ps.deliverPolicy,
+		"jetstream-deliver-policy",
+		"all",
+		"JetStream deliver policy of new consumers (all | new | last) - Default: all",
+	)
+
+	fs.DurationVar(
+		&ps.ackWait,
+		"jetstream-ack-wait",
+		defaultAckWait,
+		fmt.Sprintf("JetStream time to wait for the ack before redelivery - Default: %s", defaultAckWait),
+	)
+
+	fs.IntVar(
+		&ps.maxDeliver,
+		"jetstream-max-deliver",
+		defaultMaxDeliver,
+		fmt.Sprintf("JetStream max deliveries of a message, -1 is unlimited - Default: %d", defaultMaxDeliver),
+	)
+
+	fs.IntVar(
+		&ps.maxAckPending,
+		"jetstream-max-ack-pending",
+		defaultMaxAckPending,
+		fmt.Sprintf("JetStream max unacknowledged messages per consumer - Default: %d", defaultMaxAckPending),
+	)
+
+	fs.IntVar(
+		&ps.fetchBatchSize,
+		"jetstream-fetch-batch-size",
+		defaultFetchBatch,
+		fmt.Sprintf("JetStream number of messages pulled per fetch - Default: %d", defaultFetchBatch),
+	)
+
+	fs.StringVar(
+		&ps.codecName,
+		"jetstream-codec",
+		CodecJSON,
+		fmt.Sprintf("JetStream message payload codec (%s | %s | %s) - Default: %s", CodecJSON, CodecProtobuf, CodecMsgpack, CodecJSON),
+	)
+
+	fs.StringVar(
+		&ps.metricsID,
+		"jetstream-metrics-id",
+		"",
+		"JetStream metrics component ID, empty disables metrics - Ex: metrics",
+	)
+}
+
+func (ps *jetStreamPubSub) Run(ac appctx.AppContext) error {
+	ps.logger = ac.Logger(ps.id)
+	ps.initObserver(ac, ps.id)
+
+	if ps.durable == "" {
+		ps.durable = ac.GetName()
+	}
+
+	if ps.durable == "" {
+		ps.durable = ps.id
+	}
+
+	codec, err := NewCodec(ps.codecName)
+	if err != nil {
+		ps.logger.Error(err, "Cannot init JetStream codec")
+		return err
+	}
+
+	ps.codec = codec
+
+	conn, err := nats.Connect(ps.url, setupNatsOptions(ps.logger, []nats.Option{})...)
+	if err != nil {
+		ps.logger.Error(err, "Cannot connect to NATS")
+		return err
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		ps.logger.Error(err, "Cannot init JetStream")
+		return err
+	}
+
+	ps.js = js
+
+	if err := ps.setupStream(); err != nil {
+		conn.Close()
+		ps.logger.Error(err, "Cannot setup JetStream stream")
+		return err
+	}
+
+	ps.connection = conn
+
+	ps.logger.Infof("Connected to NATS JetStream, stream %s", ps.stream)
+
+	return nil
+}
+
+func (ps *jetStreamPubSub) Stop() error {
+	if ps.connection == nil {
+		return nil
+	}
+
+	return ps.connection.Drain()
+}
+
+func (ps *jetStreamPubSub) setupStream() error {
+	storage := nats.FileStorage
+	switch ps.storage {
+	case "memory":
+		storage = nats.MemoryStorage
+	case defaultStorage, "":
+	default:
+		return ErrStorageNotSupported
+	}
+
+	if len(ps.subjects) == 0 {
+		return ErrSubjectsRequired
+	}
+
+	cfg := &nats.StreamConfig{
+		Name:     ps.stream,
+		Subjects: ps.subjects,
+		Storage:  storage,
+		Replicas: ps.replicas,
+		MaxAge:   ps.maxAge,
+	}
+
+	_, err := ps.js.StreamInfo(ps.stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = ps.js.AddStream(cfg)
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = ps.js.UpdateStream(cfg)
+
+	return err
+}
+
+func (ps *jetStreamPubSub) Publish(ctx context.Context, topic Topic, msg *Message) (err error) {
+	msg.SetTopic(topic)
+
+	_, span := startPublishSpan(ctx, "nats-jetstream", topic, msg)
+	defer func(startTime time.Time) {
+		ps.observePublish(topic, startTime, err)
+		EndSpan(span, err)
+	}(time.Now())
+
+	data, err := encodeEnvelope(msg, ps.codec)
+	if err != nil {
+		return err
+	}
+
+	natsMsg := nats.NewMsg(string(topic))
+	natsMsg.Data = data
+
+	// Deduplicated by JetStream within the duplicate window
+	natsMsg.Header.Set(nats.MsgIdHdr, msg.ID())
+
+	if _, err := ps.js.PublishMsg(natsMsg, nats.Context(ctx)); err != nil {
+		ps.logger.Error(err, "Cannot publish message")
+		return err
+	}
+
+	return nil
+}
+
+// Subscribe creates an ephemeral consumer, so every subscriber receives every message.
+// It starts from the deliver policy and is deleted on unsubscribe
+func (ps *jetStreamPubSub) Subscribe(ctx context.Context, topic Topic) (ch <-chan *Message, unsubscribeFunc func()) {
+	return ps.subscribe(ctx, topic, "")
+}
+
+// QueueSubscribe binds to the durable consumer <group>_<topic>, each message goes to one member of the group.
+// The consumer keeps the messages published while no member is subscribed
+func (ps *jetStreamPubSub) QueueSubscribe(ctx context.Context, topic Topic, group string) (ch <-chan *Message, unsubscribeFunc func()) {
+	if group == "" {
+		group = ps.durable
+	}
+
+	return ps.subscribe(ctx, topic, consumerName(group, topic))
+}
+
+func (ps *jetStreamPubSub) subscribe(ctx context.Context, topic Topic, durable string) (ch <-chan *Message, unsubscribeFunc func()) {
+	msgChan := make(chan *Message)
+	doneChan := make(chan struct{})
+
+	sub, err := ps.pullSubscribe(topic, durable)
+	if err != nil {
+		ps.logger.Errorf(err, "Cannot subscribe topic %s", topic)
+		// Closed so the subscriber does not wait forever
+		close(msgChan)
+		return msgChan, func() {}
+	}
+
+	// The existing consumer keeps its max deliver if it cannot be updated
+	maxDeliver := ps.maxDeliver
+	if info, err := sub.ConsumerInfo(); err == nil {
+		maxDeliver = info.Config.MaxDeliver
+	}
+
+	var once sync.Once
+
+	// Ends on unsubscribe or once the connection is drained on stop
+	go func() {
+		defer close(msgChan)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-doneChan:
+				return
+			default:
+			}
+
+			msgs, err := sub.Fetch(ps.fetchBatchSize, nats.MaxWait(defaultFetchWait))
+			if err != nil {
+				if errors.Is(err, nats.ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+					continue
+				}
+
+				if errors.Is(err, nats.ErrBadSubscription) || errors.Is(err, nats.ErrConnectionClosed) ||
+					errors.Is(err, nats.ErrConnectionDraining) || ps.connection.IsClosed() {
+					return
+				}
+
+				ps.logger.Errorf(err, "Cannot fetch messages of topic %s", topic)
+				time.Sleep(defaultFetchWait)
+				continue
+			}
+
+			for _, msg := range msgs {
+				newMsg := ps.toMessage(msg, maxDeliver)
+
+				select {
+				case msgChan <- newMsg:
+					ps.observeDeliver(newMsg)
+				case <-doneChan:
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return msgChan, func() {
+		once.Do(func() {
+			close(doneChan)
+			// A durable consumer is bound, not deleted, so it keeps the messages published meanwhile
+			_ = sub.Unsubscribe()
+		})
+	}
+}
+
+// pullSubscribe binds to the durable consumer of the topic, created or updated with the flags.
+// An empty durable creates an ephemeral consumer
+func (ps *jetStreamPubSub) pullSubscribe(topic Topic, durable string) (*nats.Subscription, error) {
+	deliverPolicy, deliverOpt := nats.DeliverAllPolicy, nats.DeliverAll()
+	switch ps.deliverPolicy {
+	case "new":
+		deliverPolicy, deliverOpt = nats.DeliverNewPolicy, nats.DeliverNew()
+	case "last":
+		deliverPolicy, deliverOpt = nats.DeliverLastPolicy, nats.DeliverLast()
+	}
+
+	if durable == "" {
+		// Created by the subscription, so deleted on unsubscribe
+		return ps.js.PullSubscribe(string(topic), "",
+			nats.BindStream(ps.stream),
+			nats.AckExplicit(),
+			deliverOpt,
+			nats.AckWait(ps.ackWait),
+			nats.MaxDeliver(ps.maxDeliver),
+			nats.MaxAckPending(ps.maxAckPending),
+			nats.InactiveThreshold(defaultInactiveThreshold),
+		)
+	}
+
+	cfg := &nats.ConsumerConfig{
+		Durable:       durable,
+		FilterSubject: string(topic),
+		AckPolicy:     nats.AckExplicitPolicy,
+		DeliverPolicy: deliverPolicy,
+		AckWait:       ps.ackWait,
+		MaxDeliver:    ps.maxDeliver,
+		MaxAckPending: ps.maxAckPending,
+	}
+
+	_, err := ps.js.ConsumerInfo(ps.stream, durable)
+	if errors.Is(err, nats.ErrConsumerNotFound) {
+		_, err = ps.js.AddConsumer(ps.stream, cfg)
+	} else if err == nil {
+		if _, err := ps.js.UpdateConsumer(ps.stream, cfg); err != nil {
+			// Some fields cannot be updated, keep the existing consumer
+			ps.logger.Warnf("Cannot update consumer %s: %v", durable, err)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ps.js.PullSubscribe(string(topic), durable, nats.Bind(ps.stream, durable))
+}
+
+// Escape the characters not allowed in consumer names with "~", so the names of different groups and topics never collide
+var (
+	groupEscaper = strings.NewReplacer("~", "~t", "_", "~u", "*", "~s", ">", "~g", ".", "~d")
+	topicEscaper = strings.NewReplacer("~", "~t", "_", "~u", "*", "~s", ">", "~g", ".", "_")
+)
+
+// consumerName is <group>_<topic>, the dots of the topic are replaced by "_"
+func consumerName(group string, topic Topic) string {
+	return groupEscaper.Replace(group) + "_" + topicEscaper.Replace(string(topic))
+}
+
+func (ps *jetStreamPubSub) toMessage(msg *nats.Msg, maxDeliver int) *Message {
+	newMsg := decodeEnvelope(msg.Data)
+	newMsg.SetTopic(Topic(msg.Subject))
+
+	if maxDeliver > 0 {
+		newMsg.SetMaxDeliveryCount(maxDeliver)
+	}
+
+	if meta, err := msg.Metadata(); err == nil {
+		newMsg.SetDeliveryCount(int(meta.NumDelivered))
+	}
+
+	newMsg.SetAckFunc(func() error {
+		return msg.Ack()
+	})
+
+	newMsg.SetNakFunc(func(delay time.Duration) error {
+		if delay > 0 {
+			return msg.NakWithDelay(delay)
+		}
+
+		return msg.Nak()
+	})
+
+	newMsg.SetInProgressFunc(func() error {
+		return msg.InProgress()
+	})
+
+	return newMsg
+}
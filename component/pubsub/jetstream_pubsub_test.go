@@ -0,0 +1,264 @@
+package pubsub
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/require"
+)
+
+type testAppContext struct {
+	appctx.AppContext
+}
+
+func (testAppContext) GetName() string {
+	return "test"
+}
+
+func (testAppContext) Logger(prefix string) appctx.Logger {
+	return appctx.NewAppLogger("test", io.Discard).GetLogger(prefix)
+}
+
+func runNatsServer(t *testing.T) *server.Server {
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+
+	go ns.Start()
+	require.True(t, ns.ReadyForConnections(time.Second*5))
+	t.Cleanup(ns.Shutdown)
+
+	return ns
+}
+
+func newTestJetStream(t *testing.T, ns *server.Server) *jetStreamPubSub {
+	ps := NewJetStreamPubSub("jetstream")
+	ps.url = ns.ClientURL()
+	ps.stream = defaultStreamName
+	ps.subjects = []string{"order.>"}
+	ps.storage = "memory"
+	ps.replicas = 1
+	ps.deliverPolicy = "all"
+	ps.ackWait = time.Millisecond * 200
+	ps.maxDeliver = 3
+	ps.maxAckPending = defaultMaxAckPending
+	ps.fetchBatchSize = defaultFetchBatch
+	ps.codecName = CodecJSON
+
+	require.NoError(t, ps.Run(testAppContext{}))
+	t.Cleanup(func() {
+		_ = ps.Stop()
+	})
+
+	return ps
+}
+
+func receive(t *testing.T, ch <-chan *Message) *Message {
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(time.Second * 3):
+		t.Fatal("message not received")
+		return nil
+	}
+}
+
+func assertNoMessage(t *testing.T, ch <-chan *Message, wait time.Duration) {
+	select {
+	case msg := <-ch:
+		t.Fatalf("unexpected message %s", msg)
+	case <-time.After(wait):
+	}
+}
+
+func TestJetStreamDurable(t *testing.T) {
+	ctx := context.Background()
+	ps := newTestJetStream(t, runNatsServer(t))
+
+	// Published before any subscriber is up
+	msg := NewMessage(map[string]interface{}{"id": 1})
+	msg.SetHeader("source", "test")
+	require.NoError(t, ps.Publish(ctx, "order.created", msg))
+
+	ch, unsubscribe := ps.QueueSubscribe(ctx, "order.created", "")
+
+	received := receive(t, ch)
+	require.Equal(t, msg.ID(), received.ID())
+	require.Equal(t, "test", received.Header("source"))
+	require.Equal(t, float64(1), received.Data().(map[string]interface{})["id"])
+	require.NoError(t, received.Ack())
+	unsubscribe()
+
+	// The durable consumer keeps the messages published while unsubscribed
+	require.NoError(t, ps.Publish(ctx, "order.created", NewMessage(map[string]interface{}{"id": 2})))
+
+	ch, unsubscribe = ps.QueueSubscribe(ctx, "order.created", "")
+	defer unsubscribe()
+
+	received = receive(t, ch)
+	require.Equal(t, float64(2), received.Data().(map[string]interface{})["id"])
+	require.NoError(t, received.Ack())
+
+	assertNoMessage(t, ch, time.Millisecond*400)
+}
+
+func TestJetStreamSubscribeBroadcast(t *testing.T) {
+	ctx := context.Background()
+	ns := runNatsServer(t)
+	instance1 := newTestJetStream(t, ns)
+	instance2 := newTestJetStream(t, ns)
+
+	// Two subscribers in one instance and one in another
+	ch1, unsubscribe1 := instance1.Subscribe(ctx, "order.created")
+	defer unsubscribe1()
+	ch2, unsubscribe2 := instance1.Subscribe(ctx, "order.created")
+	defer unsubscribe2()
+	ch3, unsubscribe3 := instance2.Subscribe(ctx, "order.*")
+	defer unsubscribe3()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, instance1.Publish(ctx, "order.created", NewMessage(map[string]interface{}{"id": i})))
+	}
+
+	for _, ch := range []<-chan *Message{ch1, ch2, ch3} {
+		for i := 0; i < 3; i++ {
+			received := receive(t, ch)
+			require.Equal(t, float64(i), received.Data().(map[string]interface{})["id"])
+			require.NoError(t, received.Ack())
+		}
+	}
+}
+
+func TestConsumerName(t *testing.T) {
+	require.Equal(t, "app_order_created", consumerName("app", "order.created"))
+
+	// Wildcards, separators and escapes do not collide with literal names
+	names := make(map[string]bool)
+	for _, name := range []string{
+		consumerName("app", "order.*"),
+		consumerName("app", "order.any"),
+		consumerName("app", "order.>"),
+		consumerName("app", "order.all"),
+		consumerName("app", "order_created"),
+		consumerName("app", "order~u"),
+		consumerName("app_order", "created"),
+		consumerName("app.order", "created"),
+	} {
+		require.False(t, names[name], name)
+		require.NotContains(t, name, ".")
+		require.NotContains(t, name, "*")
+		require.NotContains(t, name, ">")
+		names[name] = true
+	}
+}
+
+func TestJetStreamRedelivery(t *testing.T) {
+	ctx := context.Background()
+	ps := newTestJetStream(t, runNatsServer(t))
+
+	ch, unsubscribe := ps.Subscribe(ctx, "order.paid")
+	defer unsubscribe()
+
+	require.NoError(t, ps.Publish(ctx, "order.paid", NewMessage(map[string]interface{}{"id": 1})))
+
+	// Nak, then no ack until ack wait, then ack
+	first := receive(t, ch)
+	require.NoError(t, first.Nak())
+
+	second := receive(t, ch)
+	require.Equal(t, first.ID(), second.ID())
+	require.NoError(t, second.InProgress())
+
+	third := receive(t, ch)
+	require.Equal(t, first.ID(), third.ID())
+	require.Equal(t, 3, third.DeliveryCount())
+	require.True(t, third.IsLastDelivery())
+	require.False(t, first.IsLastDelivery())
+
+	// Max deliver reached
+	assertNoMessage(t, ch, time.Millisecond*400)
+}
+
+func TestJetStreamSubjectsRequired(t *testing.T) {
+	ps := NewJetStreamPubSub("jetstream")
+	ps.url = runNatsServer(t).ClientURL()
+	ps.stream = defaultStreamName
+	ps.codecName = CodecJSON
+
+	require.ErrorIs(t, ps.Run(testAppContext{}), ErrSubjectsRequired)
+}
+
+func TestJetStreamQueueSubscribe(t *testing.T) {
+	ctx := context.Background()
+	ns := runNatsServer(t)
+	instance1 := newTestJetStream(t, ns)
+	instance2 := newTestJetStream(t, ns)
+
+	// Leave room for slow runs so a batch is not redelivered before it is acked
+	instance1.ackWait = time.Second * 5
+	instance2.ackWait = time.Second * 5
+
+	member1, unsubscribe1 := instance1.QueueSubscribe(ctx, "order.shipped", "workers")
+	defer unsubscribe1()
+	member2, unsubscribe2 := instance2.QueueSubscribe(ctx, "order.shipped", "workers")
+	defer unsubscribe2()
+
+	const total = 10
+	ids := make(chan string, total*2)
+	for _, ch := range []<-chan *Message{member1, member2} {
+		go func(ch <-chan *Message) {
+			for msg := range ch {
+				_ = msg.Ack()
+				ids <- msg.ID()
+			}
+		}(ch)
+	}
+
+	for i := 0; i < total; i++ {
+		require.NoError(t, instance1.Publish(ctx, "order.shipped", NewMessage(map[string]interface{}{"id": i})))
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < total; i++ {
+		select {
+		case id := <-ids:
+			require.False(t, seen[id])
+			seen[id] = true
+		case <-time.After(time.Second * 3):
+			t.Fatal("message not received")
+		}
+	}
+
+	select {
+	case <-ids:
+		t.Fatal("message received twice")
+	case <-time.After(time.Millisecond * 300):
+	}
+}
+
+func TestJetStreamClose(t *testing.T) {
+	ctx := context.Background()
+	ps := newTestJetStream(t, runNatsServer(t))
+
+	// Outside of the subjects of the stream
+	ch, _ := ps.Subscribe(ctx, "payment.created")
+	assertClosed(t, ch)
+
+	ch, unsubscribe := ps.Subscribe(ctx, "order.created")
+	unsubscribe()
+	assertClosed(t, ch)
+
+	ch, _ = ps.Subscribe(ctx, "order.paid")
+	require.NoError(t, ps.Stop())
+	assertClosed(t, ch)
+}
@@ -2,113 +2,446 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	appctx "github.com/hoangtk0100/app-context"
-	"github.com/hoangtk0100/app-context/util"
+	"github.com/spf13/pflag"
+)
+
+const (
+	OverflowBlock      = "block"
+	OverflowDropOldest = "drop-oldest"
+	OverflowDropNewest = "drop-newest"
+
+	defaultLocalBufferSize   = 1000
+	defaultLocalDrainTimeout = time.Second * 5
+
+	inboxPrefix = "_INBOX"
 )
 
 // In-memory
-// Buffer channel as queue
-// Transmission of messages with specific topic to all subscribers within a group
+// Each subscriber has a bounded buffer, messages of a topic are delivered in publish order.
+// A full buffer blocks the publisher or drops a message, depending on the overflow policy.
+// Wildcard patterns are matched with a trie of the subscribed patterns
 type localPubSub struct {
-	id           string
-	messageQueue chan *Message
-	mapTopic     map[Topic][]chan *Message
-	locker       *sync.RWMutex
-	logger       appctx.Logger
+	id             string
+	bufferSize     int
+	overflowPolicy string
+	drainTimeout   time.Duration
+	topics         map[Topic]*localTopic
+	trie           *topicNode
+	// Serialize the publishers of a topic to keep the order for all subscribers
+	publishLockers map[Topic]*publishLocker
+	locker         *sync.RWMutex
+	closed         bool
+	logger         appctx.Logger
+	metricsObserver
+}
+
+// localTopic holds the subscriptions of a topic or pattern
+type localTopic struct {
+	subs   []*localSubscriber
+	groups map[string]*subGroup
 }
 
 func NewLocalPubSub(id string) *localPubSub {
 	return &localPubSub{
-		id:           id,
-		messageQueue: make(chan *Message, 10000),
-		mapTopic:     make(map[Topic][]chan *Message),
-		locker:       new(sync.RWMutex),
+		id:             id,
+		bufferSize:     defaultLocalBufferSize,
+		overflowPolicy: OverflowBlock,
+		drainTimeout:   defaultLocalDrainTimeout,
+		topics:         make(map[Topic]*localTopic),
+		trie:           newTopicNode(),
+		publishLockers: make(map[Topic]*publishLocker),
+		locker:         new(sync.RWMutex),
+		// Replaced on Run, used by the subscriptions of a pub/sub not run
+		logger: appctx.GlobalLogger().GetLogger(id),
 	}
 }
 
-func (ps *localPubSub) Publish(ctx context.Context, topic Topic, msg *Message) error {
-	msg.SetTopic(topic)
+func (ps *localPubSub) ID() string {
+	return ps.id
+}
 
-	go func() {
-		defer util.Recovery()
+func (ps *localPubSub) InitFlags(fs *pflag.FlagSet) {
+	fs.IntVar(
+		&ps.bufferSize,
+		"local-pubsub-buffer-size",
+		defaultLocalBufferSize,
+		fmt.Sprintf("Local pub/sub buffer size of each subscriber, at least 1 - Default: %d", defaultLocalBufferSize),
+	)
 
-		ps.messageQueue <- msg
-		ps.logger.Info("New message published :", msg.String())
-	}()
+	fs.StringVar(
+		&ps.overflowPolicy,
+		"local-pubsub-overflow-policy",
+		OverflowBlock,
+		fmt.Sprintf("Local pub/sub policy when a subscriber buffer is full (%s | %s | %s) - Default: %s",
+			OverflowBlock, OverflowDropOldest, OverflowDropNewest, OverflowBlock),
+	)
 
-	return nil
+	fs.DurationVar(
+		&ps.drainTimeout,
+		"local-pubsub-drain-timeout",
+		defaultLocalDrainTimeout,
+		fmt.Sprintf("Local pub/sub max time to wait on stop for subscribers to drain their buffers - Default: %s", defaultLocalDrainTimeout),
+	)
+
+	fs.StringVar(
+		&ps.metricsID,
+		"local-pubsub-metrics-id",
+		"",
+		"Local pub/sub metrics component ID, empty disables metrics - Ex: metrics",
+	)
 }
 
-func (ps *localPubSub) Subscribe(ctx context.Context, topic Topic) (ch <-chan *Message, unsubscribe func()) {
-	c := make(chan *Message)
+func (ps *localPubSub) Run(ac appctx.AppContext) error {
+	ps.logger = ac.Logger(ps.id)
+
+	switch ps.overflowPolicy {
+	case OverflowBlock, OverflowDropOldest, OverflowDropNewest:
+	default:
+		return ErrOverflowPolicyNotSupported
+	}
+
+	// A subscriber without buffer would never receive a message with the drop policies
+	if ps.bufferSize < 1 {
+		return ErrInvalidBufferSize
+	}
 
+	ps.initObserver(ac, ps.id)
+
+	return nil
+}
+
+// Stop rejects new messages, waits for the subscribers to drain their buffers then closes their channels
+func (ps *localPubSub) Stop() error {
 	ps.locker.Lock()
+	ps.closed = true
 
-	val, ok := ps.mapTopic[topic]
-	if ok {
-		val = append(ps.mapTopic[topic], c)
-		ps.mapTopic[topic] = val
-	} else {
-		ps.mapTopic[topic] = []chan *Message{c}
+	var subs []*localSubscriber
+	for _, t := range ps.topics {
+		subs = append(subs, t.subs...)
+		for _, g := range t.groups {
+			subs = append(subs, g.members...)
+		}
 	}
 
+	ps.topics = make(map[Topic]*localTopic)
+	ps.trie = newTopicNode()
 	ps.locker.Unlock()
 
-	return c, func() {
-		ps.logger.Info("Unsubscribe :", topic)
+	deadline := time.Now().Add(ps.drainTimeout)
+	for _, sub := range subs {
+		for len(sub.ch) > 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 10)
+		}
+
+		sub.close()
+	}
+
+	return nil
+}
+
+func (ps *localPubSub) Publish(ctx context.Context, topic Topic, msg *Message) (err error) {
+	msg.SetTopic(topic)
 
-		if chans, ok := ps.mapTopic[topic]; ok {
-			for index := range chans {
-				if chans[index] == c {
-					chans = append(chans[:index], chans[index+1:]...)
+	ctx, span := startPublishSpan(ctx, "local", topic, msg)
+	defer func(startTime time.Time) {
+		ps.observePublish(topic, startTime, err)
+		EndSpan(span, err)
+	}(time.Now())
 
-					ps.locker.Lock()
-					ps.mapTopic[topic] = chans
-					ps.locker.Unlock()
-				}
+	_, err = ps.publish(ctx, topic, msg)
+
+	return err
+}
+
+// publishLocker is shared by the concurrent publishers of a topic, it is deleted when the last one is done
+type publishLocker struct {
+	sync.Mutex
+	refs int
+}
+
+// publish returns the number of subscribers the message is delivered to
+func (ps *localPubSub) publish(ctx context.Context, topic Topic, msg *Message) (int, error) {
+	if topic.IsWildcard() {
+		return 0, ErrWildcardPublish
+	}
+
+	ps.locker.Lock()
+	locker, ok := ps.publishLockers[topic]
+	if !ok {
+		locker = new(publishLocker)
+		ps.publishLockers[topic] = locker
+	}
+	locker.refs++
+	ps.locker.Unlock()
+
+	defer func() {
+		ps.locker.Lock()
+		locker.refs--
+		if locker.refs == 0 {
+			delete(ps.publishLockers, topic)
+		}
+		ps.locker.Unlock()
+	}()
+
+	locker.Lock()
+	defer locker.Unlock()
+
+	return ps.deliver(ctx, topic, msg)
+}
+
+// deliver sends the message to the matching subscribers, the caller keeps the order of the topic
+func (ps *localPubSub) deliver(ctx context.Context, topic Topic, msg *Message) (int, error) {
+	// Picking a group member moves the round-robin cursor
+	ps.locker.Lock()
+	if ps.closed {
+		ps.locker.Unlock()
+		return 0, ErrPubSubClosed
+	}
+
+	var subs []*localSubscriber
+	for _, t := range ps.trie.match(topic.tokens(), nil) {
+		subs = append(subs, t.subs...)
+		for _, g := range t.groups {
+			if len(g.members) > 0 {
+				subs = append(subs, g.pick())
 			}
 		}
 	}
-}
+	ps.locker.Unlock()
 
-func (ps *localPubSub) ID() string {
-	return ps.id
+	for _, sub := range subs {
+		if err := ps.send(ctx, sub, msg); err != nil {
+			return 0, err
+		}
+
+		ps.observeDeliver(msg)
+	}
+
+	return len(subs), nil
 }
 
-func (*localPubSub) InitFlags() {
+// Request is emulated with an inbox topic, the first reply published to it is returned
+func (ps *localPubSub) Request(ctx context.Context, topic Topic, msg *Message) (reply *Message, err error) {
+	msg.SetTopic(topic)
+
+	ctx, span := startPublishSpan(ctx, "local", topic, msg)
+	defer func(startTime time.Time) {
+		ps.observePublish(topic, startTime, err)
+		EndSpan(span, err)
+	}(time.Now())
+
+	inbox := Topic(fmt.Sprintf("%s.%s", inboxPrefix, uuid.NewString()))
+	ch, unsubscribe := ps.Subscribe(ctx, inbox)
+	defer unsubscribe()
+
+	// Only the first reply is read, no order to keep
+	msg.SetReplyFunc(inbox, func(ctx context.Context, reply *Message) error {
+		reply.SetTopic(inbox)
+		_, err := ps.deliver(ctx, inbox, reply)
+		return err
+	})
+
+	count, err := ps.publish(ctx, topic, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, ErrNoResponders
+	}
+
+	select {
+	case reply, ok := <-ch:
+		if !ok {
+			return nil, ErrPubSubClosed
+		}
+
+		return reply, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
-// Send message from message queue to subscribed topic channels
-func (ps *localPubSub) Run(ac appctx.AppContext) error {
-	ps.logger = ac.Logger(ps.id)
+// send applies the overflow policy when the subscriber buffer is full
+func (ps *localPubSub) send(ctx context.Context, sub *localSubscriber, msg *Message) error {
+	sub.locker.RLock()
+	defer sub.locker.RUnlock()
 
-	go func() {
-		defer util.Recovery()
+	if sub.closed {
+		return nil
+	}
 
+	switch ps.overflowPolicy {
+	case OverflowDropNewest:
+		select {
+		case sub.ch <- msg:
+		default:
+			ps.logger.Warnf("Subscriber buffer of topic %s is full, message %s dropped", msg.Topic(), msg.ID())
+		}
+	case OverflowDropOldest:
 		for {
-			msg := <-ps.messageQueue
-			ps.logger.Info("Message dequeue :", msg.String())
-
-			ps.locker.RLock()
-
-			if subs, ok := ps.mapTopic[msg.Topic()]; ok {
-				for index := range subs {
-					go func(c chan *Message) {
-						defer util.Recovery()
-						c <- msg
-					}(subs[index])
-				}
+			select {
+			case sub.ch <- msg:
+				return nil
+			default:
 			}
 
-			ps.locker.RUnlock()
+			select {
+			case dropped := <-sub.ch:
+				ps.logger.Warnf("Subscriber buffer of topic %s is full, message %s dropped", msg.Topic(), dropped.ID())
+			default:
+			}
 		}
-	}()
+	default:
+		select {
+		case sub.ch <- msg:
+		case <-sub.doneChan:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 
 	return nil
 }
 
-func (*localPubSub) Stop() error {
-	return nil
+type localSubscriber struct {
+	ch       chan *Message
+	doneChan chan struct{}
+	locker   sync.RWMutex
+	once     sync.Once
+	closed   bool
+}
+
+func newLocalSubscriber(size int) *localSubscriber {
+	return &localSubscriber{
+		ch:       make(chan *Message, size),
+		doneChan: make(chan struct{}),
+	}
+}
+
+// close unblocks the pending sends first, then closes the channel once no send is in progress
+func (sub *localSubscriber) close() {
+	sub.once.Do(func() {
+		close(sub.doneChan)
+
+		sub.locker.Lock()
+		sub.closed = true
+		close(sub.ch)
+		sub.locker.Unlock()
+	})
+}
+
+// Members of a queue group, each message goes to one member by round-robin
+type subGroup struct {
+	members []*localSubscriber
+	next    int
+}
+
+func (g *subGroup) pick() *localSubscriber {
+	sub := g.members[g.next%len(g.members)]
+	g.next++
+
+	return sub
+}
+
+// getTopic returns the subscriptions of the topic or pattern, the caller holds the write lock
+func (ps *localPubSub) getTopic(topic Topic) *localTopic {
+	t, ok := ps.topics[topic]
+	if !ok {
+		t = &localTopic{groups: make(map[string]*subGroup)}
+		ps.topics[topic] = t
+		ps.trie.insert(topic, t)
+	}
+
+	return t
+}
+
+// removeTopicIfEmpty drops the topic or pattern without subscriptions, the caller holds the write lock
+func (ps *localPubSub) removeTopicIfEmpty(topic Topic, t *localTopic) {
+	if len(t.subs) > 0 || len(t.groups) > 0 || ps.topics[topic] != t {
+		return
+	}
+
+	delete(ps.topics, topic)
+	ps.trie.remove(topic.tokens())
+}
+
+func (ps *localPubSub) Subscribe(ctx context.Context, topic Topic) (ch <-chan *Message, unsubscribe func()) {
+	sub := newLocalSubscriber(ps.bufferSize)
+
+	ps.locker.Lock()
+	if ps.closed {
+		ps.locker.Unlock()
+		sub.close()
+		return sub.ch, func() {}
+	}
+
+	t := ps.getTopic(topic)
+	t.subs = append(t.subs, sub)
+	ps.locker.Unlock()
+
+	return sub.ch, func() {
+		ps.logger.Info("Unsubscribe :", topic)
+
+		ps.locker.Lock()
+		for index := range t.subs {
+			if t.subs[index] == sub {
+				t.subs = append(t.subs[:index:index], t.subs[index+1:]...)
+				break
+			}
+		}
+
+		ps.removeTopicIfEmpty(topic, t)
+		ps.locker.Unlock()
+
+		sub.close()
+	}
+}
+
+func (ps *localPubSub) QueueSubscribe(ctx context.Context, topic Topic, group string) (ch <-chan *Message, unsubscribe func()) {
+	sub := newLocalSubscriber(ps.bufferSize)
+
+	ps.locker.Lock()
+	if ps.closed {
+		ps.locker.Unlock()
+		sub.close()
+		return sub.ch, func() {}
+	}
+
+	t := ps.getTopic(topic)
+	g, ok := t.groups[group]
+	if !ok {
+		g = new(subGroup)
+		t.groups[group] = g
+	}
+
+	g.members = append(g.members, sub)
+	ps.locker.Unlock()
+
+	return sub.ch, func() {
+		ps.logger.Infof("Unsubscribe : %s (group %s)", topic, group)
+
+		ps.locker.Lock()
+		for index := range g.members {
+			if g.members[index] == sub {
+				g.members = append(g.members[:index:index], g.members[index+1:]...)
+				break
+			}
+		}
+
+		if len(g.members) == 0 && t.groups[group] == g {
+			delete(t.groups, group)
+		}
+
+		ps.removeTopicIfEmpty(topic, t)
+		ps.locker.Unlock()
+
+		sub.close()
+	}
 }
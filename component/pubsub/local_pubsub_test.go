@@ -0,0 +1,272 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalQueueSubscribe(t *testing.T) {
+	ctx := context.Background()
+	ps := NewLocalPubSub("pubsub")
+	require.NoError(t, ps.Run(testAppContext{}))
+
+	member1, unsubscribe1 := ps.QueueSubscribe(ctx, "order.created", "workers")
+	member2, unsubscribe2 := ps.QueueSubscribe(ctx, "order.created", "workers")
+	defer unsubscribe2()
+	broadcast, unsubscribe := ps.Subscribe(ctx, "order.created")
+	defer unsubscribe()
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, ps.Publish(ctx, "order.created", NewMessage(i)))
+		// Keep the publish order for the round-robin assertion
+		receive(t, broadcast)
+	}
+
+	counts := make(map[<-chan *Message]int)
+	for i := 0; i < 4; i++ {
+		select {
+		case <-member1:
+			counts[member1]++
+		case <-member2:
+			counts[member2]++
+		case <-time.After(time.Second):
+			t.Fatal("message not received")
+		}
+	}
+
+	require.Equal(t, 2, counts[member1])
+	require.Equal(t, 2, counts[member2])
+
+	// The remaining member receives all messages
+	unsubscribe1()
+	require.NoError(t, ps.Publish(ctx, "order.created", NewMessage(5)))
+	require.Equal(t, 5, receive(t, member2).Data())
+	receive(t, broadcast)
+
+	_, ok := <-member1
+	require.False(t, ok)
+}
+
+func newTestLocalPubSub(t *testing.T, bufferSize int, overflowPolicy string) *localPubSub {
+	ps := NewLocalPubSub("pubsub")
+	ps.bufferSize = bufferSize
+	ps.overflowPolicy = overflowPolicy
+	ps.drainTimeout = time.Second
+	require.NoError(t, ps.Run(testAppContext{}))
+
+	return ps
+}
+
+func TestLocalOrdering(t *testing.T) {
+	ctx := context.Background()
+	ps := newTestLocalPubSub(t, 10, OverflowBlock)
+
+	ch, unsubscribe := ps.Subscribe(ctx, "order.created")
+	defer unsubscribe()
+
+	const total = 100
+	go func() {
+		for i := 0; i < total; i++ {
+			_ = ps.Publish(ctx, "order.created", NewMessage(i))
+		}
+	}()
+
+	for i := 0; i < total; i++ {
+		require.Equal(t, i, receive(t, ch).Data())
+	}
+}
+
+func TestLocalPublishLockers(t *testing.T) {
+	ctx := context.Background()
+	ps := newTestLocalPubSub(t, 10, OverflowBlock)
+
+	ch, unsubscribe := ps.Subscribe(ctx, "order.*")
+	defer unsubscribe()
+
+	// One topic per order
+	go func() {
+		for i := 0; i < 100; i++ {
+			_ = ps.Publish(ctx, Topic(fmt.Sprintf("order.%d", i)), NewMessage(i))
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		receive(t, ch)
+	}
+
+	// Deleted once the publishers of a topic are done
+	require.Eventually(t, func() bool {
+		ps.locker.RLock()
+		defer ps.locker.RUnlock()
+
+		return len(ps.publishLockers) == 0
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestLocalOverflowPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		policy   string
+		expected []int
+	}{
+		"drop newest": {policy: OverflowDropNewest, expected: []int{0, 1}},
+		"drop oldest": {policy: OverflowDropOldest, expected: []int{3, 4}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ps := newTestLocalPubSub(t, 2, tc.policy)
+
+			ch, unsubscribe := ps.Subscribe(ctx, "order.created")
+			defer unsubscribe()
+
+			for i := 0; i < 5; i++ {
+				require.NoError(t, ps.Publish(ctx, "order.created", NewMessage(i)))
+			}
+
+			for _, expected := range tc.expected {
+				require.Equal(t, expected, receive(t, ch).Data())
+			}
+
+			assertNoMessage(t, ch, time.Millisecond*50)
+		})
+	}
+
+	t.Run("block", func(t *testing.T) {
+		ps := newTestLocalPubSub(t, 1, OverflowBlock)
+
+		_, unsubscribe := ps.Subscribe(ctx, "order.created")
+		require.NoError(t, ps.Publish(ctx, "order.created", NewMessage(0)))
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+		defer cancel()
+		require.ErrorIs(t, ps.Publish(timeoutCtx, "order.created", NewMessage(1)), context.DeadlineExceeded)
+
+		// Unsubscribe unblocks the publisher
+		published := make(chan error)
+		go func() {
+			published <- ps.Publish(ctx, "order.created", NewMessage(2))
+		}()
+
+		time.Sleep(time.Millisecond * 50)
+		unsubscribe()
+		require.NoError(t, <-published)
+	})
+}
+
+func TestLocalStop(t *testing.T) {
+	ctx := context.Background()
+	ps := newTestLocalPubSub(t, 10, OverflowBlock)
+
+	ch, _ := ps.Subscribe(ctx, "order.created")
+	for i := 0; i < 3; i++ {
+		require.NoError(t, ps.Publish(ctx, "order.created", NewMessage(i)))
+	}
+
+	received := make(chan int, 3)
+	go func() {
+		for msg := range ch {
+			received <- msg.Data().(int)
+		}
+
+		close(received)
+	}()
+
+	// Waits for the buffer to drain then closes the subscriber channels
+	require.NoError(t, ps.Stop())
+
+	var values []int
+	for value := range received {
+		values = append(values, value)
+	}
+
+	require.Equal(t, []int{0, 1, 2}, values)
+	require.ErrorIs(t, ps.Publish(ctx, "order.created", NewMessage(3)), ErrPubSubClosed)
+}
+
+type requestPubSub interface {
+	PubSub
+	Requester
+}
+
+// testRequest replies with the doubled number of the request
+func testRequest(t *testing.T, ps requestPubSub) {
+	ctx := context.Background()
+
+	_, err := ps.Request(ctx, "math.double", NewMessage(map[string]interface{}{"n": 1}))
+	require.ErrorIs(t, err, ErrNoResponders)
+
+	requests, unsubscribe := ps.Subscribe(ctx, "math.double")
+	defer unsubscribe()
+
+	go func() {
+		for msg := range requests {
+			data, err := Decode[map[string]int](msg)
+			if err == nil && data["n"] > 0 {
+				_ = msg.Reply(ctx, NewMessage(map[string]interface{}{"n": data["n"] * 2}))
+			}
+		}
+	}()
+
+	requestCtx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+
+	reply, err := ps.Request(requestCtx, "math.double", NewMessage(map[string]interface{}{"n": 21}))
+	require.NoError(t, err)
+
+	result, err := Decode[map[string]int](reply)
+	require.NoError(t, err)
+	require.Equal(t, 42, result["n"])
+
+	// No reply to a request with n = 0
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, time.Millisecond*100)
+	defer timeoutCancel()
+
+	_, err = ps.Request(timeoutCtx, "math.double", NewMessage(map[string]interface{}{"n": 0}))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.ErrorIs(t, NewMessage(nil).Reply(ctx, NewMessage(nil)), ErrNoReplyTopic)
+}
+
+func TestLocalRequest(t *testing.T) {
+	ps := NewLocalPubSub("pubsub")
+	require.NoError(t, ps.Run(testAppContext{}))
+
+	testRequest(t, ps)
+
+	// Nothing left of the inbox topics
+	ps.locker.RLock()
+	defer ps.locker.RUnlock()
+	require.Empty(t, ps.publishLockers)
+	require.Empty(t, ps.topics)
+}
+
+func TestLocalInvalidConfig(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		ps := NewLocalPubSub("pubsub")
+		ps.bufferSize = size
+		require.ErrorIs(t, ps.Run(testAppContext{}), ErrInvalidBufferSize)
+	}
+
+	ps := NewLocalPubSub("pubsub")
+	ps.overflowPolicy = "drop-all"
+	require.ErrorIs(t, ps.Run(testAppContext{}), ErrOverflowPolicyNotSupported)
+}
+
+func TestLocalUnsubscribeNotRun(t *testing.T) {
+	ctx := context.Background()
+	ps := NewLocalPubSub("pubsub")
+
+	_, unsubscribe := ps.Subscribe(ctx, "order.created")
+	_, unsubscribeGroup := ps.QueueSubscribe(ctx, "order.created", "workers")
+
+	require.NotPanics(t, func() {
+		unsubscribe()
+		unsubscribeGroup()
+	})
+}
@@ -1,26 +1,39 @@
 package pubsub
 
 import (
+	"context"
 	"fmt"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Topic string
 
 type Message struct {
-	id        string
-	topic     Topic // ignorable
-	data      interface{}
-	createdAt time.Time
-	ackFunc   func() error
+	id          string
+	topic       Topic // ignorable
+	data        interface{}
+	headers     map[string]string
+	createdAt   time.Time
+	contentType string
+	payload     []byte // encoded data of a received message
+	delivery    int
+	maxDelivery int
+	replyTo     Topic
+	replyFunc   func(ctx context.Context, reply *Message) error
+	ackFunc     func() error
+	nakFunc     func(delay time.Duration) error
+	progFunc    func() error
 }
 
 func NewMessage(data interface{}) *Message {
 	now := time.Now().UTC()
 
 	return &Message{
-		id:        fmt.Sprintf("%d", now.UnixNano()),
+		id:        uuid.NewString(),
 		data:      data,
+		headers:   make(map[string]string),
 		createdAt: now,
 	}
 }
@@ -29,6 +42,14 @@ func (m *Message) String() string {
 	return fmt.Sprintf("Message %s value %v", m.topic, m.data)
 }
 
+func (m *Message) ID() string {
+	return m.id
+}
+
+func (m *Message) CreatedAt() time.Time {
+	return m.createdAt
+}
+
 func (m *Message) Topic() Topic {
 	return m.topic
 }
@@ -41,10 +62,133 @@ func (m *Message) Data() interface{} {
 	return m.data
 }
 
+// ContentType of the payload, empty uses the codec of the backend
+func (m *Message) ContentType() string {
+	return m.contentType
+}
+
+// SetContentType overrides the codec of the backend for this message, ex: ContentTypeProtobuf
+func (m *Message) SetContentType(contentType string) {
+	m.contentType = contentType
+}
+
+// Headers carries metadata along with the data, ex: trace context
+func (m *Message) Headers() map[string]string {
+	return m.headers
+}
+
+func (m *Message) Header(key string) string {
+	return m.headers[key]
+}
+
+func (m *Message) SetHeader(key, value string) {
+	if m.headers == nil {
+		m.headers = make(map[string]string)
+	}
+
+	m.headers[key] = value
+}
+
+// clone copies the message with a new ID and no acknowledgement
+func (m *Message) clone() *Message {
+	msg := NewMessage(m.data)
+	msg.topic = m.topic
+	msg.contentType = m.contentType
+	msg.payload = m.payload
+
+	for key, value := range m.headers {
+		msg.headers[key] = value
+	}
+
+	return msg
+}
+
+// DeliveryCount is the number of deliveries of the message by the backend, 0 if unknown
+func (m *Message) DeliveryCount() int {
+	return m.delivery
+}
+
+func (m *Message) SetDeliveryCount(count int) {
+	m.delivery = count
+}
+
+// MaxDeliveryCount is the number of deliveries after which the backend stops redelivering, 0 if unlimited or unknown
+func (m *Message) MaxDeliveryCount() int {
+	return m.maxDelivery
+}
+
+func (m *Message) SetMaxDeliveryCount(count int) {
+	m.maxDelivery = count
+}
+
+// IsLastDelivery tells if the backend does not redeliver the message after this delivery
+func (m *Message) IsLastDelivery() bool {
+	return m.maxDelivery > 0 && m.delivery >= m.maxDelivery
+}
+
+// CanRedeliver tells if the backend redelivers the message on Nak
+func (m *Message) CanRedeliver() bool {
+	return m.nakFunc != nil
+}
+
+// ReplyTo is the topic of the reply of a request, empty if the message is not a request
+func (m *Message) ReplyTo() Topic {
+	return m.replyTo
+}
+
+func (m *Message) SetReplyFunc(replyTo Topic, f func(ctx context.Context, reply *Message) error) {
+	m.replyTo = replyTo
+	m.replyFunc = f
+}
+
+// Reply sends the reply of a request to its requester
+func (m *Message) Reply(ctx context.Context, reply *Message) error {
+	if m.replyFunc == nil {
+		return ErrNoReplyTopic
+	}
+
+	return m.replyFunc(ctx, reply)
+}
+
 func (m *Message) SetAckFunc(f func() error) {
 	m.ackFunc = f
 }
 
+// Ack acknowledges the message, no-op if the backend has no acknowledgement
 func (m *Message) Ack() error {
+	if m.ackFunc == nil {
+		return nil
+	}
+
 	return m.ackFunc()
 }
+
+func (m *Message) SetNakFunc(f func(delay time.Duration) error) {
+	m.nakFunc = f
+}
+
+// Nak asks the backend to redeliver the message
+func (m *Message) Nak() error {
+	return m.NakWithDelay(0)
+}
+
+func (m *Message) NakWithDelay(delay time.Duration) error {
+	if m.nakFunc == nil {
+		return nil
+	}
+
+	return m.nakFunc(delay)
+}
+
+func (m *Message) SetInProgressFunc(f func() error) {
+	m.progFunc = f
+}
+
+// InProgress extends the ack deadline of a message still being processed
+func (m *Message) InProgress() error {
+	if m.progFunc == nil {
+		return nil
+	}
+
+	return m.progFunc()
+}
@@ -2,28 +2,47 @@ package pubsub
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	appctx "github.com/hoangtk0100/app-context"
+	"github.com/hoangtk0100/app-context/logadapter"
 	"github.com/nats-io/nats.go"
 	"github.com/spf13/pflag"
 )
 
+const defaultNatsRequestTimeout = time.Second * 5
+
 type natsPubSub struct {
-	id         string
-	url        string
-	connection *nats.Conn
-	logger     appctx.Logger
+	id             string
+	url            string
+	codecName      string
+	requestTimeout time.Duration
+	codec          Codec
+	connection     *nats.Conn
+	logger         appctx.Logger
+	metricsObserver
 }
 
 func NewNatsPubSub(id string) *natsPubSub {
-	return &natsPubSub{id: id}
+	return &natsPubSub{
+		id:             id,
+		requestTimeout: defaultNatsRequestTimeout,
+	}
 }
 
-func (ps *natsPubSub) Publish(ctx context.Context, topic Topic, msg *Message) error {
-	data, err := json.Marshal(msg.Data())
+func (ps *natsPubSub) Publish(ctx context.Context, topic Topic, msg *Message) (err error) {
+	msg.SetTopic(topic)
+
+	_, span := startPublishSpan(ctx, "nats", topic, msg)
+	defer func(startTime time.Time) {
+		ps.observePublish(topic, startTime, err)
+		EndSpan(span, err)
+	}(time.Now())
+
+	data, err := encodeEnvelope(msg, ps.codec)
 	if err != nil {
 		ps.logger.Error(err)
 		return err
@@ -37,30 +56,115 @@ func (ps *natsPubSub) Publish(ctx context.Context, topic Topic, msg *Message) er
 	return nil
 }
 
-func (ps *natsPubSub) Subscribe(ctx context.Context, topic Topic) (ch <-chan *Message, unsubscribeFunc func()) {
-	msgChan := make(chan *Message)
+// Request uses the NATS request/reply with a unique inbox, the request timeout applies if ctx has no deadline
+func (ps *natsPubSub) Request(ctx context.Context, topic Topic, msg *Message) (reply *Message, err error) {
+	msg.SetTopic(topic)
 
-	sub, err := ps.connection.Subscribe(string(topic), func(msg *nats.Msg) {
-		data := make(map[string]interface{})
+	ctx, span := startPublishSpan(ctx, "nats", topic, msg)
+	defer func(startTime time.Time) {
+		ps.observePublish(topic, startTime, err)
+		EndSpan(span, err)
+	}(time.Now())
 
-		_ = json.Unmarshal(msg.Data, &data)
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ps.requestTimeout)
+		defer cancel()
+	}
 
-		newMsg := NewMessage(data)
-		newMsg.SetTopic(topic)
+	data, err := encodeEnvelope(msg, ps.codec)
+	if err != nil {
+		ps.logger.Error(err)
+		return nil, err
+	}
 
-		newMsg.SetAckFunc(func() error {
-			return msg.Ack()
-		})
+	resp, err := ps.connection.RequestWithContext(ctx, string(topic), data)
+	if err != nil {
+		if errors.Is(err, nats.ErrNoResponders) {
+			return nil, ErrNoResponders
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		ps.logger.Error(err)
+		return nil, err
+	}
+
+	reply = decodeEnvelope(resp.Data)
+	reply.SetTopic(Topic(resp.Subject))
+
+	return reply, nil
+}
+
+func (ps *natsPubSub) Subscribe(ctx context.Context, topic Topic) (ch <-chan *Message, unsubscribeFunc func()) {
+	return ps.subscribe(topic, "")
+}
+
+// QueueSubscribe uses a NATS queue group, each message goes to one member of the group
+func (ps *natsPubSub) QueueSubscribe(ctx context.Context, topic Topic, group string) (ch <-chan *Message, unsubscribeFunc func()) {
+	return ps.subscribe(topic, group)
+}
 
-		msgChan <- newMsg
+func (ps *natsPubSub) subscribe(topic Topic, group string) (ch <-chan *Message, unsubscribeFunc func()) {
+	msgChan := make(chan *Message)
+	doneChan := make(chan struct{})
+	// Held by the callbacks while sending, so the channel is closed once no send is in progress
+	locker := new(sync.RWMutex)
+	closed := false
+
+	sub, err := ps.connection.QueueSubscribe(string(topic), group, func(msg *nats.Msg) {
+		newMsg := decodeEnvelope(msg.Data)
+		newMsg.SetTopic(Topic(msg.Subject))
+
+		if msg.Reply != "" {
+			replyTo := Topic(msg.Reply)
+			newMsg.SetReplyFunc(replyTo, func(ctx context.Context, reply *Message) error {
+				reply.SetTopic(replyTo)
+
+				data, err := encodeEnvelope(reply, ps.codec)
+				if err != nil {
+					return err
+				}
+
+				return ps.connection.Publish(msg.Reply, data)
+			})
+		}
+
+		locker.RLock()
+		defer locker.RUnlock()
+
+		if closed {
+			return
+		}
+
+		select {
+		case msgChan <- newMsg:
+			ps.observeDeliver(newMsg)
+		case <-doneChan:
+		}
 	})
 
 	if err != nil {
 		ps.logger.Error(err)
+		// Closed so the subscriber does not wait forever
+		close(msgChan)
+		return msgChan, func() {}
 	}
 
+	var once sync.Once
+
 	return msgChan, func() {
-		_ = sub.Unsubscribe()
+		once.Do(func() {
+			close(doneChan)
+			_ = sub.Unsubscribe()
+
+			locker.Lock()
+			closed = true
+			close(msgChan)
+			locker.Unlock()
+		})
 	}
 }
 
@@ -68,32 +172,47 @@ func (ps *natsPubSub) ID() string {
 	return ps.id
 }
 
-func (ps *natsPubSub) InitFlags() {
-	pflag.StringVar(
+func (ps *natsPubSub) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(
 		&ps.url,
 		"nats-url",
 		nats.DefaultURL,
 		fmt.Sprintf("NATS URL - Ex: %s", nats.DefaultURL),
 	)
+
+	fs.StringVar(
+		&ps.codecName,
+		"nats-codec",
+		CodecJSON,
+		fmt.Sprintf("NATS message payload codec (%s | %s | %s) - Default: %s", CodecJSON, CodecProtobuf, CodecMsgpack, CodecJSON),
+	)
+
+	fs.DurationVar(
+		&ps.requestTimeout,
+		"nats-request-timeout",
+		defaultNatsRequestTimeout,
+		fmt.Sprintf("NATS max time to wait for the reply of a request without deadline - Default: %s", defaultNatsRequestTimeout),
+	)
+
+	fs.StringVar(
+		&ps.metricsID,
+		"nats-metrics-id",
+		"",
+		"NATS metrics component ID, empty disables metrics - Ex: metrics",
+	)
 }
 
 func (ps *natsPubSub) setupOptions(opts []nats.Option) []nats.Option {
+	return setupNatsOptions(ps.logger, opts)
+}
+
+func setupNatsOptions(logger appctx.Logger, opts []nats.Option) []nats.Option {
 	totalWait := 10 * time.Minute
 	reconnectWait := time.Second
 
 	opts = append(opts, nats.ReconnectWait(reconnectWait))
 	opts = append(opts, nats.MaxReconnects(int(totalWait/reconnectWait)))
-	opts = append(opts, nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
-		ps.logger.Infof("Disconnected due to:%s, will attempt reconnects for %.0fm", err, totalWait.Minutes())
-	}))
-
-	opts = append(opts, nats.ReconnectHandler(func(nc *nats.Conn) {
-		ps.logger.Infof("Reconnected [%s]", nc.ConnectedUrl())
-	}))
-
-	opts = append(opts, nats.ClosedHandler(func(nc *nats.Conn) {
-		ps.logger.Infof("Exiting: %v", nc.LastError())
-	}))
+	opts = append(opts, logadapter.NatsOptions(logger)...)
 
 	return opts
 }
@@ -101,6 +220,15 @@ func (ps *natsPubSub) setupOptions(opts []nats.Option) []nats.Option {
 func (ps *natsPubSub) Run(ac appctx.AppContext) error {
 	ps.logger = ac.Logger(ps.id)
 
+	codec, err := NewCodec(ps.codecName)
+	if err != nil {
+		ps.logger.Error(err, "Cannot init NATS codec")
+		return err
+	}
+
+	ps.codec = codec
+	ps.initObserver(ac, ps.id)
+
 	conn, err := nats.Connect(ps.url, ps.setupOptions([]nats.Option{})...)
 	if err != nil {
 		ps.logger.Fatal(err)
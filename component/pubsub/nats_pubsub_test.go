@@ -0,0 +1,84 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNatsQueueSubscribe(t *testing.T) {
+	ctx := context.Background()
+
+	ps := NewNatsPubSub("nats")
+	ps.url = runNatsServer(t).ClientURL()
+	ps.codecName = CodecJSON
+	require.NoError(t, ps.Run(testAppContext{}))
+
+	member1, unsubscribe1 := ps.QueueSubscribe(ctx, "order.created", "workers")
+	defer unsubscribe1()
+	member2, unsubscribe2 := ps.QueueSubscribe(ctx, "order.created", "workers")
+	defer unsubscribe2()
+	broadcast, unsubscribe := ps.Subscribe(ctx, "order.created")
+	defer unsubscribe()
+
+	const total = 10
+	received := make(chan struct{}, total*2)
+	for _, ch := range []<-chan *Message{member1, member2} {
+		go func(ch <-chan *Message) {
+			for range ch {
+				received <- struct{}{}
+			}
+		}(ch)
+	}
+
+	for i := 0; i < total; i++ {
+		require.NoError(t, ps.Publish(ctx, "order.created", NewMessage(map[string]interface{}{"id": i})))
+		receive(t, broadcast)
+	}
+
+	for i := 0; i < total; i++ {
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatal("message not received")
+		}
+	}
+
+	// Each message goes to only one member of the group
+	select {
+	case <-received:
+		t.Fatal("message received twice")
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestNatsRequest(t *testing.T) {
+	ps := NewNatsPubSub("nats")
+	ps.url = runNatsServer(t).ClientURL()
+	ps.codecName = CodecJSON
+	require.NoError(t, ps.Run(testAppContext{}))
+
+	testRequest(t, ps)
+}
+
+func TestNatsUnsubscribe(t *testing.T) {
+	ctx := context.Background()
+
+	ps := NewNatsPubSub("nats")
+	ps.url = runNatsServer(t).ClientURL()
+	ps.codecName = CodecJSON
+	require.NoError(t, ps.Run(testAppContext{}))
+
+	ch, unsubscribe := ps.Subscribe(ctx, "order.created")
+	require.NoError(t, ps.Publish(ctx, "order.created", NewMessage(1)))
+
+	// The pending delivery does not block unsubscribe
+	time.Sleep(time.Millisecond * 50)
+	unsubscribe()
+	unsubscribe()
+
+	for range ch {
+	}
+}
@@ -0,0 +1,45 @@
+package pubsub
+
+import (
+	"time"
+
+	appctx "github.com/hoangtk0100/app-context"
+)
+
+// Observer is notified about the messages of a backend, ex: to collect metrics
+type Observer interface {
+	OnPublish(topic Topic, duration time.Duration, err error)
+	// OnDeliver is called when a message is delivered to a subscriber
+	OnDeliver(msg *Message)
+}
+
+// observerProvider is the metrics component, see core.MetricsComponent
+type observerProvider interface {
+	PubSubObserver(name string) Observer
+}
+
+// metricsObserver reports to the metrics component of the metrics ID flag, if set
+type metricsObserver struct {
+	metricsID string
+	observer  Observer
+}
+
+func (o *metricsObserver) initObserver(ac appctx.AppContext, name string) {
+	if o.metricsID == "" {
+		return
+	}
+
+	o.observer = ac.MustGet(o.metricsID).(observerProvider).PubSubObserver(name)
+}
+
+func (o *metricsObserver) observePublish(topic Topic, startTime time.Time, err error) {
+	if o.observer != nil {
+		o.observer.OnPublish(topic, time.Since(startTime), err)
+	}
+}
+
+func (o *metricsObserver) observeDeliver(msg *Message) {
+	if o.observer != nil {
+		o.observer.OnDeliver(msg)
+	}
+}
@@ -0,0 +1,59 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testObserver struct {
+	mu        sync.Mutex
+	published map[Topic]int
+	delivered []*Message
+}
+
+func (o *testObserver) OnPublish(topic Topic, duration time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.published[topic]++
+}
+
+func (o *testObserver) OnDeliver(msg *Message) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.delivered = append(o.delivered, msg)
+}
+
+func TestLocalObserver(t *testing.T) {
+	ctx := context.Background()
+	ps := newTestLocalPubSub(t, 10, OverflowBlock)
+
+	observer := &testObserver{published: make(map[Topic]int)}
+	ps.observer = observer
+
+	ch, unsubscribe := ps.Subscribe(ctx, "order.created")
+	defer unsubscribe()
+
+	require.NoError(t, ps.Publish(ctx, "order.created", NewMessage(1)))
+	require.NoError(t, ps.Publish(ctx, "order.paid", NewMessage(2)))
+	require.Equal(t, 1, receive(t, ch).Data())
+
+	// Reported after the message is handed to the subscriber
+	require.Eventually(t, func() bool {
+		observer.mu.Lock()
+		defer observer.mu.Unlock()
+
+		return len(observer.delivered) == 1
+	}, time.Second, time.Millisecond*10)
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+
+	require.Equal(t, map[Topic]int{"order.created": 1, "order.paid": 1}, observer.published)
+	require.Equal(t, Topic("order.created"), observer.delivered[0].Topic())
+}
@@ -4,7 +4,23 @@ import (
 	"context"
 )
 
+// PubSub delivers the messages of a topic to its subscribers.
+//
+// Topics are tokens separated by ".", ex: "order.created". Subscriptions accept wildcards, as NATS subjects:
+//   - "*" matches exactly one token, ex: "order.*" matches "order.created" but not "order.item.added"
+//   - ">" as the last token matches one or more tokens, ex: "order.>" matches both
+//
+// A message published to a topic with wildcards is rejected. The received messages carry the published topic.
+// Redis Streams backend matches topics exactly, as a topic is a stream
 type PubSub interface {
 	Publish(ctx context.Context, topic Topic, msg *Message) error
 	Subscribe(ctx context.Context, topic Topic) (ch <-chan *Message, unsubscribe func())
+	// QueueSubscribe delivers each message to only one subscriber of the group
+	QueueSubscribe(ctx context.Context, topic Topic, group string) (ch <-chan *Message, unsubscribe func())
+}
+
+// Requester sends a request and waits for the first reply, until the deadline of ctx.
+// The subscribers answer with Message.Reply
+type Requester interface {
+	Request(ctx context.Context, topic Topic, msg *Message) (*Message, error)
 }
@@ -0,0 +1,545 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/pflag"
+)
+
+const (
+	envelopeField = "envelope"
+
+	defaultStreamKeyPrefix = "stream:"
+	defaultStreamMaxLen    = 10000
+	defaultStreamBlock     = time.Second
+	defaultStreamBatch     = 10
+	defaultClaimInterval   = time.Second * 30
+	defaultClaimMinIdle    = time.Minute
+)
+
+// Same as core.RedisDBComponent, core imports this package
+type redisDBComponent interface {
+	GetDB() redis.UniversalClient
+}
+
+type redisStreamOpt struct {
+	prefix        string
+	redisDBID     string
+	keyPrefix     string
+	group         string
+	consumer      string
+	startID       string
+	maxLen        int64
+	block         time.Duration
+	batchSize     int64
+	claimInterval time.Duration
+	claimMinIdle  time.Duration
+	codecName     string
+}
+
+// Persistent pub/sub on Redis Streams, a topic is a stream read by the subscribers and consumer groups.
+// Pending entries of crashed consumers are claimed by the others after claim min idle
+type redisStreamPubSub struct {
+	id       string
+	client   redis.UniversalClient
+	codec    Codec
+	logger   appctx.Logger
+	stopChan chan struct{}
+	stopOnce sync.Once
+	metricsObserver
+	*redisStreamOpt
+}
+
+func NewRedisStreamPubSub(id, prefix, redisDBID string) *redisStreamPubSub {
+	return &redisStreamPubSub{
+		id: id,
+		redisStreamOpt: &redisStreamOpt{
+			prefix:    strings.TrimSpace(prefix),
+			redisDBID: redisDBID,
+		},
+		stopChan: make(chan struct{}),
+	}
+}
+
+func (ps *redisStreamPubSub) ID() string {
+	return ps.id
+}
+
+func (ps *redisStreamPubSub) InitFlags(fs *pflag.FlagSet) {
+	prefix := ps.prefix
+	if prefix != "" {
+		prefix += "-"
+	}
+
+	fs.StringVar(&ps.keyPrefix,
+		fmt.Sprintf("%sredis-stream-key-prefix", prefix),
+		defaultStreamKeyPrefix,
+		fmt.Sprintf("Redis stream key prefix, the stream of a topic is <prefix><topic> - Default: %s", defaultStreamKeyPrefix),
+	)
+
+	fs.StringVar(&ps.group,
+		fmt.Sprintf("%sredis-stream-group", prefix),
+		"",
+		"Redis stream consumer group of QueueSubscribe with an empty group, the instances of an app share it - Default: app name",
+	)
+
+	fs.StringVar(&ps.consumer,
+		fmt.Sprintf("%sredis-stream-consumer", prefix),
+		"",
+		"Redis stream consumer name, keep it stable to resume its pending entries after restart - Default: <hostname>-<random>",
+	)
+
+	fs.StringVar(&ps.startID,
+		fmt.Sprintf("%sredis-stream-start-id", prefix),
+		"$",
+		"Redis stream ID new consumer groups and subscriptions start from ($ is new messages, 0 is all) - Default: $",
+	)
+
+	fs.Int64Var(&ps.maxLen,
+		fmt.Sprintf("%sredis-stream-max-len", prefix),
+		defaultStreamMaxLen,
+		fmt.Sprintf("Redis stream approximate max length, trimmed on publish, 0 is unlimited - Default: %d", defaultStreamMaxLen),
+	)
+
+	fs.DurationVar(&ps.block,
+		fmt.Sprintf("%sredis-stream-block", prefix),
+		defaultStreamBlock,
+		fmt.Sprintf("Redis stream max time to block waiting for messages - Default: %s", defaultStreamBlock),
+	)
+
+	fs.Int64Var(&ps.batchSize,
+		fmt.Sprintf("%sredis-stream-batch-size", prefix),
+		defaultStreamBatch,
+		fmt.Sprintf("Redis stream number of messages read at once - Default: %d", defaultStreamBatch),
+	)
+
+	fs.DurationVar(&ps.claimInterval,
+		fmt.Sprintf("%sredis-stream-claim-interval", prefix),
+		defaultClaimInterval,
+		fmt.Sprintf("Redis stream interval to claim the pending entries of other consumers - Default: %s", defaultClaimInterval),
+	)
+
+	fs.DurationVar(&ps.claimMinIdle,
+		fmt.Sprintf("%sredis-stream-claim-min-idle", prefix),
+		defaultClaimMinIdle,
+		fmt.Sprintf("Redis stream idle time of a pending entry before it is claimed - Default: %s", defaultClaimMinIdle),
+	)
+
+	fs.StringVar(&ps.codecName,
+		fmt.Sprintf("%sredis-stream-codec", prefix),
+		CodecJSON,
+		fmt.Sprintf("Redis stream message payload codec (%s | %s | %s) - Default: %s", CodecJSON, CodecProtobuf, CodecMsgpack, CodecJSON),
+	)
+
+	fs.StringVar(&ps.metricsID,
+		fmt.Sprintf("%sredis-stream-metrics-id", prefix),
+		"",
+		"Redis stream metrics component ID, empty disables metrics - Ex: metrics",
+	)
+}
+
+func (ps *redisStreamPubSub) Run(ac appctx.AppContext) error {
+	ps.logger = ac.Logger(ps.id)
+
+	if ps.group == "" {
+		ps.group = ac.GetName()
+	}
+
+	if ps.group == "" {
+		ps.group = ps.id
+	}
+
+	redisDB := ac.MustGet(ps.redisDBID).(redisDBComponent)
+	ps.initObserver(ac, ps.id)
+
+	return ps.setup(redisDB.GetDB())
+}
+
+func (ps *redisStreamPubSub) setup(client redis.UniversalClient) error {
+	codec, err := NewCodec(ps.codecName)
+	if err != nil {
+		ps.logger.Error(err, "Cannot init Redis stream codec")
+		return err
+	}
+
+	if ps.consumer == "" {
+		hostname, _ := os.Hostname()
+		ps.consumer = fmt.Sprintf("%s-%s", hostname, uuid.NewString()[:8])
+	}
+
+	ps.codec = codec
+	ps.client = client
+
+	return nil
+}
+
+// Stop ends the subscriptions and closes their channels, it leaves the client to the Redis component
+func (ps *redisStreamPubSub) Stop() error {
+	ps.stopOnce.Do(func() {
+		close(ps.stopChan)
+	})
+
+	return nil
+}
+
+func (ps *redisStreamPubSub) streamKey(topic Topic) string {
+	return fmt.Sprintf("%s%s", ps.keyPrefix, topic)
+}
+
+func (ps *redisStreamPubSub) Publish(ctx context.Context, topic Topic, msg *Message) (err error) {
+	msg.SetTopic(topic)
+
+	ctx, span := startPublishSpan(ctx, "redis-stream", topic, msg)
+	defer func(startTime time.Time) {
+		ps.observePublish(topic, startTime, err)
+		EndSpan(span, err)
+	}(time.Now())
+
+	if topic.IsWildcard() {
+		return ErrWildcardPublish
+	}
+
+	data, err := encodeEnvelope(msg, ps.codec)
+	if err != nil {
+		return err
+	}
+
+	args := &redis.XAddArgs{
+		Stream: ps.streamKey(topic),
+		Values: map[string]interface{}{envelopeField: data},
+	}
+
+	if ps.maxLen > 0 {
+		args.MaxLen = ps.maxLen
+		args.Approx = true
+	}
+
+	if err := ps.client.XAdd(ctx, args).Err(); err != nil {
+		ps.logger.Error(err, "Cannot publish message")
+		return err
+	}
+
+	return nil
+}
+
+// Subscribe reads the stream without consumer group (XREAD), so every subscriber receives every message.
+// It starts from the start ID, the messages are not acknowledged nor redelivered
+func (ps *redisStreamPubSub) Subscribe(ctx context.Context, topic Topic) (ch <-chan *Message, unsubscribeFunc func()) {
+	return ps.subscribe(ctx, topic, "")
+}
+
+// QueueSubscribe reads with the consumer group named group, each message goes to one member of the group.
+// An empty group is the group of the app. A naked entry is redelivered once claimed after the delay,
+// capped at claim min idle, the delivery count is the one of the pending entry
+func (ps *redisStreamPubSub) QueueSubscribe(ctx context.Context, topic Topic, group string) (ch <-chan *Message, unsubscribeFunc func()) {
+	if group == "" {
+		group = ps.group
+	}
+
+	return ps.subscribe(ctx, topic, group)
+}
+
+func (ps *redisStreamPubSub) subscribe(ctx context.Context, topic Topic, group string) (ch <-chan *Message, unsubscribeFunc func()) {
+	msgChan := make(chan *Message)
+	doneChan := make(chan struct{})
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// Closed so the subscriber does not wait forever
+	if topic.IsWildcard() {
+		ps.logger.Errorf(ErrWildcardNotSupported, "Cannot subscribe topic %s", topic)
+		close(msgChan)
+		return msgChan, func() {}
+	}
+
+	stream := ps.streamKey(topic)
+
+	var err error
+	lastID := ps.startID
+	if group != "" {
+		err = ps.createGroup(ctx, stream, group)
+		// Own pending entries first, left by a previous run with the same consumer name
+		lastID = "0"
+	} else if lastID == "$" {
+		// Resolved once, so the messages published between two reads are not skipped
+		lastID, err = ps.lastEntryID(ctx, stream)
+	}
+
+	if err != nil {
+		ps.logger.Errorf(err, "Cannot subscribe topic %s", topic)
+		close(msgChan)
+		return msgChan, func() {}
+	}
+
+	// Cancel the blocking reads on unsubscribe or stop
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-ps.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var once sync.Once
+
+	go func() {
+		defer close(msgChan)
+		defer cancel()
+
+		deliver := func(entries []redis.XMessage, history bool) bool {
+			counts := make(map[string]int)
+			if history {
+				entries = ps.dropTrimmed(ctx, stream, group, entries)
+				counts = ps.deliveryCounts(ctx, stream, group, entries)
+			}
+
+			for _, entry := range entries {
+				msg := ps.toMessage(topic, stream, group, entry, counts[entry.ID])
+
+				select {
+				case msgChan <- msg:
+					ps.observeDeliver(msg)
+				case <-doneChan:
+					return false
+				case <-ctx.Done():
+					return false
+				}
+			}
+
+			return true
+		}
+
+		lastClaim := time.Now()
+
+		for {
+			select {
+			case <-doneChan:
+				return
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			if group != "" && time.Since(lastClaim) >= ps.claimInterval {
+				lastClaim = time.Now()
+
+				if !deliver(ps.claim(ctx, stream, group), true) {
+					return
+				}
+			}
+
+			var streams []redis.XStream
+			if group == "" {
+				streams, err = ps.client.XRead(ctx, &redis.XReadArgs{
+					Streams: []string{stream, lastID},
+					Count:   ps.batchSize,
+					Block:   ps.block,
+				}).Result()
+			} else {
+				streams, err = ps.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+					Group:    group,
+					Consumer: ps.consumer,
+					Streams:  []string{stream, lastID},
+					Count:    ps.batchSize,
+					Block:    ps.block,
+				}).Result()
+			}
+
+			if err != nil {
+				if errors.Is(err, redis.Nil) {
+					continue
+				}
+
+				if ctx.Err() != nil || errors.Is(err, redis.ErrClosed) {
+					return
+				}
+
+				ps.logger.Errorf(err, "Cannot read messages of topic %s", topic)
+				time.Sleep(ps.block)
+				continue
+			}
+
+			var entries []redis.XMessage
+			if len(streams) > 0 {
+				entries = streams[0].Messages
+			}
+
+			if group != "" && lastID != ">" && len(entries) == 0 {
+				lastID = ">"
+				continue
+			}
+
+			if !deliver(entries, group != "" && lastID != ">") {
+				return
+			}
+
+			if lastID != ">" && len(entries) > 0 {
+				lastID = entries[len(entries)-1].ID
+			}
+		}
+	}()
+
+	return msgChan, func() {
+		once.Do(func() {
+			close(doneChan)
+			cancel()
+		})
+	}
+}
+
+// createGroup creates the consumer group and the stream if not exists
+func (ps *redisStreamPubSub) createGroup(ctx context.Context, stream, group string) error {
+	err := ps.client.XGroupCreateMkStream(ctx, stream, group, ps.startID).Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+
+	return err
+}
+
+// lastEntryID returns the ID of the last entry of the stream, 0-0 if it is empty
+func (ps *redisStreamPubSub) lastEntryID(ctx context.Context, stream string) (string, error) {
+	entries, err := ps.client.XRevRangeN(ctx, stream, "+", "-", 1).Result()
+	if err != nil || len(entries) == 0 {
+		return "0-0", err
+	}
+
+	return entries[0].ID, nil
+}
+
+// claim takes over the entries pending for longer than claim min idle, ex: of a crashed consumer or naked
+func (ps *redisStreamPubSub) claim(ctx context.Context, stream, group string) []redis.XMessage {
+	var claimed []redis.XMessage
+
+	start := "0-0"
+	for {
+		entries, next, err := ps.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    group,
+			Consumer: ps.consumer,
+			MinIdle:  ps.claimMinIdle,
+			Start:    start,
+			Count:    ps.batchSize,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				ps.logger.Errorf(err, "Cannot claim pending messages of stream %s", stream)
+			}
+
+			return claimed
+		}
+
+		claimed = append(claimed, entries...)
+
+		if next == "0-0" || len(entries) == 0 {
+			return claimed
+		}
+
+		start = next
+	}
+}
+
+// dropTrimmed acks and removes the pending entries trimmed by the max length, returned without values
+func (ps *redisStreamPubSub) dropTrimmed(ctx context.Context, stream, group string, entries []redis.XMessage) []redis.XMessage {
+	kept := entries[:0]
+	for _, entry := range entries {
+		if _, ok := entry.Values[envelopeField]; ok {
+			kept = append(kept, entry)
+			continue
+		}
+
+		ps.logger.Warnf("Drop entry %s of stream %s trimmed while pending", entry.ID, stream)
+		if err := ps.client.XAck(ctx, stream, group, entry.ID).Err(); err != nil {
+			ps.logger.Errorf(err, "Cannot ack entry %s of stream %s", entry.ID, stream)
+		}
+	}
+
+	return kept
+}
+
+// deliveryCounts returns the delivery counts of the pending entries of the consumer, by entry ID
+func (ps *redisStreamPubSub) deliveryCounts(ctx context.Context, stream, group string, entries []redis.XMessage) map[string]int {
+	counts := make(map[string]int, len(entries))
+	if len(entries) == 0 {
+		return counts
+	}
+
+	pending, err := ps.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   stream,
+		Group:    group,
+		Start:    entries[0].ID,
+		End:      entries[len(entries)-1].ID,
+		Count:    int64(len(entries)),
+		Consumer: ps.consumer,
+	}).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			ps.logger.Errorf(err, "Cannot get pending messages of stream %s", stream)
+		}
+
+		return counts
+	}
+
+	for _, p := range pending {
+		counts[p.ID] = int(p.RetryCount)
+	}
+
+	return counts
+}
+
+// toMessage converts an entry, read with group if not empty, delivered count times (0 is the first delivery)
+func (ps *redisStreamPubSub) toMessage(topic Topic, stream, group string, entry redis.XMessage, count int) *Message {
+	data, _ := entry.Values[envelopeField].(string)
+
+	newMsg := decodeEnvelope([]byte(data))
+	newMsg.SetTopic(topic)
+
+	if group == "" {
+		return newMsg
+	}
+
+	if count < 1 {
+		count = 1
+	}
+
+	newMsg.SetDeliveryCount(count)
+
+	newMsg.SetAckFunc(func() error {
+		return ps.client.XAck(context.Background(), stream, group, entry.ID).Err()
+	})
+
+	// Claiming the entry again resets its idle time
+	newMsg.SetInProgressFunc(func() error {
+		return ps.client.XClaimJustID(context.Background(), &redis.XClaimArgs{
+			Stream:   stream,
+			Group:    group,
+			Consumer: ps.consumer,
+			Messages: []string{entry.ID},
+		}).Err()
+	})
+
+	// Left pending with an idle time, so it is claimed again once the delay is over
+	newMsg.SetNakFunc(func(delay time.Duration) error {
+		idle := ps.claimMinIdle - delay
+		if idle < 0 {
+			idle = 0
+		}
+
+		return ps.client.Do(context.Background(), "XCLAIM", stream, group, ps.consumer, 0, entry.ID,
+			"IDLE", idle.Milliseconds(), "RETRYCOUNT", count, "JUSTID").Err()
+	})
+
+	return newMsg
+}
@@ -0,0 +1,278 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisStream(t *testing.T, server *miniredis.Miniredis, consumer string) *redisStreamPubSub {
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	ps := NewRedisStreamPubSub("pubsub", "", "redis")
+	ps.logger = testAppContext{}.Logger(ps.id)
+	ps.keyPrefix = defaultStreamKeyPrefix
+	ps.group = "test"
+	ps.consumer = consumer
+	ps.startID = "0"
+	ps.maxLen = defaultStreamMaxLen
+	ps.block = time.Millisecond * 50
+	ps.batchSize = defaultStreamBatch
+	ps.claimInterval = time.Millisecond * 50
+	ps.claimMinIdle = time.Millisecond * 200
+	ps.codecName = CodecJSON
+	require.NoError(t, ps.setup(client))
+
+	return ps
+}
+
+func pendingCount(t *testing.T, ps *redisStreamPubSub, topic Topic, group string) int64 {
+	pending, err := ps.client.XPending(context.Background(), ps.streamKey(topic), group).Result()
+	require.NoError(t, err)
+
+	return pending.Count
+}
+
+func TestRedisStreamAck(t *testing.T) {
+	ctx := context.Background()
+	ps := newTestRedisStream(t, miniredis.RunT(t), "consumer-1")
+
+	// Published before any subscriber is up
+	msg := NewMessage(orderCreated{OrderID: "o-1", Amount: 9.5})
+	msg.SetHeader("source", "test")
+	require.NoError(t, ps.Publish(ctx, "order.created", msg))
+
+	ch, unsubscribe := ps.QueueSubscribe(ctx, "order.created", "")
+	defer unsubscribe()
+
+	received := receive(t, ch)
+	require.Equal(t, msg.ID(), received.ID())
+	require.Equal(t, "test", received.Header("source"))
+
+	order, err := Decode[orderCreated](received)
+	require.NoError(t, err)
+	require.Equal(t, "o-1", order.OrderID)
+
+	require.Equal(t, int64(1), pendingCount(t, ps, "order.created", "test"))
+	require.NoError(t, received.InProgress())
+	require.NoError(t, received.Ack())
+	require.Equal(t, int64(0), pendingCount(t, ps, "order.created", "test"))
+
+	assertNoMessage(t, ch, time.Millisecond*400)
+}
+
+func TestRedisStreamClaim(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	crashed := newTestRedisStream(t, server, "consumer-1")
+	alive := newTestRedisStream(t, server, "consumer-2")
+
+	ch, unsubscribe := crashed.QueueSubscribe(ctx, "order.paid", "")
+
+	msg := NewMessage(map[string]interface{}{"id": 1})
+	require.NoError(t, crashed.Publish(ctx, "order.paid", msg))
+	require.Equal(t, msg.ID(), receive(t, ch).ID())
+
+	// Gone without ack
+	unsubscribe()
+
+	ch, unsubscribe = alive.QueueSubscribe(ctx, "order.paid", "")
+	defer unsubscribe()
+
+	claimed := receive(t, ch)
+	require.Equal(t, msg.ID(), claimed.ID())
+	require.NoError(t, claimed.Ack())
+	require.Equal(t, int64(0), pendingCount(t, alive, "order.paid", "test"))
+}
+
+func TestRedisStreamNak(t *testing.T) {
+	ctx := context.Background()
+	ps := newTestRedisStream(t, miniredis.RunT(t), "consumer-1")
+
+	ch, unsubscribe := ps.QueueSubscribe(ctx, "order.paid", "")
+	defer unsubscribe()
+
+	msg := NewMessage(map[string]interface{}{"id": 1})
+	require.NoError(t, ps.Publish(ctx, "order.paid", msg))
+
+	first := receive(t, ch)
+	require.True(t, first.CanRedeliver())
+	require.Equal(t, 1, first.DeliveryCount())
+	require.NoError(t, first.Nak())
+
+	// Claimed again without waiting for claim min idle
+	second := receive(t, ch)
+	require.Equal(t, msg.ID(), second.ID())
+	require.Equal(t, 2, second.DeliveryCount())
+	require.NoError(t, second.Ack())
+
+	assertNoMessage(t, ch, time.Millisecond*400)
+}
+
+func TestRedisStreamDropTrimmed(t *testing.T) {
+	ctx := context.Background()
+	ps := newTestRedisStream(t, miniredis.RunT(t), "consumer-1")
+
+	ch, unsubscribe := ps.QueueSubscribe(ctx, "order.paid", "")
+	require.NoError(t, ps.Publish(ctx, "order.paid", NewMessage(1)))
+	entry := receive(t, ch)
+	unsubscribe()
+	require.NotNil(t, entry)
+
+	// Returned without values by the claims once trimmed
+	stream := ps.streamKey("order.paid")
+	pending, err := ps.client.XPendingExt(ctx, &redis.XPendingExtArgs{Stream: stream, Group: "test", Start: "-", End: "+", Count: 1}).Result()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	kept := ps.dropTrimmed(ctx, stream, "test", []redis.XMessage{{ID: pending[0].ID}})
+	require.Empty(t, kept)
+	require.Equal(t, int64(0), pendingCount(t, ps, "order.paid", "test"))
+}
+
+func TestRedisStreamSubscribeBroadcast(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	instance1 := newTestRedisStream(t, server, "consumer-1")
+	instance2 := newTestRedisStream(t, server, "consumer-2")
+	instance1.startID = "$"
+	instance2.startID = "$"
+
+	// Published before the subscriptions, skipped from $
+	require.NoError(t, instance1.Publish(ctx, "order.created", NewMessage(-1)))
+
+	ch1, unsubscribe1 := instance1.Subscribe(ctx, "order.created")
+	defer unsubscribe1()
+	ch2, unsubscribe2 := instance1.Subscribe(ctx, "order.created")
+	defer unsubscribe2()
+	ch3, unsubscribe3 := instance2.Subscribe(ctx, "order.created")
+	defer unsubscribe3()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, instance1.Publish(ctx, "order.created", NewMessage(i)))
+	}
+
+	for _, ch := range []<-chan *Message{ch1, ch2, ch3} {
+		for i := 0; i < 3; i++ {
+			received := receive(t, ch)
+			require.Equal(t, float64(i), received.Data())
+			require.False(t, received.CanRedeliver())
+			require.NoError(t, received.Ack())
+		}
+	}
+}
+
+func TestRedisStreamResumePending(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	ps := newTestRedisStream(t, server, "consumer-1")
+	ps.claimMinIdle = time.Hour
+
+	ch, unsubscribe := ps.QueueSubscribe(ctx, "order.paid", "")
+
+	msg := NewMessage(map[string]interface{}{"id": 1})
+	require.NoError(t, ps.Publish(ctx, "order.paid", msg))
+	require.Equal(t, msg.ID(), receive(t, ch).ID())
+	unsubscribe()
+
+	// Restarted with the same consumer name
+	restarted := newTestRedisStream(t, server, "consumer-1")
+	restarted.claimMinIdle = time.Hour
+
+	ch, unsubscribe = restarted.QueueSubscribe(ctx, "order.paid", "")
+	defer unsubscribe()
+
+	require.Equal(t, msg.ID(), receive(t, ch).ID())
+}
+
+func TestRedisStreamQueueSubscribe(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	instance1 := newTestRedisStream(t, server, "consumer-1")
+	instance2 := newTestRedisStream(t, server, "consumer-2")
+
+	member1, unsubscribe1 := instance1.QueueSubscribe(ctx, "order.shipped", "workers")
+	defer unsubscribe1()
+	member2, unsubscribe2 := instance2.QueueSubscribe(ctx, "order.shipped", "workers")
+	defer unsubscribe2()
+
+	const total = 10
+	ids := make(chan string, total*2)
+	for _, ch := range []<-chan *Message{member1, member2} {
+		go func(ch <-chan *Message) {
+			for msg := range ch {
+				_ = msg.Ack()
+				ids <- msg.ID()
+			}
+		}(ch)
+	}
+
+	for i := 0; i < total; i++ {
+		require.NoError(t, instance1.Publish(ctx, "order.shipped", NewMessage(map[string]interface{}{"id": i})))
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < total; i++ {
+		select {
+		case id := <-ids:
+			require.False(t, seen[id])
+			seen[id] = true
+		case <-time.After(time.Second * 3):
+			t.Fatal("message not received")
+		}
+	}
+
+	select {
+	case <-ids:
+		t.Fatal("message received twice")
+	case <-time.After(time.Millisecond * 300):
+	}
+}
+
+func TestRedisStreamMaxLen(t *testing.T) {
+	ctx := context.Background()
+	ps := newTestRedisStream(t, miniredis.RunT(t), "consumer-1")
+	ps.maxLen = 5
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, ps.Publish(ctx, "order.created", NewMessage(i)))
+	}
+
+	length, err := ps.client.XLen(ctx, ps.streamKey("order.created")).Result()
+	require.NoError(t, err)
+	require.LessOrEqual(t, length, int64(5))
+}
+
+func assertClosed(t *testing.T, ch <-chan *Message) {
+	select {
+	case _, ok := <-ch:
+		require.False(t, ok, "channel not closed")
+	case <-time.After(time.Second * 3):
+		t.Fatal("channel not closed")
+	}
+}
+
+func TestRedisStreamClose(t *testing.T) {
+	ctx := context.Background()
+	ps := newTestRedisStream(t, miniredis.RunT(t), "consumer-1")
+
+	// Not supported, closed at once
+	ch, _ := ps.Subscribe(ctx, "order.*")
+	assertClosed(t, ch)
+
+	ch, unsubscribe := ps.Subscribe(ctx, "order.created")
+	unsubscribe()
+	unsubscribe()
+	assertClosed(t, ch)
+
+	ch, _ = ps.Subscribe(ctx, "order.paid")
+	require.NoError(t, ps.Stop())
+	assertClosed(t, ch)
+}
@@ -0,0 +1,124 @@
+package pubsub
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// Headers of a dead letter message
+const (
+	HeaderOriginalTopic   = "x-original-topic"
+	HeaderOriginalID      = "x-original-id"
+	HeaderFailureError    = "x-failure-error"
+	HeaderFailureAttempts = "x-failure-attempts"
+	HeaderFailedAt        = "x-failed-at"
+)
+
+const deadLetterReplayGroup = "dead-letter-replay"
+
+// RetryPolicy of the messages of a topic.
+// The backends with redelivery redeliver the failed message after the backoff, the others retry in-process.
+// After MaxRetries, the message goes to DeadLetterTopic if set
+type RetryPolicy struct {
+	MaxRetries      int
+	Backoff         time.Duration
+	MaxBackoff      time.Duration // 0 is unlimited
+	Multiplier      float64       // 0 is 2
+	Jitter          float64       // random fraction of the backoff added or removed, ex: 0.2
+	DeadLetterTopic Topic
+}
+
+// BackoffOf returns the delay before the retry following the attempt (1 is the first delivery)
+func (p RetryPolicy) BackoffOf(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	backoff := float64(p.Backoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (rand.Float64()*2 - 1)
+	}
+
+	return time.Duration(backoff)
+}
+
+// Durations returns the backoffs of all retries, for in-process retries
+func (p RetryPolicy) Durations() []time.Duration {
+	durations := make([]time.Duration, p.MaxRetries)
+	for index := range durations {
+		durations[index] = p.BackoffOf(index + 1)
+	}
+
+	return durations
+}
+
+// NewDeadLetter copies the failed message with the failure metadata in the headers.
+// It has a new ID as some backends deduplicate by ID
+func NewDeadLetter(msg *Message, err error, attempts int) *Message {
+	dlq := msg.clone()
+	dlq.SetHeader(HeaderOriginalTopic, string(msg.Topic()))
+	dlq.SetHeader(HeaderOriginalID, msg.ID())
+	dlq.SetHeader(HeaderFailureAttempts, strconv.Itoa(attempts))
+	dlq.SetHeader(HeaderFailedAt, time.Now().UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		dlq.SetHeader(HeaderFailureError, err.Error())
+	}
+
+	return dlq
+}
+
+// ReplayDeadLetters publishes the messages of the dead letter topic back to their original topic,
+// until no message comes for idle or ctx is done. It returns the number of replayed messages.
+// The dead letters are consumed by the queue group "dead-letter-replay", so the acknowledged ones are not replayed again
+func ReplayDeadLetters(ctx context.Context, ps PubSub, deadLetterTopic Topic, idle time.Duration) (int, error) {
+	ch, unsubscribe := ps.QueueSubscribe(ctx, deadLetterTopic, deadLetterReplayGroup)
+	defer unsubscribe()
+
+	count := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return count, ctx.Err()
+		case <-time.After(idle):
+			return count, nil
+		case msg, ok := <-ch:
+			if !ok {
+				return count, nil
+			}
+
+			topic := Topic(msg.Header(HeaderOriginalTopic))
+			if topic == "" {
+				_ = msg.Ack()
+				continue
+			}
+
+			replay := msg.clone()
+			for _, key := range []string{HeaderOriginalTopic, HeaderFailureError, HeaderFailureAttempts, HeaderFailedAt} {
+				delete(replay.headers, key)
+			}
+
+			if err := ps.Publish(ctx, topic, replay); err != nil {
+				_ = msg.Nak()
+				return count, err
+			}
+
+			if err := msg.Ack(); err != nil {
+				return count, err
+			}
+
+			count++
+		}
+	}
+}
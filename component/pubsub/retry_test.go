@@ -0,0 +1,63 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 4, Backoff: time.Millisecond * 100, MaxBackoff: time.Millisecond * 500}
+
+	require.Equal(t, []time.Duration{
+		time.Millisecond * 100,
+		time.Millisecond * 200,
+		time.Millisecond * 400,
+		time.Millisecond * 500,
+	}, policy.Durations())
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.BackoffOf(1)
+		require.GreaterOrEqual(t, backoff, time.Millisecond*50)
+		require.LessOrEqual(t, backoff, time.Millisecond*150)
+	}
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	ps := newTestJetStream(t, runNatsServer(t))
+
+	msg := NewMessage(map[string]interface{}{"id": 1})
+	msg.SetTopic("order.created")
+	msg.SetHeader("source", "test")
+
+	deadLetter := NewDeadLetter(msg, errors.New("boom"), 3)
+	require.NotEqual(t, msg.ID(), deadLetter.ID())
+	require.Equal(t, msg.ID(), deadLetter.Header(HeaderOriginalID))
+	require.Equal(t, "order.created", deadLetter.Header(HeaderOriginalTopic))
+	require.Equal(t, "boom", deadLetter.Header(HeaderFailureError))
+	require.Equal(t, "3", deadLetter.Header(HeaderFailureAttempts))
+	require.NoError(t, ps.Publish(ctx, "order.created.dlq", deadLetter))
+
+	count, err := ReplayDeadLetters(ctx, ps, "order.created.dlq", time.Millisecond*500)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	ch, unsubscribe := ps.Subscribe(ctx, "order.created")
+	defer unsubscribe()
+
+	replayed := receive(t, ch)
+	require.Equal(t, msg.ID(), replayed.Header(HeaderOriginalID))
+	require.Equal(t, "test", replayed.Header("source"))
+	require.Empty(t, replayed.Header(HeaderFailureError))
+	require.Equal(t, float64(1), replayed.Data().(map[string]interface{})["id"])
+
+	// Acknowledged, so not replayed twice
+	count, err = ReplayDeadLetters(ctx, ps, "order.created.dlq", time.Millisecond*500)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
@@ -0,0 +1,103 @@
+package pubsub
+
+import "strings"
+
+const (
+	topicSeparator    = "."
+	wildcardToken     = "*"
+	fullWildcardToken = ">"
+)
+
+func (t Topic) tokens() []string {
+	return strings.Split(string(t), topicSeparator)
+}
+
+// IsWildcard tells if the topic is a subscription pattern, it cannot be published
+func (t Topic) IsWildcard() bool {
+	for _, token := range t.tokens() {
+		if token == wildcardToken || token == fullWildcardToken {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match tells if the topic matches the pattern, with the same semantics as NATS subjects
+func (t Topic) Match(pattern Topic) bool {
+	tokens := t.tokens()
+	patternTokens := pattern.tokens()
+
+	for index, token := range patternTokens {
+		if token == fullWildcardToken && index == len(patternTokens)-1 {
+			return len(tokens) > index
+		}
+
+		if index >= len(tokens) || (token != wildcardToken && token != tokens[index]) {
+			return false
+		}
+	}
+
+	return len(tokens) == len(patternTokens)
+}
+
+// topicNode is a trie of the subscribed patterns by token, so a topic is matched in O(tokens)
+type topicNode struct {
+	children map[string]*topicNode
+	value    *localTopic
+}
+
+func newTopicNode() *topicNode {
+	return &topicNode{children: make(map[string]*topicNode)}
+}
+
+func (n *topicNode) insert(pattern Topic, value *localTopic) {
+	node := n
+	for _, token := range pattern.tokens() {
+		child, ok := node.children[token]
+		if !ok {
+			child = newTopicNode()
+			node.children[token] = child
+		}
+
+		node = child
+	}
+
+	node.value = value
+}
+
+// remove deletes the pattern and prunes the empty nodes
+func (n *topicNode) remove(tokens []string) bool {
+	if len(tokens) == 0 {
+		n.value = nil
+	} else if child, ok := n.children[tokens[0]]; ok && child.remove(tokens[1:]) {
+		delete(n.children, tokens[0])
+	}
+
+	return n.value == nil && len(n.children) == 0
+}
+
+// match collects the values of the patterns matching the topic tokens
+func (n *topicNode) match(tokens []string, values []*localTopic) []*localTopic {
+	if len(tokens) == 0 {
+		if n.value != nil {
+			values = append(values, n.value)
+		}
+
+		return values
+	}
+
+	if child, ok := n.children[fullWildcardToken]; ok && child.value != nil {
+		values = append(values, child.value)
+	}
+
+	if child, ok := n.children[wildcardToken]; ok {
+		values = child.match(tokens[1:], values)
+	}
+
+	if child, ok := n.children[tokens[0]]; ok {
+		values = child.match(tokens[1:], values)
+	}
+
+	return values
+}
@@ -0,0 +1,83 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopicMatch(t *testing.T) {
+	tests := []struct {
+		pattern Topic
+		topic   Topic
+		match   bool
+	}{
+		{"order.created", "order.created", true},
+		{"order.created", "order.paid", false},
+		{"order.*", "order.created", true},
+		{"order.*", "order.item.added", false},
+		{"order.*", "order", false},
+		{"*.created", "order.created", true},
+		{"order.*.added", "order.item.added", true},
+		{"order.>", "order.created", true},
+		{"order.>", "order.item.added", true},
+		{"order.>", "order", false},
+		{">", "order", true},
+		{"order", "order.created", false},
+	}
+
+	for _, tc := range tests {
+		require.Equal(t, tc.match, tc.topic.Match(tc.pattern), "%s %s", tc.pattern, tc.topic)
+
+		// The trie matches the same way
+		root := newTopicNode()
+		value := new(localTopic)
+		root.insert(tc.pattern, value)
+		require.Equal(t, tc.match, len(root.match(tc.topic.tokens(), nil)) == 1, "%s %s", tc.pattern, tc.topic)
+
+		require.True(t, root.remove(tc.pattern.tokens()))
+	}
+}
+
+func TestWildcardSubscribe(t *testing.T) {
+	ctx := context.Background()
+
+	local := newTestLocalPubSub(t, 10, OverflowBlock)
+
+	nats := NewNatsPubSub("nats")
+	nats.url = runNatsServer(t).ClientURL()
+	nats.codecName = CodecJSON
+	require.NoError(t, nats.Run(testAppContext{}))
+
+	for name, ps := range map[string]PubSub{"local": local, "nats": nats} {
+		t.Run(name, func(t *testing.T) {
+			one, unsubscribeOne := ps.Subscribe(ctx, "order.*")
+			defer unsubscribeOne()
+			all, unsubscribeAll := ps.Subscribe(ctx, "order.>")
+			defer unsubscribeAll()
+			exact, unsubscribeExact := ps.Subscribe(ctx, "order.item.added")
+			defer unsubscribeExact()
+
+			require.NoError(t, ps.Publish(ctx, "order.created", NewMessage(1)))
+			require.NoError(t, ps.Publish(ctx, "order.item.added", NewMessage(2)))
+			require.NoError(t, ps.Publish(ctx, "user.created", NewMessage(3)))
+
+			require.Equal(t, Topic("order.created"), receive(t, one).Topic())
+			require.Equal(t, Topic("order.created"), receive(t, all).Topic())
+			require.Equal(t, Topic("order.item.added"), receive(t, all).Topic())
+			require.Equal(t, Topic("order.item.added"), receive(t, exact).Topic())
+
+			assertNoMessage(t, one, time.Millisecond*50)
+			assertNoMessage(t, all, time.Millisecond*50)
+			assertNoMessage(t, exact, time.Millisecond*50)
+		})
+	}
+
+	require.ErrorIs(t, local.Publish(ctx, "order.*", NewMessage(1)), ErrWildcardPublish)
+
+	// The patterns without subscriptions are removed
+	require.Empty(t, local.topics)
+	require.Empty(t, local.trie.children)
+}
@@ -0,0 +1,87 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/hoangtk0100/app-context/component/pubsub"
+
+// Spans go to the global tracer provider (no-op until the tracing component runs)
+var tracer = otel.Tracer(instrumentationName)
+
+// messageCarrier adapts message headers to propagation.TextMapCarrier
+type messageCarrier struct {
+	msg *Message
+}
+
+func (c messageCarrier) Get(key string) string {
+	return c.msg.Header(key)
+}
+
+func (c messageCarrier) Set(key, value string) {
+	c.msg.SetHeader(key, value)
+}
+
+func (c messageCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.headers))
+	for key := range c.msg.headers {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// startPublishSpan starts a producer span and injects its context into the message headers
+func startPublishSpan(ctx context.Context, system string, topic Topic, msg *Message) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, span := tracer.Start(ctx, fmt.Sprintf("%s publish", topic),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystem(system),
+			semconv.MessagingOperationPublish,
+			semconv.MessagingDestinationName(string(topic)),
+			semconv.MessagingMessageID(msg.id),
+		),
+	)
+
+	otel.GetTextMapPropagator().Inject(ctx, messageCarrier{msg: msg})
+
+	return ctx, span
+}
+
+// StartConsumeSpan starts a consumer span continuing the trace carried by the message headers
+func StartConsumeSpan(ctx context.Context, msg *Message) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, messageCarrier{msg: msg})
+
+	return tracer.Start(ctx, fmt.Sprintf("%s process", msg.Topic()),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingOperationProcess,
+			semconv.MessagingSourceName(string(msg.Topic())),
+			semconv.MessagingMessageID(msg.id),
+		),
+	)
+}
+
+// EndSpan records err (if any) and ends the span
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,105 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	testExporter     = tracetest.NewInMemoryExporter()
+	testProviderOnce sync.Once
+)
+
+// setupTracing sets the global tracer provider once, the package tracer keeps the 1st one
+func setupTracing(t *testing.T) *tracetest.InMemoryExporter {
+	testProviderOnce.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(testExporter)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+
+	testExporter.Reset()
+	t.Cleanup(testExporter.Reset)
+
+	return testExporter
+}
+
+func TestTracingPropagation(t *testing.T) {
+	exporter := setupTracing(t)
+
+	ctx := context.Background()
+	ps := newTestLocalPubSub(t, 10, OverflowBlock)
+
+	ch, unsubscribe := ps.Subscribe(ctx, "order.created")
+	defer unsubscribe()
+
+	require.NoError(t, ps.Publish(ctx, "order.created", NewMessage(1)))
+
+	// The trace context is carried by the headers, kept by the encoding of the network backends
+	msg := receive(t, ch)
+	require.NotEmpty(t, msg.Header("traceparent"))
+
+	data, err := MarshalMessage(msg)
+	require.NoError(t, err)
+
+	_, span := StartConsumeSpan(ctx, UnmarshalMessage(data))
+	EndSpan(span, nil)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+
+	producer, consumer := spans[0], spans[1]
+	require.Equal(t, "order.created publish", producer.Name)
+	require.Equal(t, trace.SpanKindProducer, producer.SpanKind)
+	require.Equal(t, "order.created process", consumer.Name)
+	require.Equal(t, trace.SpanKindConsumer, consumer.SpanKind)
+	require.Equal(t, producer.SpanContext.TraceID(), consumer.SpanContext.TraceID())
+	require.Equal(t, producer.SpanContext.SpanID(), consumer.Parent.SpanID())
+}
+
+func TestTracingRequest(t *testing.T) {
+	exporter := setupTracing(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	ps := newTestLocalPubSub(t, 10, OverflowBlock)
+
+	ch, unsubscribe := ps.Subscribe(ctx, "user.get")
+	defer unsubscribe()
+
+	go func() {
+		msg := <-ch
+		_, span := StartConsumeSpan(ctx, msg)
+		_ = msg.Reply(ctx, NewMessage("pong"))
+		EndSpan(span, nil)
+	}()
+
+	_, err := ps.Request(ctx, "user.get", NewMessage("ping"))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(exporter.GetSpans()) == 2
+	}, time.Second, time.Millisecond*10)
+
+	var producer, consumer sdktrace.ReadOnlySpan
+	for _, span := range exporter.GetSpans().Snapshots() {
+		if span.SpanKind() == trace.SpanKindProducer {
+			producer = span
+		} else {
+			consumer = span
+		}
+	}
+
+	require.NotNil(t, producer)
+	require.NotNil(t, consumer)
+	require.Equal(t, producer.SpanContext().SpanID(), consumer.Parent().SpanID())
+}
@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Idle keys are swept on the first call after each window, so memory is bounded by the active keys
+type localSlidingWindow struct {
+	limit     int
+	window    time.Duration
+	requests  map[string][]time.Time
+	lastSweep time.Time
+	locker    *sync.Mutex
+}
+
+// NewLocalSlidingWindow is the in-memory sliding window for tests and single instance
+func NewLocalSlidingWindow(limit int, window time.Duration) *localSlidingWindow {
+	return &localSlidingWindow{
+		limit:     limit,
+		window:    window,
+		requests:  make(map[string][]time.Time),
+		lastSweep: time.Now(),
+		locker:    new(sync.Mutex),
+	}
+}
+
+func (l *localSlidingWindow) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *localSlidingWindow) AllowN(ctx context.Context, key string, n int) (*Result, error) {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+
+	now := time.Now()
+	windowStart := now.Add(-l.window)
+
+	if now.Sub(l.lastSweep) >= l.window {
+		l.sweep(windowStart)
+		l.lastSweep = now
+	}
+
+	requests := l.requests[key]
+	i := 0
+	for i < len(requests) && !requests[i].After(windowStart) {
+		i++
+	}
+	requests = requests[i:]
+
+	if len(requests)+n <= l.limit {
+		for j := 0; j < n; j++ {
+			requests = append(requests, now)
+		}
+		l.requests[key] = requests
+
+		return &Result{
+			Allowed:   true,
+			Remaining: l.limit - len(requests),
+		}, nil
+	}
+
+	if len(requests) == 0 {
+		delete(l.requests, key)
+	} else {
+		l.requests[key] = requests
+	}
+
+	result := &Result{
+		Remaining: l.limit - len(requests),
+	}
+
+	if len(requests) > 0 {
+		result.RetryAfter = requests[0].Add(l.window).Sub(now)
+	}
+
+	return result, nil
+}
+
+// sweep deletes the keys without requests in the window, the caller holds the lock
+func (l *localSlidingWindow) sweep(windowStart time.Time) {
+	for key, requests := range l.requests {
+		if len(requests) == 0 || !requests[len(requests)-1].After(windowStart) {
+			delete(l.requests, key)
+		}
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Refilled buckets are swept, a new bucket is full, so memory is bounded by the active keys
+type localTokenBucket struct {
+	rate       float64
+	burst      int
+	buckets    map[string]*bucket
+	refillTime time.Duration
+	lastSweep  time.Time
+	locker     *sync.Mutex
+}
+
+// NewLocalTokenBucket is the in-memory token bucket for tests and single instance
+func NewLocalTokenBucket(rate float64, burst int) *localTokenBucket {
+	return &localTokenBucket{
+		rate:       rate,
+		burst:      burst,
+		buckets:    make(map[string]*bucket),
+		refillTime: time.Duration(float64(burst) / rate * float64(time.Second)),
+		lastSweep:  time.Now(),
+		locker:     new(sync.Mutex),
+	}
+}
+
+func (l *localTokenBucket) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *localTokenBucket) AllowN(ctx context.Context, key string, n int) (*Result, error) {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(l.lastSweep) >= l.refillTime {
+		l.sweep(now)
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens: float64(l.burst),
+			last:   now,
+		}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	allowed := b.tokens >= float64(n)
+	if allowed {
+		b.tokens -= float64(n)
+	}
+
+	return tokenBucketResult(allowed, b.tokens, n, l.rate), nil
+}
+
+// sweep deletes the buckets refilled to the burst, the caller holds the lock
+func (l *localTokenBucket) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.refillTime {
+			delete(l.buckets, key)
+		}
+	}
+}
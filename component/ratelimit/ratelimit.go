@@ -0,0 +1,19 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+type Result struct {
+	Allowed bool
+	// Remaining is the number of requests still allowed right now
+	Remaining int
+	// RetryAfter is the time to wait before the next request is allowed, 0 if allowed
+	RetryAfter time.Duration
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string) (*Result, error)
+	AllowN(ctx context.Context, key string, n int) (*Result, error)
+}
@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+type redisDB struct {
+	client redis.UniversalClient
+}
+
+func (db *redisDB) GetDB() redis.UniversalClient {
+	return db.client
+}
+
+func newTestRedisDB(t *testing.T) *redisDB {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return &redisDB{client: client}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	ctx := context.Background()
+
+	for name, limiter := range map[string]Limiter{
+		"local": NewLocalSlidingWindow(3, time.Millisecond*100),
+		"redis": NewRedisSlidingWindow(newTestRedisDB(t), 3, time.Millisecond*100),
+	} {
+		t.Run(name, func(t *testing.T) {
+			for i := 2; i >= 0; i-- {
+				result, err := limiter.Allow(ctx, "user:1")
+				require.NoError(t, err)
+				require.True(t, result.Allowed)
+				require.Equal(t, i, result.Remaining)
+			}
+
+			result, err := limiter.Allow(ctx, "user:1")
+			require.NoError(t, err)
+			require.False(t, result.Allowed)
+			require.Greater(t, result.RetryAfter, time.Duration(0))
+
+			// Other keys are limited separately
+			result, err = limiter.Allow(ctx, "user:2")
+			require.NoError(t, err)
+			require.True(t, result.Allowed)
+
+			time.Sleep(result.RetryAfter + time.Millisecond*110)
+
+			result, err = limiter.AllowN(ctx, "user:1", 3)
+			require.NoError(t, err)
+			require.True(t, result.Allowed)
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	ctx := context.Background()
+
+	for name, limiter := range map[string]Limiter{
+		"local": NewLocalTokenBucket(20, 2),
+		"redis": NewRedisTokenBucket(newTestRedisDB(t), 20, 2),
+	} {
+		t.Run(name, func(t *testing.T) {
+			result, err := limiter.AllowN(ctx, "user:1", 2)
+			require.NoError(t, err)
+			require.True(t, result.Allowed)
+			require.Equal(t, 0, result.Remaining)
+
+			result, err = limiter.Allow(ctx, "user:1")
+			require.NoError(t, err)
+			require.False(t, result.Allowed)
+			require.Greater(t, result.RetryAfter, time.Duration(0))
+			require.LessOrEqual(t, result.RetryAfter, time.Millisecond*50)
+
+			// Refilled 1 token every 50ms
+			time.Sleep(result.RetryAfter + time.Millisecond*10)
+
+			result, err = limiter.Allow(ctx, "user:1")
+			require.NoError(t, err)
+			require.True(t, result.Allowed)
+		})
+	}
+}
+
+func TestLocalSweep(t *testing.T) {
+	ctx := context.Background()
+
+	window := NewLocalSlidingWindow(3, time.Millisecond*20)
+	bucket := NewLocalTokenBucket(100, 2)
+	for _, key := range []string{"ip:1", "ip:2", "ip:3"} {
+		_, _ = window.Allow(ctx, key)
+		_, _ = bucket.Allow(ctx, key)
+	}
+
+	require.Len(t, window.requests, 3)
+	require.Len(t, bucket.buckets, 3)
+
+	// Idle keys are swept by the next call
+	time.Sleep(time.Millisecond * 30)
+	_, _ = window.Allow(ctx, "ip:4")
+	_, _ = bucket.Allow(ctx, "ip:4")
+
+	require.Len(t, window.requests, 1)
+	require.Len(t, bucket.buckets, 1)
+}
+
+func TestRedisLimitersKeys(t *testing.T) {
+	ctx := context.Background()
+	redisDB := newTestRedisDB(t)
+
+	strict := NewRedisSlidingWindow(redisDB, 1, time.Minute)
+	loose := NewRedisSlidingWindow(redisDB, 10, time.Minute)
+	bucket := NewRedisTokenBucket(redisDB, 1, 1)
+
+	// Same key with other algorithms and limits, the states are separate
+	for _, limiter := range []Limiter{strict, loose, bucket} {
+		result, err := limiter.Allow(ctx, "user:1")
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+	}
+
+	result, err := strict.Allow(ctx, "user:1")
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+
+	result, err = loose.Allow(ctx, "user:1")
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 8, result.Remaining)
+}
@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/redis/go-redis/v9"
+)
+
+// The keys are per algorithm and limit, so limiters of other kinds or limits do not share the state of a key
+const keyPrefix = "ratelimit:"
+
+// The scripts use the clock of Redis, so the instances agree on the time
+
+var (
+	// Sorted set of request timestamps in the window
+	slidingWindowScript = redis.NewScript(`
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+
+if count + n <= limit then
+	for i = 1, n do
+		redis.call("ZADD", KEYS[1], now, ARGV[4] .. ":" .. i)
+	end
+	redis.call("PEXPIRE", KEYS[1], window)
+	return {1, limit - count - n, 0}
+end
+
+local retryAfter = 0
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+if oldest[2] then
+	retryAfter = tonumber(oldest[2]) + window - now
+end
+
+return {0, limit - count, retryAfter}
+`)
+
+	// Hash of the remaining tokens and the last refill time
+	tokenBucketScript = redis.NewScript(`
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate))
+
+return {allowed, tostring(tokens)}
+`)
+)
+
+type redisSlidingWindow struct {
+	redisDB core.RedisDBComponent
+	limit   int
+	window  time.Duration
+}
+
+// NewRedisSlidingWindow allows limit requests per key in any window of time
+func NewRedisSlidingWindow(redisDB core.RedisDBComponent, limit int, window time.Duration) *redisSlidingWindow {
+	return &redisSlidingWindow{
+		redisDB: redisDB,
+		limit:   limit,
+		window:  window,
+	}
+}
+
+// key is ratelimit:sw:<limit>:<window ms>:<key>
+func (l *redisSlidingWindow) key(key string) string {
+	return fmt.Sprintf("%ssw:%d:%d:%s", keyPrefix, l.limit, l.window.Milliseconds(), key)
+}
+
+func (l *redisSlidingWindow) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *redisSlidingWindow) AllowN(ctx context.Context, key string, n int) (*Result, error) {
+	values, err := slidingWindowScript.Run(ctx, l.redisDB.GetDB(), []string{l.key(key)},
+		l.window.Milliseconds(),
+		l.limit,
+		n,
+		uuid.NewString(),
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
+
+type redisTokenBucket struct {
+	redisDB core.RedisDBComponent
+	rate    float64
+	burst   int
+}
+
+// NewRedisTokenBucket refills rate tokens per second per key, up to burst tokens
+func NewRedisTokenBucket(redisDB core.RedisDBComponent, rate float64, burst int) *redisTokenBucket {
+	return &redisTokenBucket{
+		redisDB: redisDB,
+		rate:    rate,
+		burst:   burst,
+	}
+}
+
+// key is ratelimit:tb:<rate>:<burst>:<key>
+func (l *redisTokenBucket) key(key string) string {
+	return fmt.Sprintf("%stb:%s:%d:%s", keyPrefix, strconv.FormatFloat(l.rate, 'g', -1, 64), l.burst, key)
+}
+
+func (l *redisTokenBucket) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *redisTokenBucket) AllowN(ctx context.Context, key string, n int) (*Result, error) {
+	values, err := tokenBucketScript.Run(ctx, l.redisDB.GetDB(), []string{l.key(key)},
+		l.rate/1000, // Tokens per millisecond
+		l.burst,
+		n,
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := strconv.ParseFloat(values[1].(string), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenBucketResult(values[0].(int64) == 1, tokens, n, l.rate), nil
+}
+
+func tokenBucketResult(allowed bool, tokens float64, n int, rate float64) *Result {
+	result := &Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+	}
+
+	if !allowed {
+		result.RetryAfter = time.Duration((float64(n) - tokens) / rate * float64(time.Second))
+	}
+
+	return result
+}
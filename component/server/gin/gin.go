@@ -11,6 +11,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	appctx "github.com/hoangtk0100/app-context"
+	"github.com/hoangtk0100/app-context/component/tracing"
+	"github.com/hoangtk0100/app-context/core"
+	"github.com/hoangtk0100/app-context/logadapter"
 	"github.com/spf13/pflag"
 )
 
@@ -20,8 +23,10 @@ const (
 )
 
 type config struct {
-	address string
-	mode    string
+	address       string
+	mode          string
+	enableTracing bool
+	metricsID     string
 }
 
 type ginServer struct {
@@ -43,20 +48,34 @@ func (gs *ginServer) ID() string {
 	return gs.id
 }
 
-func (gs *ginServer) InitFlags() {
-	pflag.StringVar(
+func (gs *ginServer) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(
 		&gs.address,
 		"gin-address",
 		defaultServerAddress,
 		fmt.Sprintf("Gin server address - Default: %s", defaultServerAddress),
 	)
 
-	pflag.StringVar(
+	fs.StringVar(
 		&gs.mode,
 		"gin-mode",
 		defaultMode,
 		"Gin mode (debug | release) - Default: debug",
 	)
+
+	fs.BoolVar(
+		&gs.enableTracing,
+		"gin-enable-tracing",
+		false,
+		"Gin enable tracing (OpenTelemetry) - Default: false",
+	)
+
+	fs.StringVar(
+		&gs.metricsID,
+		"gin-metrics-id",
+		"",
+		"Gin metrics component ID, also serves the metrics on the router, empty disables metrics - Ex: metrics",
+	)
 }
 
 func (gs *ginServer) Run(ac appctx.AppContext) error {
@@ -67,7 +86,27 @@ func (gs *ginServer) Run(ac appctx.AppContext) error {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	gs.router = gin.Default()
+	logadapter.SetGinWriters(gs.logger)
+
+	gs.router = gin.New()
+
+	// Before Recovery, so the span and the metrics record the status of the recovered panics
+	if gs.enableTracing {
+		gs.router.Use(tracing.GinMiddleware())
+	}
+
+	var metrics core.MetricsComponent
+	if gs.metricsID != "" {
+		metrics = ac.MustGet(gs.metricsID).(core.MetricsComponent)
+		gs.router.Use(metrics.GinMiddleware())
+	}
+
+	gs.router.Use(logadapter.GinLogger(gs.logger), gin.Recovery())
+
+	if metrics != nil {
+		metrics.RegisterGin(gs.router)
+	}
+
 	gs.logger.Info("Init Gin server")
 
 	return nil
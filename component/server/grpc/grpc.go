@@ -13,7 +13,9 @@ import (
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	appctx "github.com/hoangtk0100/app-context"
+	"github.com/hoangtk0100/app-context/component/tracing"
 	"github.com/hoangtk0100/app-context/core"
+	"github.com/hoangtk0100/app-context/logadapter"
 	"github.com/rakyll/statik/fs"
 	"github.com/spf13/pflag"
 	"google.golang.org/grpc"
@@ -38,6 +40,8 @@ type config struct {
 	enableSwagger              bool
 	swaggerPrefix              string
 	enableMetrics              bool
+	metricsID                  string
+	enableTracing              bool
 	mapProtoResponseFieldStyle bool
 	shutdownTimeout            time.Duration
 	serverOptions              []grpc.ServerOption
@@ -71,64 +75,78 @@ func (gs *grpcServer) ID() string {
 	return gs.id
 }
 
-func (gs *grpcServer) InitFlags() {
-	pflag.StringVar(
+func (gs *grpcServer) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(
 		&gs.address,
 		"grpc-server-address",
 		defaultServerAddress,
 		fmt.Sprintf("GRPC server address - Default: %q", defaultServerAddress),
 	)
 
-	pflag.StringVar(
+	fs.StringVar(
 		&gs.tlsCertFile,
 		"grpc-server-tls-cert-file",
 		"",
 		"GRPC server TLS cert file",
 	)
 
-	pflag.StringVar(
+	fs.StringVar(
 		&gs.tlsKeyFile,
 		"grpc-server-tls-key-file",
 		"",
 		"GRPC server TLS key file",
 	)
 
-	pflag.StringVar(
+	fs.StringVar(
 		&gs.apiPrefix,
 		"grpc-server-api-prefix",
 		defaultAPIPrefix,
 		fmt.Sprintf("GRPC server API prefix - Default: %q", defaultAPIPrefix),
 	)
 
-	pflag.BoolVar(
+	fs.BoolVar(
 		&gs.enableSwagger,
 		"grpc-server-enable-swagger",
 		false,
 		"GRPC server enable Swagger - Default: false",
 	)
 
-	pflag.StringVar(
+	fs.StringVar(
 		&gs.swaggerPrefix,
 		"grpc-server-swagger-prefix",
 		defaultSwaggerPrefix,
 		fmt.Sprintf("GRPC server Swagger prefix - Default: %q", defaultSwaggerPrefix),
 	)
 
-	pflag.BoolVar(
+	fs.BoolVar(
 		&gs.enableMetrics,
 		"grpc-server-enable-metrics",
 		false,
 		"GRPC server enable metrics (Prometheus) - Default: false",
 	)
 
-	pflag.BoolVar(
+	fs.StringVar(
+		&gs.metricsID,
+		"grpc-server-metrics-id",
+		"",
+		"GRPC server metrics component ID, enables metrics and serves them on the HTTP gateway - Ex: metrics",
+	)
+
+	fs.BoolVar(
+		&gs.enableTracing,
+		"grpc-server-enable-tracing",
+		false,
+		"GRPC server enable tracing (OpenTelemetry) - Default: false",
+	)
+
+	fs.BoolVar(
 		&gs.mapProtoResponseFieldStyle,
 		"grpc-server-map-proto-response-field-style",
 		true,
 		"GRPC server map proto response field style - Default: true (false: camelCase)",
 	)
 
-	pflag.DurationVar(
+	fs.DurationVar(
 		&gs.shutdownTimeout,
 		"grpc-server-shutdown-timeout",
 		defaultShutdownTimeout,
@@ -139,6 +157,7 @@ func (gs *grpcServer) InitFlags() {
 func (gs *grpcServer) Run(ac appctx.AppContext) error {
 	gs.ac = ac
 	gs.logger = ac.Logger(gs.id)
+	logadapter.SetGrpcLogger(ac.Logger("grpc"))
 	gs.lis = gs.getListener()
 
 	if (gs.tlsCertFile != "" && gs.tlsKeyFile == "") ||
@@ -150,11 +169,20 @@ func (gs *grpcServer) Run(ac appctx.AppContext) error {
 		gs.logger.Fatal(ErrSwaggerPrefixMissing)
 	}
 
+	if gs.metricsID != "" {
+		gs.enableMetrics = true
+	}
+
 	if gs.enableMetrics {
 		gs.streamInterceptors = append(gs.streamInterceptors, grpc_prometheus.StreamServerInterceptor)
 		gs.unaryInterceptors = append(gs.unaryInterceptors, grpc_prometheus.UnaryServerInterceptor)
 	}
 
+	if gs.enableTracing {
+		gs.streamInterceptors = append(gs.streamInterceptors, tracing.StreamServerInterceptor())
+		gs.unaryInterceptors = append(gs.unaryInterceptors, tracing.UnaryServerInterceptor())
+	}
+
 	gs.serverOptions = append(gs.serverOptions, grpc.UnaryInterceptor(GrpcLogger))
 
 	if gs.isSecured() {
@@ -300,6 +328,23 @@ func (gs *grpcServer) serveSwagger(mux *http.ServeMux) {
 	}
 }
 
+func (gs *grpcServer) serveMetrics(mux *http.ServeMux) {
+	if gs.metricsID == "" {
+		return
+	}
+
+	// The gateway receives the requests out of the API prefix only if it is the root
+	metricsMux := gs.gateway
+	if gs.apiPrefix != "/" {
+		metricsMux = runtime.NewServeMux()
+		mux.Handle("/", metricsMux)
+	}
+
+	if err := gs.ac.MustGet(gs.metricsID).(core.MetricsComponent).RegisterGateway(metricsMux); err != nil {
+		gs.logger.Error(err, "Cannot serve metrics on HTTP gateway")
+	}
+}
+
 func (gs *grpcServer) updateServerOptions() {
 	if len(gs.streamInterceptors) > 0 {
 		gs.serverOptions = append(gs.serverOptions, grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(gs.streamInterceptors...)))
@@ -336,10 +381,16 @@ func (gs *grpcServer) startGateway(ctx context.Context) {
 	mux.Handle(gs.apiPrefix, gs.gateway)
 
 	gs.serveSwagger(mux)
+	gs.serveMetrics(mux)
 
 	gs.logger.Infof("Start HTTP gateway server at %s", gs.address)
 
-	err := http.Serve(gs.lis, HttpLogger(mux))
+	var handler http.Handler = mux
+	if gs.enableTracing {
+		handler = tracing.HTTPHandler(handler)
+	}
+
+	err := http.Serve(gs.lis, HttpLogger(handler))
 	if err != nil {
 		gs.logger.Fatal(err, ErrCannotStartGatewayServer.Error())
 	}
@@ -38,13 +38,13 @@ func (storage *s3Storage) ID() string {
 	return storage.id
 }
 
-func (storage *s3Storage) InitFlags() {
-	pflag.StringVar(&storage.accessKey, "storage-access-key", "", "Cloud storage access key")
-	pflag.StringVar(&storage.secretKey, "storage-secret-key", "", "Cloud storage secret key")
-	pflag.StringVar(&storage.region, "storage-region", "", "Cloud storage region")
-	pflag.StringVar(&storage.bucketName, "storage-bucket", "", "Cloud storage bucket name")
-	pflag.StringVar(&storage.endPoint, "storage-end-point", "", "Cloud storage end point")
-	pflag.StringVar(&storage.domain, "storage-domain", "", "Cloud storage domain")
+func (storage *s3Storage) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&storage.accessKey, "storage-access-key", "", "Cloud storage access key")
+	fs.StringVar(&storage.secretKey, "storage-secret-key", "", "Cloud storage secret key")
+	fs.StringVar(&storage.region, "storage-region", "", "Cloud storage region")
+	fs.StringVar(&storage.bucketName, "storage-bucket", "", "Cloud storage bucket name")
+	fs.StringVar(&storage.endPoint, "storage-end-point", "", "Cloud storage end point")
+	fs.StringVar(&storage.domain, "storage-domain", "", "Cloud storage domain")
 }
 
 func (storage *s3Storage) Run(ac appctx.AppContext) error {
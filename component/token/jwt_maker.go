@@ -42,20 +42,20 @@ func (maker *jwtMaker) ID() string {
 	return maker.id
 }
 
-func (maker *jwtMaker) InitFlags() {
-	pflag.StringVar(&maker.secretKey,
+func (maker *jwtMaker) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&maker.secretKey,
 		"jwt-secret-key",
 		"",
 		fmt.Sprintf("JWT secret key - Key size >= %d", minJWTSecretKeySize),
 	)
 
-	pflag.DurationVar(&maker.accessTokenExpiresIn,
+	fs.DurationVar(&maker.accessTokenExpiresIn,
 		"access-token-expires-in",
 		defaultAccessTokenExpiresIn,
 		"Access token expires in duration - Ex: 2h - Default: 168h",
 	)
 
-	pflag.DurationVar(&maker.refreshTokenExpiresIn,
+	fs.DurationVar(&maker.refreshTokenExpiresIn,
 		"refresh-token-expires-in",
 		defaultRefreshTokenExpiresIn,
 		"Refresh token expires in duration - Ex: 2h - Default: 336h",
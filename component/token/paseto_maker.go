@@ -47,20 +47,20 @@ func (maker *pasetoMaker) ID() string {
 	return maker.id
 }
 
-func (maker *pasetoMaker) InitFlags() {
-	pflag.StringVar(&maker.symmetricKey,
+func (maker *pasetoMaker) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&maker.symmetricKey,
 		"paseto-symmetric-key",
 		"",
 		fmt.Sprintf("PASETO symmetric key - Key size: %d", pasetoSymmetricKeySize),
 	)
 
-	pflag.DurationVar(&maker.accessTokenExpiresIn,
+	fs.DurationVar(&maker.accessTokenExpiresIn,
 		"access-token-expires-in",
 		defaultAccessTokenExpiresIn,
 		"Access token expires in duration - Ex: 2h - Default: 168h",
 	)
 
-	pflag.DurationVar(&maker.refreshTokenExpiresIn,
+	fs.DurationVar(&maker.refreshTokenExpiresIn,
 		"refresh-token-expires-in",
 		defaultRefreshTokenExpiresIn,
 		"Refresh token expires in duration - Ex: 2h - Default: 336h",
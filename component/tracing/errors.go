@@ -0,0 +1,7 @@
+package tracing
+
+import "errors"
+
+var (
+	ErrExporterNotSupported = errors.New("tracing exporter not supported")
+)
@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/hoangtk0100/app-context/component/datastore/gormdb/dialects"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+type user struct {
+	ID   uint64 `gorm:"primaryKey"`
+	Name string
+}
+
+func spanAttribute(attrs []attribute.KeyValue, key attribute.Key) attribute.Value {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+
+	return attribute.Value{}
+}
+
+func TestGormPlugin(t *testing.T) {
+	db, err := dialects.SQLiteDB(filepath.Join(t.TempDir(), "tracing.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&user{}))
+	require.NoError(t, db.Use(GormPlugin()))
+
+	exporter := setupTracing(t)
+
+	ctx, parent := tracer().Start(context.Background(), "parent")
+	db = db.WithContext(ctx)
+
+	require.NoError(t, db.Create(&user{ID: 1, Name: "alice"}).Error)
+	require.ErrorIs(t, db.First(&user{}, 2).Error, gorm.ErrRecordNotFound)
+	require.Error(t, db.Exec("SELECT * FROM missing").Error)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 4)
+
+	create, query, raw := spans[0], spans[1], spans[2]
+	for _, span := range spans[:3] {
+		require.Equal(t, trace.SpanKindClient, span.SpanKind)
+		require.Equal(t, spans[3].SpanContext.SpanID(), span.Parent.SpanID())
+		require.Equal(t, "sqlite", spanAttribute(span.Attributes, semconv.DBSystemKey).AsString())
+	}
+
+	require.Equal(t, "gorm.create", create.Name)
+	require.Equal(t, "users", spanAttribute(create.Attributes, dbTableKey).AsString())
+	require.Equal(t, int64(1), spanAttribute(create.Attributes, dbRowsAffectedKey).AsInt64())
+	require.Contains(t, spanAttribute(create.Attributes, semconv.DBStatementKey).AsString(), "INSERT INTO `users`")
+
+	// Record not found is not an error
+	require.Equal(t, "gorm.query", query.Name)
+	require.Equal(t, "Unset", query.Status.Code.String())
+
+	require.Equal(t, "gorm.raw", raw.Name)
+	require.Equal(t, "Error", raw.Status.Code.String())
+	require.Len(t, raw.Events, 1)
+}
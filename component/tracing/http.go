@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func startHTTPSpan(req *http.Request, route string) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+	spanName := fmt.Sprintf("%s %s", req.Method, route)
+	if route == "" {
+		spanName = fmt.Sprintf("HTTP %s", req.Method)
+	}
+
+	ctx, span := tracer().Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethod(req.Method),
+			semconv.HTTPTarget(req.URL.RequestURI()),
+			semconv.HTTPUserAgent(req.UserAgent()),
+		),
+	)
+
+	if route != "" {
+		span.SetAttributes(semconv.HTTPRoute(route))
+	}
+
+	return req.WithContext(ctx), span
+}
+
+func endHTTPSpan(span trace.Span, statusCode int) {
+	span.SetAttributes(semconv.HTTPStatusCode(statusCode))
+	if statusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(statusCode))
+	}
+
+	span.End()
+}
+
+// GinMiddleware starts a server span for every request, continuing the incoming trace
+func GinMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		req, span := startHTTPSpan(ctx.Request, ctx.FullPath())
+		ctx.Request = req
+
+		ctx.Next()
+
+		for _, err := range ctx.Errors {
+			span.RecordError(err.Err)
+		}
+
+		endHTTPSpan(span, ctx.Writer.Status())
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rec *statusRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Flush supports streaming responses, ex: server streams of the GRPC gateway
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the original writer to http.ResponseController
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// HTTPHandler starts a server span for every request, ex: to wrap the GRPC gateway
+func HTTPHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		req, span := startHTTPSpan(req, "")
+		rec := &statusRecorder{ResponseWriter: res, statusCode: http.StatusOK}
+
+		handler.ServeHTTP(rec, req)
+
+		endHTTPSpan(span, rec.statusCode)
+	})
+}
@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRedisHook(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	client.AddHook(RedisHook())
+
+	exporter := setupTracing(t)
+	ctx, parent := tracer().Start(context.Background(), "parent")
+
+	require.NoError(t, client.Set(ctx, "key", "secret", 0).Err())
+	require.ErrorIs(t, client.Get(ctx, "missing").Err(), redis.Nil)
+	require.Error(t, client.Incr(ctx, "key").Err())
+
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Get(ctx, "key")
+		pipe.Del(ctx, "key")
+		return nil
+	})
+	require.NoError(t, err)
+	parent.End()
+
+	var names []string
+	spans := exporter.GetSpans()
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	require.Equal(t, []string{"redis.dial", "redis.set", "redis.get", "redis.incr", "redis.pipeline", "parent"}, names)
+
+	for _, span := range spans[:5] {
+		require.Equal(t, trace.SpanKindClient, span.SpanKind)
+		require.Equal(t, "redis", spanAttribute(span.Attributes, semconv.DBSystemKey).AsString())
+		require.Equal(t, spans[5].SpanContext.TraceID(), span.SpanContext.TraceID())
+	}
+
+	// The arguments are not recorded
+	for _, attr := range spans[1].Attributes {
+		require.NotContains(t, attr.Value.Emit(), "secret")
+	}
+
+	// redis.Nil is not an error
+	require.Equal(t, "Unset", spans[2].Status.Code.String())
+	require.Equal(t, "Error", spans[3].Status.Code.String())
+	require.Equal(t, int64(2), spanAttribute(spans[4].Attributes, redisPipelineLengthKey).AsInt64())
+}
@@ -0,0 +1,235 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/spf13/pflag"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/hoangtk0100/app-context/component/tracing"
+
+	exporterNone     = "none"
+	exporterOTLPGRPC = "otlp-grpc"
+	exporterOTLPHTTP = "otlp-http"
+	exporterStdout   = "stdout"
+	exporterMemory   = "memory"
+
+	defaultSampleRatio     = 1.0
+	defaultShutdownTimeout = time.Second * 5
+)
+
+type config struct {
+	exporter    string
+	endpoint    string
+	insecure    bool
+	serviceName string
+	sampleRatio float64
+}
+
+type tracing struct {
+	id             string
+	logger         appctx.Logger
+	provider       *sdktrace.TracerProvider
+	memoryExporter *tracetest.InMemoryExporter
+	*config
+}
+
+func NewTracing(id string) *tracing {
+	return &tracing{
+		id:     id,
+		config: new(config),
+	}
+}
+
+func (t *tracing) ID() string {
+	return t.id
+}
+
+func (t *tracing) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(
+		&t.exporter,
+		"tracing-exporter",
+		exporterNone,
+		"Tracing exporter (none | otlp-grpc | otlp-http | stdout | memory) - Default: none",
+	)
+
+	fs.StringVar(
+		&t.endpoint,
+		"tracing-endpoint",
+		"",
+		"Tracing OTLP collector endpoint - Ex: localhost:4317 (otlp-grpc), localhost:4318 (otlp-http)",
+	)
+
+	fs.BoolVar(
+		&t.insecure,
+		"tracing-insecure",
+		false,
+		"Tracing OTLP exporter without TLS - Default: false",
+	)
+
+	fs.StringVar(
+		&t.serviceName,
+		"tracing-service-name",
+		"",
+		"Tracing service name - Default: app name",
+	)
+
+	fs.Float64Var(
+		&t.sampleRatio,
+		"tracing-sample-ratio",
+		defaultSampleRatio,
+		fmt.Sprintf("Tracing sample ratio of root spans (0 -> 1) - Default: %.1f", defaultSampleRatio),
+	)
+}
+
+func (t *tracing) isDisabled() bool {
+	return t.exporter == "" || t.exporter == exporterNone
+}
+
+func (t *tracing) Run(ac appctx.AppContext) error {
+	if t.isDisabled() {
+		return nil
+	}
+
+	t.logger = ac.Logger(t.id)
+
+	exporter, err := t.getExporter()
+	if err != nil {
+		t.logger.Error(err, "Cannot create tracing exporter")
+		return err
+	}
+
+	serviceName := t.serviceName
+	if serviceName == "" {
+		serviceName = ac.GetName()
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+			semconv.DeploymentEnvironment(ac.GetEnvName()),
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(t.sampleRatio))),
+	}
+
+	if t.exporter == exporterMemory {
+		// Export synchronously so spans can be asserted right after they end
+		opts = append(opts, sdktrace.WithSyncer(exporter))
+	} else {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	t.provider = sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(t.provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	t.logger.Infof("Init tracing with %s exporter", t.exporter)
+
+	return nil
+}
+
+func (t *tracing) Stop() error {
+	if t.provider == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+
+	return t.provider.Shutdown(ctx)
+}
+
+func (t *tracing) getExporter() (sdktrace.SpanExporter, error) {
+	switch t.exporter {
+	case exporterOTLPGRPC:
+		opts := []otlptracegrpc.Option{}
+		if t.endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(t.endpoint))
+		}
+
+		if t.insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+
+		return otlptracegrpc.New(context.Background(), opts...)
+
+	case exporterOTLPHTTP:
+		opts := []otlptracehttp.Option{}
+		if t.endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(t.endpoint))
+		}
+
+		if t.insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+
+		return otlptracehttp.New(context.Background(), opts...)
+
+	case exporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+
+	case exporterMemory:
+		t.memoryExporter = tracetest.NewInMemoryExporter()
+		return t.memoryExporter, nil
+	}
+
+	return nil, ErrExporterNotSupported
+}
+
+func (t *tracing) GetTracerProvider() trace.TracerProvider {
+	if t.provider == nil {
+		return otel.GetTracerProvider()
+	}
+
+	return t.provider
+}
+
+func (t *tracing) Tracer(name string) trace.Tracer {
+	return t.GetTracerProvider().Tracer(name)
+}
+
+// GetSpans returns the ended spans, only available with the memory exporter
+func (t *tracing) GetSpans() tracetest.SpanStubs {
+	if t.memoryExporter == nil {
+		return nil
+	}
+
+	return t.memoryExporter.GetSpans()
+}
+
+func (t *tracing) ResetSpans() {
+	if t.memoryExporter != nil {
+		t.memoryExporter.Reset()
+	}
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
@@ -0,0 +1,210 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func setupTracing(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+	})
+
+	return exporter
+}
+
+func TestGinMiddleware(t *testing.T) {
+	exporter := setupTracing(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GinMiddleware())
+	router.GET("/users/:id", func(ctx *gin.Context) {
+		require.True(t, trace.SpanFromContext(ctx.Request.Context()).SpanContext().IsValid())
+		ctx.Status(http.StatusInternalServerError)
+	})
+
+	parentCtx, parent := tracer().Start(context.Background(), "parent")
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	otel.GetTextMapPropagator().Inject(parentCtx, propagation.HeaderCarrier(req.Header))
+	parent.End()
+
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+
+	span := spans[1]
+	require.Equal(t, "GET /users/:id", span.Name)
+	require.Equal(t, spans[0].SpanContext.TraceID(), span.SpanContext.TraceID())
+	require.Equal(t, spans[0].SpanContext.SpanID(), span.Parent.SpanID())
+	require.Equal(t, "Error", span.Status.Code.String())
+}
+
+func TestGRPCPropagation(t *testing.T) {
+	exporter := setupTracing(t)
+
+	const method = "/demo.DemoService/Ping"
+
+	serverHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "pong", nil
+	}
+
+	// The client invoker hands the outgoing metadata to the server interceptor
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		serverCtx := metadata.NewIncomingContext(context.Background(), md)
+
+		_, err := UnaryServerInterceptor()(serverCtx, req, &grpc.UnaryServerInfo{FullMethod: method}, serverHandler)
+		return err
+	}
+
+	err := UnaryClientInterceptor()(context.Background(), method, "ping", nil, nil, invoker)
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+
+	server, client := spans[0], spans[1]
+	require.Equal(t, trace.SpanKindServer, server.SpanKind)
+	require.Equal(t, trace.SpanKindClient, client.SpanKind)
+	require.Equal(t, "demo.DemoService/Ping", client.Name)
+	require.Equal(t, client.SpanContext.TraceID(), server.SpanContext.TraceID())
+	require.Equal(t, client.SpanContext.SpanID(), server.Parent.SpanID())
+}
+
+func TestGinMiddlewareRecovery(t *testing.T) {
+	exporter := setupTracing(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GinMiddleware(), gin.RecoveryWithWriter(io.Discard))
+	router.GET("/panic", func(ctx *gin.Context) {
+		panic("boom")
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "Error", spans[0].Status.Code.String())
+	require.Equal(t, int64(http.StatusInternalServerError), spanAttribute(spans[0].Attributes, semconv.HTTPStatusCodeKey).AsInt64())
+}
+
+func TestHTTPHandler(t *testing.T) {
+	exporter := setupTracing(t)
+
+	handler := HTTPHandler(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		require.True(t, trace.SpanFromContext(req.Context()).SpanContext().IsValid())
+
+		res.WriteHeader(http.StatusAccepted)
+		_, _ = res.Write([]byte("chunk"))
+
+		// Flushed through the wrapper, ex: streamed responses
+		res.(http.Flusher).Flush()
+		require.NoError(t, http.NewResponseController(res).Flush())
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/users", nil))
+	require.True(t, rec.Flushed)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "HTTP POST", spans[0].Name)
+	require.Equal(t, int64(http.StatusAccepted), spanAttribute(spans[0].Attributes, semconv.HTTPStatusCodeKey).AsInt64())
+}
+
+// testServerStream and testClientStream connect the stream interceptors without a network
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+type testClientStream struct {
+	grpc.ClientStream
+	err error
+}
+
+func (s *testClientStream) RecvMsg(m interface{}) error {
+	return s.err
+}
+
+func TestGRPCStreamPropagation(t *testing.T) {
+	exporter := setupTracing(t)
+
+	const method = "/demo.DemoService/Watch"
+
+	serverHandler := func(srv interface{}, stream grpc.ServerStream) error {
+		require.True(t, trace.SpanFromContext(stream.Context()).SpanContext().IsValid())
+		return status.Error(grpccodes.Unavailable, "stream closed")
+	}
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		serverStream := &testServerStream{ctx: metadata.NewIncomingContext(context.Background(), md)}
+
+		err := StreamServerInterceptor()(nil, serverStream, &grpc.StreamServerInfo{FullMethod: method}, serverHandler)
+		return &testClientStream{err: err}, nil
+	}
+
+	stream, err := StreamClientInterceptor()(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, method, streamer)
+	require.NoError(t, err)
+
+	// The client span ends with the stream
+	require.Len(t, exporter.GetSpans(), 1)
+	require.Error(t, stream.RecvMsg(nil))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+
+	server, client := spans[0], spans[1]
+	require.Equal(t, trace.SpanKindServer, server.SpanKind)
+	require.Equal(t, trace.SpanKindClient, client.SpanKind)
+	require.Equal(t, "demo.DemoService/Watch", client.Name)
+	require.Equal(t, client.SpanContext.SpanID(), server.Parent.SpanID())
+	require.Equal(t, "Error", server.Status.Code.String())
+	require.Equal(t, "stream closed", client.Status.Description)
+}
+
+func TestGRPCStreamEOF(t *testing.T) {
+	exporter := setupTracing(t)
+
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &testClientStream{err: io.EOF}, nil
+	}
+
+	stream, err := StreamClientInterceptor()(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/demo.DemoService/Watch", streamer)
+	require.NoError(t, err)
+	require.ErrorIs(t, stream.RecvMsg(nil), io.EOF)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "Unset", spans[0].Status.Code.String())
+}
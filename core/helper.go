@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 	"net"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -10,7 +11,9 @@ import (
 	appctx "github.com/hoangtk0100/app-context"
 	"github.com/hoangtk0100/app-context/component/pubsub"
 	"github.com/hoangtk0100/app-context/component/token"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"gorm.io/gorm"
 )
@@ -19,14 +22,68 @@ type GormDBComponent interface {
 	GetDB() *gorm.DB
 }
 
+// OutboxComponent writes messages in a transaction, they are published once it is committed
+type OutboxComponent interface {
+	Publish(tx *gorm.DB, topic pubsub.Topic, msg *pubsub.Message) error
+}
+
 type RedisDBComponent interface {
-	GetDB() *redis.Client
+	GetDB() redis.UniversalClient
 }
 
 type CacheComponent interface {
 	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
 	Get(ctx context.Context, key string, value interface{}) error
 	Delete(ctx context.Context, key string) error
+
+	MGet(ctx context.Context, values map[string]interface{}) (missing []string, err error)
+	MSet(ctx context.Context, values map[string]interface{}, ttl time.Duration) error
+	MDelete(ctx context.Context, keys ...string) error
+
+	Exists(ctx context.Context, key string) (bool, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	TTL(ctx context.Context, key string) (time.Duration, error)
+
+	Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+	Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+
+	DeleteByPrefix(ctx context.Context, prefix string) error
+	SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) error
+	InvalidateTags(ctx context.Context, tags ...string) error
+
+	GetOrLoad(ctx context.Context, key string, dst interface{}, ttl time.Duration, loader LoaderFunc, opts ...LoadOption) error
+}
+
+// LoaderFunc loads the value of GetOrLoad on cache miss, return cache.ErrNotFound to cache a negative result
+type LoaderFunc func(ctx context.Context) (interface{}, error)
+
+// LoadOptions of GetOrLoad, set by cache.WithNegativeTTL and cache.WithStaleTTL
+type LoadOptions struct {
+	NegativeTTL time.Duration
+	StaleTTL    time.Duration
+}
+
+type LoadOption func(*LoadOptions)
+
+// DedupState is the result of DedupStore.Claim
+type DedupState int
+
+const (
+	// DedupClaimed - the key is leased to the caller for processing
+	DedupClaimed DedupState = iota
+	// DedupProcessing - the key is leased to another delivery, the message is not processed yet
+	DedupProcessing
+	// DedupDone - the message is already processed
+	DedupDone
+)
+
+// DedupStore records the processed message keys.
+// A key is leased to one owner while the message is processed, only the owner can extend, complete or release it
+type DedupStore interface {
+	Claim(ctx context.Context, key, owner string, ttl time.Duration) (DedupState, error)
+	Extend(ctx context.Context, key, owner string, ttl time.Duration) error
+	Complete(ctx context.Context, key, owner string, ttl time.Duration) error
+	Release(ctx context.Context, key, owner string) error
 }
 
 type EmailComponent interface {
@@ -50,6 +107,11 @@ type GinComponent interface {
 type PubSubComponent interface {
 	Publish(ctx context.Context, topic pubsub.Topic, msg *pubsub.Message) error
 	Subscribe(ctx context.Context, topic pubsub.Topic) (ch <-chan *pubsub.Message, unsubscribeFunc func())
+	QueueSubscribe(ctx context.Context, topic pubsub.Topic, group string) (ch <-chan *pubsub.Message, unsubscribeFunc func())
+}
+
+type RequesterComponent interface {
+	Request(ctx context.Context, topic pubsub.Topic, msg *pubsub.Message) (*pubsub.Message, error)
 }
 
 type TokenMakerComponent interface {
@@ -86,6 +148,33 @@ type GRPCClientComponent interface {
 	DialContext(ctx context.Context, options ...grpc.DialOption) *grpc.ClientConn
 }
 
+type TracingComponent interface {
+	GetTracerProvider() trace.TracerProvider
+	Tracer(name string) trace.Tracer
+}
+
+// MetricsComponent instruments the components whose metrics ID flag is set to its ID
+type MetricsComponent interface {
+	GetRegistry() *prometheus.Registry
+	Handler() http.Handler
+	GinMiddleware() gin.HandlerFunc
+	GormPlugin(dbName string) gorm.Plugin
+	RegisterRedis(name string, client redis.UniversalClient) error
+	WrapEmailSender(es EmailComponent) EmailComponent
+	CacheObserver(name string) CacheObserver
+	PubSubObserver(name string) pubsub.Observer
+	GRPCClientDialOptions() []grpc.DialOption
+	RegisterGin(router gin.IRoutes)
+	RegisterGateway(mux *runtime.ServeMux) error
+}
+
+// CacheObserver is notified about the operations of a cache, ex: to collect metrics
+type CacheObserver interface {
+	OnOperation(operation string, duration time.Duration, err error)
+	// OnRead reports the number of keys found (hits) and not found (misses) by a read
+	OnRead(hits, misses int)
+}
+
 type DBMigrationComponent interface {
 	MigrateUp()
 	MigrateDown()
@@ -2,7 +2,12 @@ package core
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/google/uuid"
 	appctx "github.com/hoangtk0100/app-context"
 	"github.com/hoangtk0100/app-context/component/pubsub"
 	"github.com/hoangtk0100/app-context/util/asyncjob"
@@ -17,24 +22,75 @@ type GroupSubJob interface {
 	Run(ctx context.Context) error
 }
 
+const defaultProgressInterval = time.Second * 10
+
+// SubscriberObserver is notified about the messages skipped by the subscriber engines, ex: to collect metrics
+type SubscriberObserver interface {
+	OnDuplicate(engine string, topic pubsub.Topic)
+}
+
+type subscriberObserver struct {
+	SubscriberObserver
+}
+
+var defaultSubscriberObserver atomic.Value
+
+// SetSubscriberObserver sets the observer of all the subscriber engines
+func SetSubscriberObserver(observer SubscriberObserver) {
+	defaultSubscriberObserver.Store(subscriberObserver{observer})
+}
+
+func getSubscriberObserver() SubscriberObserver {
+	observer, _ := defaultSubscriberObserver.Load().(subscriberObserver)
+	return observer.SubscriberObserver
+}
+
 type subscriberEngine struct {
-	name   string
-	jobs   []topicJobs
-	ps     PubSubComponent
-	ac     appctx.AppContext
-	logger appctx.Logger
+	name       string
+	jobs       []topicJobs
+	policies   map[pubsub.Topic]pubsub.RetryPolicy
+	dedupStore DedupStore
+	dedupTTL   time.Duration
+	// Interval of InProgress and of the deduplication lease renewal while the jobs run
+	progressInterval time.Duration
+	ps               PubSubComponent
+	ac               appctx.AppContext
+	logger           appctx.Logger
 }
 
 func NewSubscriberEngine(name string, ps PubSubComponent, ac appctx.AppContext) *subscriberEngine {
 	return &subscriberEngine{
-		name: name,
-		ps:   ps,
-		ac:   ac,
+		name:             name,
+		policies:         make(map[pubsub.Topic]pubsub.RetryPolicy),
+		progressInterval: defaultProgressInterval,
+		ps:               ps,
+		ac:               ac,
 	}
 }
 
+// SetDeduplication skips the messages already processed by the engine, keyed on message ID for ttl.
+// A message being processed holds its key for 2 progress intervals, renewed while the jobs run,
+// so it is processed again if redelivered after a crash. A redelivery received meanwhile is left unacknowledged.
+// The store must be per instance (ex: local) if each instance receives its own copy of the messages
+func (engine *subscriberEngine) SetDeduplication(store DedupStore, ttl time.Duration) {
+	engine.dedupStore = store
+	engine.dedupTTL = ttl
+}
+
+// SetProgressInterval sets the interval of InProgress while the jobs of a message run, so the backend does not
+// redeliver it meanwhile. Keep it under the ack wait of the backend, 0 disables it - Default: 10s
+func (engine *subscriberEngine) SetProgressInterval(interval time.Duration) {
+	engine.progressInterval = interval
+}
+
+// SetRetryPolicy replaces the default in-process retries of the jobs of the topic, set it before Start
+func (engine *subscriberEngine) SetRetryPolicy(topic pubsub.Topic, policy pubsub.RetryPolicy) {
+	engine.policies[topic] = policy
+}
+
 type topicJobs struct {
 	topic        pubsub.Topic
+	group        string
 	isConcurrent bool
 	jobs         []SubJob
 }
@@ -49,17 +105,35 @@ func (engine *subscriberEngine) AddTopicJobs(topic pubsub.Topic, isConcurrent bo
 	engine.jobs = append(engine.jobs, *topicJobs)
 }
 
+// AddQueueTopicJobs runs the jobs on only one instance of the group per message
+func (engine *subscriberEngine) AddQueueTopicJobs(topic pubsub.Topic, group string, isConcurrent bool, jobs ...SubJob) {
+	topicJobs := &topicJobs{
+		topic:        topic,
+		group:        group,
+		isConcurrent: isConcurrent,
+		jobs:         jobs,
+	}
+
+	engine.jobs = append(engine.jobs, *topicJobs)
+}
+
 func (engine *subscriberEngine) Start() error {
 	engine.logger = engine.ac.Logger(engine.name)
 	for _, jobIndex := range engine.jobs {
-		engine.startSubTopic(jobIndex.topic, jobIndex.isConcurrent, jobIndex.jobs...)
+		engine.startSubTopic(jobIndex.topic, jobIndex.group, jobIndex.isConcurrent, jobIndex.jobs...)
 	}
 
 	return nil
 }
 
-func (engine *subscriberEngine) startSubTopic(topic pubsub.Topic, isConcurrent bool, jobs ...SubJob) error {
-	c, _ := engine.ps.Subscribe(context.Background(), topic)
+func (engine *subscriberEngine) startSubTopic(topic pubsub.Topic, group string, isConcurrent bool, jobs ...SubJob) error {
+	var c <-chan *pubsub.Message
+	if group == "" {
+		c, _ = engine.ps.Subscribe(context.Background(), topic)
+	} else {
+		c, _ = engine.ps.QueueSubscribe(context.Background(), topic, group)
+	}
+
 	for _, item := range jobs {
 		engine.logger.Info("Setup subscriber :", item.Name)
 	}
@@ -71,22 +145,198 @@ func (engine *subscriberEngine) startSubTopic(topic pubsub.Topic, isConcurrent b
 		}
 	}
 
+	policy, hasPolicy := engine.policies[topic]
+
 	go func() {
-		for {
-			msg := <-c
+		// Ends when the backend closes the channel, on unsubscribe or stop (Local, JetStream, Redis Streams)
+		for msg := range c {
+			dedupKey := fmt.Sprintf("%s:%s:%s:%s", engine.name, topic, group, msg.ID())
+			dedupOwner := uuid.NewString()
+			if engine.isDuplicate(dedupKey, dedupOwner, msg) {
+				continue
+			}
+
+			// Retried by the backend if it can redeliver, in-process otherwise
+			brokerRetry := hasPolicy && msg.CanRedeliver()
+
+			var options []asyncjob.OptionHandler
+			if brokerRetry {
+				options = append(options, asyncjob.WithRetryDurations([]time.Duration{}))
+			} else if hasPolicy {
+				options = append(options, asyncjob.WithRetryDurations(policy.Durations()))
+			}
 
 			jobHdls := make([]asyncjob.Job, len(jobs))
 			for index := range jobs {
 				jobHdlIdnex := getJobHandler(&jobs[index], msg)
-				jobHdls[index] = asyncjob.NewJob(jobHdlIdnex, asyncjob.WithName(jobs[index].Name))
+				jobHdls[index] = asyncjob.NewJob(jobHdlIdnex, append(options, asyncjob.WithName(jobs[index].Name))...)
 			}
 
+			ctx, span := pubsub.StartConsumeSpan(context.Background(), msg)
+
+			stopProgress := engine.keepInProgress(msg, dedupKey, dedupOwner)
 			group := asyncjob.NewGroup(isConcurrent, jobHdls...)
-			if err := group.Run(context.Background()); err != nil {
+			err := group.Run(ctx)
+			stopProgress()
+
+			if err == nil {
+				if engine.dedupStore != nil {
+					if compErr := engine.dedupStore.Complete(ctx, dedupKey, dedupOwner, engine.dedupTTL); compErr != nil {
+						engine.logger.Error(compErr, "Cannot complete deduplication key")
+					}
+				}
+
+				if ackErr := msg.Ack(); ackErr != nil {
+					engine.logger.Error(ackErr, "Cannot ack message")
+				}
+			} else {
 				engine.logger.Error(err)
+
+				// Processed again when redelivered or replayed
+				if engine.dedupStore != nil {
+					if relErr := engine.dedupStore.Release(ctx, dedupKey, dedupOwner); relErr != nil {
+						engine.logger.Error(relErr, "Cannot release deduplication key")
+					}
+				}
+
+				if hasPolicy {
+					engine.handleFailure(ctx, msg, policy, brokerRetry, err)
+				} else if nakErr := msg.Nak(); nakErr != nil {
+					// Redelivered by the backends with acknowledgement
+					engine.logger.Error(nakErr, "Cannot nak message")
+				}
 			}
+
+			pubsub.EndSpan(span, err)
 		}
 	}()
 
 	return nil
 }
+
+// processingLease is the TTL of the deduplication key while the jobs run, set to the TTL on success
+func (engine *subscriberEngine) processingLease() time.Duration {
+	if engine.progressInterval <= 0 || engine.progressInterval*2 > engine.dedupTTL {
+		return engine.dedupTTL
+	}
+
+	return engine.progressInterval * 2
+}
+
+// keepInProgress sends InProgress and renews the deduplication lease until stopped
+func (engine *subscriberEngine) keepInProgress(msg *pubsub.Message, dedupKey, dedupOwner string) (stop func()) {
+	if engine.progressInterval <= 0 {
+		return func() {}
+	}
+
+	doneChan := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(engine.progressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-doneChan:
+				return
+			case <-ticker.C:
+				if err := msg.InProgress(); err != nil {
+					engine.logger.Error(err, "Cannot mark message in progress")
+				}
+
+				if engine.dedupStore != nil {
+					if err := engine.dedupStore.Extend(context.Background(), dedupKey, dedupOwner, engine.processingLease()); err != nil {
+						engine.logger.Error(err, "Cannot extend deduplication key")
+					}
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(doneChan)
+		wg.Wait()
+	}
+}
+
+// isDuplicate acks the message if it is already processed, the message is processed if the store fails.
+// A message still processed by another delivery is neither acked nor naked,
+// the backend redelivers it after its ack wait in case that processing fails
+func (engine *subscriberEngine) isDuplicate(key, owner string, msg *pubsub.Message) bool {
+	if engine.dedupStore == nil {
+		return false
+	}
+
+	state, err := engine.dedupStore.Claim(context.Background(), key, owner, engine.processingLease())
+	if err != nil {
+		engine.logger.Error(err, "Cannot claim deduplication key")
+		return false
+	}
+
+	switch state {
+	case DedupClaimed:
+		return false
+	case DedupProcessing:
+		engine.logger.Infof("Skip message %s of topic %s processed by another delivery", msg.ID(), msg.Topic())
+		return true
+	}
+
+	engine.logger.Infof("Skip duplicate message %s of topic %s", msg.ID(), msg.Topic())
+
+	if observer := getSubscriberObserver(); observer != nil {
+		observer.OnDuplicate(engine.name, msg.Topic())
+	}
+
+	if ackErr := msg.Ack(); ackErr != nil {
+		engine.logger.Error(ackErr, "Cannot ack message")
+	}
+
+	return true
+}
+
+// handleFailure redelivers the message until the retries are exhausted, then sends it to the dead letter topic.
+// The last delivery of the backend (ex: JetStream max deliver) also exhausts the retries
+func (engine *subscriberEngine) handleFailure(ctx context.Context, msg *pubsub.Message, policy pubsub.RetryPolicy, brokerRetry bool, err error) {
+	attempts := policy.MaxRetries + 1
+	if brokerRetry {
+		attempts = msg.DeliveryCount()
+		if attempts < 1 {
+			attempts = 1
+		}
+
+		if attempts <= policy.MaxRetries && !msg.IsLastDelivery() {
+			if nakErr := msg.NakWithDelay(policy.BackoffOf(attempts)); nakErr != nil {
+				engine.logger.Error(nakErr, "Cannot nak message")
+			}
+
+			return
+		}
+	}
+
+	if policy.DeadLetterTopic != "" {
+		if pubErr := engine.ps.Publish(ctx, policy.DeadLetterTopic, pubsub.NewDeadLetter(msg, err, attempts)); pubErr != nil {
+			engine.logger.Errorf(pubErr, "Cannot send message %s to dead letter topic %s", msg.ID(), policy.DeadLetterTopic)
+
+			if msg.IsLastDelivery() {
+				engine.logger.Warnf("Message %s dropped after the last delivery of the backend", msg.ID())
+			}
+
+			// Keep the message rather than losing it
+			if nakErr := msg.Nak(); nakErr != nil {
+				engine.logger.Error(nakErr, "Cannot nak message")
+			}
+
+			return
+		}
+	} else {
+		engine.logger.Warnf("Message %s dropped after %d attempts", msg.ID(), attempts)
+	}
+
+	if ackErr := msg.Ack(); ackErr != nil {
+		engine.logger.Error(ackErr, "Cannot ack message")
+	}
+}
@@ -0,0 +1,407 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/hoangtk0100/app-context/component/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+type testAppContext struct {
+	appctx.AppContext
+}
+
+func (testAppContext) Logger(prefix string) appctx.Logger {
+	return appctx.NewAppLogger("test", io.Discard).GetLogger(prefix)
+}
+
+// testPubSub redelivers on nak like the backends with acknowledgement when redeliver is set
+type testPubSub struct {
+	redeliver bool
+	// Redelivered up to maxDeliver deliveries if set, like JetStream
+	maxDeliver int
+	locker     sync.Mutex
+	chans      map[pubsub.Topic]chan *pubsub.Message
+	acks       int32
+	progress   int32
+}
+
+func newTestPubSub(redeliver bool) *testPubSub {
+	return &testPubSub{
+		redeliver: redeliver,
+		chans:     make(map[pubsub.Topic]chan *pubsub.Message),
+	}
+}
+
+func (ps *testPubSub) channel(topic pubsub.Topic) chan *pubsub.Message {
+	ps.locker.Lock()
+	defer ps.locker.Unlock()
+
+	if _, ok := ps.chans[topic]; !ok {
+		ps.chans[topic] = make(chan *pubsub.Message, 10)
+	}
+
+	return ps.chans[topic]
+}
+
+func (ps *testPubSub) Publish(ctx context.Context, topic pubsub.Topic, msg *pubsub.Message) error {
+	msg.SetTopic(topic)
+	ps.deliver(topic, msg, 1)
+
+	return nil
+}
+
+func (ps *testPubSub) deliver(topic pubsub.Topic, msg *pubsub.Message, count int) {
+	msg.SetDeliveryCount(count)
+	msg.SetMaxDeliveryCount(ps.maxDeliver)
+	msg.SetAckFunc(func() error {
+		atomic.AddInt32(&ps.acks, 1)
+		return nil
+	})
+	msg.SetInProgressFunc(func() error {
+		atomic.AddInt32(&ps.progress, 1)
+		return nil
+	})
+
+	if ps.redeliver {
+		msg.SetNakFunc(func(delay time.Duration) error {
+			if ps.maxDeliver > 0 && count >= ps.maxDeliver {
+				return nil
+			}
+
+			time.AfterFunc(delay, func() {
+				ps.deliver(topic, msg, count+1)
+			})
+
+			return nil
+		})
+	}
+
+	ps.channel(topic) <- msg
+}
+
+func (ps *testPubSub) Subscribe(ctx context.Context, topic pubsub.Topic) (<-chan *pubsub.Message, func()) {
+	return ps.channel(topic), func() {}
+}
+
+func (ps *testPubSub) QueueSubscribe(ctx context.Context, topic pubsub.Topic, group string) (<-chan *pubsub.Message, func()) {
+	return ps.channel(topic), func() {}
+}
+
+func TestSubscriberEngineDeadLetter(t *testing.T) {
+	for name, redeliver := range map[string]bool{"in-process": false, "redelivery": true} {
+		t.Run(name, func(t *testing.T) {
+			ps := newTestPubSub(redeliver)
+			ctx := context.Background()
+
+			var calls int32
+			engine := NewSubscriberEngine("engine", ps, testAppContext{})
+			engine.SetRetryPolicy("order.created", pubsub.RetryPolicy{
+				MaxRetries:      2,
+				Backoff:         time.Millisecond * 10,
+				DeadLetterTopic: "order.created.dlq",
+			})
+			engine.AddTopicJobs("order.created", false, SubJob{
+				Name: "fail",
+				Hdl: func(ctx context.Context, msg *pubsub.Message) error {
+					atomic.AddInt32(&calls, 1)
+					return errors.New("boom")
+				},
+			})
+			require.NoError(t, engine.Start())
+
+			msg := pubsub.NewMessage(map[string]interface{}{"id": 1})
+			require.NoError(t, ps.Publish(ctx, "order.created", msg))
+
+			dlq, _ := ps.Subscribe(ctx, "order.created.dlq")
+			select {
+			case deadLetter := <-dlq:
+				require.Equal(t, msg.ID(), deadLetter.Header(pubsub.HeaderOriginalID))
+				require.Equal(t, "order.created", deadLetter.Header(pubsub.HeaderOriginalTopic))
+				require.Equal(t, "boom", deadLetter.Header(pubsub.HeaderFailureError))
+				require.Equal(t, "3", deadLetter.Header(pubsub.HeaderFailureAttempts))
+			case <-time.After(time.Second * 3):
+				t.Fatal("dead letter not received")
+			}
+
+			require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+			// The original message is acknowledged once in the dead letter topic
+			require.Eventually(t, func() bool {
+				return atomic.LoadInt32(&ps.acks) == 1
+			}, time.Second, time.Millisecond*10)
+		})
+	}
+}
+
+func TestSubscriberEngineDeadLetterMaxDeliver(t *testing.T) {
+	ps := newTestPubSub(true)
+	ps.maxDeliver = 2
+	ctx := context.Background()
+
+	var calls int32
+	engine := NewSubscriberEngine("engine", ps, testAppContext{})
+	engine.SetRetryPolicy("order.created", pubsub.RetryPolicy{
+		MaxRetries:      5,
+		Backoff:         time.Millisecond * 10,
+		DeadLetterTopic: "order.created.dlq",
+	})
+	engine.AddTopicJobs("order.created", false, SubJob{
+		Name: "fail",
+		Hdl: func(ctx context.Context, msg *pubsub.Message) error {
+			atomic.AddInt32(&calls, 1)
+			return errors.New("boom")
+		},
+	})
+	require.NoError(t, engine.Start())
+
+	msg := pubsub.NewMessage(map[string]interface{}{"id": 1})
+	require.NoError(t, ps.Publish(ctx, "order.created", msg))
+
+	// Sent on the last delivery of the backend, before the retries of the policy are exhausted
+	dlq, _ := ps.Subscribe(ctx, "order.created.dlq")
+	select {
+	case deadLetter := <-dlq:
+		require.Equal(t, msg.ID(), deadLetter.Header(pubsub.HeaderOriginalID))
+		require.Equal(t, "2", deadLetter.Header(pubsub.HeaderFailureAttempts))
+	case <-time.After(time.Second * 3):
+		t.Fatal("dead letter not received")
+	}
+
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+type testDedupEntry struct {
+	owner string
+	done  bool
+	ttl   time.Duration
+}
+
+// testDedupStore records the owner and ttl of the keys
+type testDedupStore struct {
+	locker sync.Mutex
+	keys   map[string]*testDedupEntry
+}
+
+func newTestDedupStore() *testDedupStore {
+	return &testDedupStore{keys: make(map[string]*testDedupEntry)}
+}
+
+func (s *testDedupStore) Claim(ctx context.Context, key, owner string, ttl time.Duration) (DedupState, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if entry, ok := s.keys[key]; ok {
+		if entry.done {
+			return DedupDone, nil
+		}
+
+		return DedupProcessing, nil
+	}
+
+	s.keys[key] = &testDedupEntry{owner: owner, ttl: ttl}
+
+	return DedupClaimed, nil
+}
+
+func (s *testDedupStore) Extend(ctx context.Context, key, owner string, ttl time.Duration) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if entry, ok := s.keys[key]; ok && entry.owner == owner && !entry.done {
+		entry.ttl = ttl
+	}
+
+	return nil
+}
+
+func (s *testDedupStore) Complete(ctx context.Context, key, owner string, ttl time.Duration) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if entry, ok := s.keys[key]; ok && entry.owner == owner && !entry.done {
+		entry.done = true
+		entry.ttl = ttl
+	}
+
+	return nil
+}
+
+func (s *testDedupStore) Release(ctx context.Context, key, owner string) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if entry, ok := s.keys[key]; ok && entry.owner == owner && !entry.done {
+		delete(s.keys, key)
+	}
+
+	return nil
+}
+
+func (s *testDedupStore) ttl(key string) time.Duration {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if entry, ok := s.keys[key]; ok {
+		return entry.ttl
+	}
+
+	return 0
+}
+
+func TestSubscriberEngineDeduplication(t *testing.T) {
+	ps := newTestPubSub(false)
+	ctx := context.Background()
+
+	observer := new(testSubscriberObserver)
+	SetSubscriberObserver(observer)
+	defer SetSubscriberObserver(nil)
+
+	var calls int32
+	engine := NewSubscriberEngine("engine", ps, testAppContext{})
+	engine.SetDeduplication(newTestDedupStore(), time.Minute)
+	engine.AddTopicJobs("order.created", false, SubJob{
+		Name: "count",
+		Hdl: func(ctx context.Context, msg *pubsub.Message) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		},
+	})
+	require.NoError(t, engine.Start())
+
+	// Delivered twice, ex: redelivered after a lost ack
+	msg := pubsub.NewMessage(map[string]interface{}{"id": 1})
+	data, err := pubsub.MarshalMessage(msg)
+	require.NoError(t, err)
+
+	require.NoError(t, ps.Publish(ctx, "order.created", msg))
+	require.NoError(t, ps.Publish(ctx, "order.created", pubsub.UnmarshalMessage(data)))
+	require.NoError(t, ps.Publish(ctx, "order.created", pubsub.NewMessage(map[string]interface{}{"id": 2})))
+
+	// The duplicate is acked without running the job
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&ps.acks) == 3
+	}, time.Second, time.Millisecond*10)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	require.Equal(t, 1, observer.count())
+}
+
+func TestSubscriberEngineDeduplicationProcessing(t *testing.T) {
+	ctx := context.Background()
+	store := newTestDedupStore()
+
+	observer := new(testSubscriberObserver)
+	SetSubscriberObserver(observer)
+	defer SetSubscriberObserver(nil)
+
+	// Two instances sharing the store
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls int32
+	newEngine := func(ps *testPubSub, hdl func() error) {
+		engine := NewSubscriberEngine("engine", ps, testAppContext{})
+		engine.SetDeduplication(store, time.Minute)
+		// Naked on failure, redelivered later
+		engine.SetRetryPolicy("order.created", pubsub.RetryPolicy{MaxRetries: 1, Backoff: time.Hour})
+		engine.AddTopicJobs("order.created", false, SubJob{
+			Name: "count",
+			Hdl: func(ctx context.Context, msg *pubsub.Message) error {
+				atomic.AddInt32(&calls, 1)
+				return hdl()
+			},
+		})
+		require.NoError(t, engine.Start())
+	}
+
+	first := newTestPubSub(true)
+	newEngine(first, func() error {
+		close(started)
+		<-release
+		return errors.New("failed")
+	})
+
+	second := newTestPubSub(false)
+	newEngine(second, func() error {
+		return nil
+	})
+
+	msg := pubsub.NewMessage(map[string]interface{}{"id": 1})
+	data, err := pubsub.MarshalMessage(msg)
+	require.NoError(t, err)
+
+	require.NoError(t, first.Publish(ctx, "order.created", msg))
+	<-started
+
+	// Redelivered while the first delivery is running: neither acked nor processed
+	require.NoError(t, second.Publish(ctx, "order.created", pubsub.UnmarshalMessage(data)))
+	time.Sleep(time.Millisecond * 50)
+	require.Zero(t, atomic.LoadInt32(&second.acks))
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// The first delivery fails, the next redelivery is processed
+	close(release)
+	require.Eventually(t, func() bool {
+		return store.ttl(fmt.Sprintf("engine:order.created::%s", msg.ID())) == 0
+	}, time.Second, time.Millisecond*10)
+
+	require.NoError(t, second.Publish(ctx, "order.created", pubsub.UnmarshalMessage(data)))
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&second.acks) == 1
+	}, time.Second, time.Millisecond*10)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	require.Zero(t, observer.count())
+}
+
+type testSubscriberObserver struct {
+	duplicates int32
+}
+
+func (o *testSubscriberObserver) OnDuplicate(engine string, topic pubsub.Topic) {
+	atomic.AddInt32(&o.duplicates, 1)
+}
+
+func (o *testSubscriberObserver) count() int {
+	return int(atomic.LoadInt32(&o.duplicates))
+}
+
+func TestSubscriberEngineProcessingLease(t *testing.T) {
+	ps := newTestPubSub(false)
+	ctx := context.Background()
+	store := newTestDedupStore()
+
+	msg := pubsub.NewMessage(map[string]interface{}{"id": 1})
+	key := fmt.Sprintf("engine:order.created::%s", msg.ID())
+
+	var leases []time.Duration
+	engine := NewSubscriberEngine("engine", ps, testAppContext{})
+	engine.SetDeduplication(store, time.Minute)
+	engine.SetProgressInterval(time.Millisecond * 20)
+	engine.AddTopicJobs("order.created", false, SubJob{
+		Name: "slow",
+		Hdl: func(ctx context.Context, msg *pubsub.Message) error {
+			leases = append(leases, store.ttl(key))
+			time.Sleep(time.Millisecond * 100)
+			leases = append(leases, store.ttl(key))
+			return nil
+		},
+	})
+	require.NoError(t, engine.Start())
+
+	require.NoError(t, ps.Publish(ctx, "order.created", msg))
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&ps.acks) == 1
+	}, time.Second, time.Millisecond*10)
+
+	// Held for the processing lease while running, kept for the TTL once processed
+	require.Equal(t, []time.Duration{time.Millisecond * 40, time.Millisecond * 40}, leases)
+	require.Equal(t, time.Minute, store.ttl(key))
+	require.GreaterOrEqual(t, atomic.LoadInt32(&ps.progress), int32(3))
+}
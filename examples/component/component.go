@@ -20,8 +20,8 @@ func (c *demoComponent) ID() string {
 	return c.id
 }
 
-func (c *demoComponent) InitFlags() {
-	pflag.StringVar(&c.data, "component-data", "demo", "Data string")
+func (c *demoComponent) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.data, "component-data", "demo", "Data string")
 }
 
 func (c *demoComponent) Run(ac appctx.AppContext) error {
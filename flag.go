@@ -14,12 +14,14 @@ import (
 type appFlagSet struct {
 	prefix  string
 	flagSet *pflag.FlagSet
+	viper   *viper.Viper
 }
 
-func newAppFlagSet(prefix string, name string, fs *pflag.FlagSet) *appFlagSet {
+func newAppFlagSet(prefix string, name string, fs *pflag.FlagSet, v *viper.Viper) *appFlagSet {
 	afs := &appFlagSet{
 		prefix:  prefix,
 		flagSet: fs,
+		viper:   v,
 	}
 
 	afs.flagSet.Usage = flagUsages(name, afs)
@@ -70,7 +72,7 @@ func (afs *appFlagSet) ParseSet() error {
 		all = append(all, f)
 		if !contains(explicit, f) {
 			name := getEnvName(afs.prefix, f.Name)
-			val := viper.GetString(name)
+			val := afs.viper.GetString(name)
 			if !f.Changed && val != "" {
 				if ferr := afs.flagSet.Set(f.Name, val); ferr != nil {
 					err = fmt.Errorf("failed to set flag %q with value %q", f.Name, val)
@@ -0,0 +1,59 @@
+package appctx
+
+import (
+	"io"
+	"sync"
+)
+
+// sinkWriter fans out log entries to the primary output and the installed sinks
+type sinkWriter struct {
+	locker  *sync.RWMutex
+	primary io.Writer
+	sinks   map[int]io.Writer
+	nextID  int
+}
+
+func newSinkWriter() *sinkWriter {
+	return &sinkWriter{
+		locker: new(sync.RWMutex),
+		sinks:  make(map[int]io.Writer),
+	}
+}
+
+func (w *sinkWriter) setPrimary(primary io.Writer) {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+
+	w.primary = primary
+}
+
+func (w *sinkWriter) add(sink io.Writer) func() {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+
+	id := w.nextID
+	w.nextID++
+	w.sinks[id] = sink
+
+	return func() {
+		w.locker.Lock()
+		defer w.locker.Unlock()
+
+		delete(w.sinks, id)
+	}
+}
+
+func (w *sinkWriter) Write(p []byte) (int, error) {
+	w.locker.RLock()
+	defer w.locker.RUnlock()
+
+	for _, sink := range w.sinks {
+		_, _ = sink.Write(p)
+	}
+
+	if w.primary == nil {
+		return len(p), nil
+	}
+
+	return w.primary.Write(p)
+}
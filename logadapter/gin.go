@@ -0,0 +1,59 @@
+package logadapter
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	appctx "github.com/hoangtk0100/app-context"
+)
+
+// SetGinWriters routes gin debug and error output through the logger,
+// it must be called before creating the gin engine
+func SetGinWriters(logger appctx.Logger) {
+	gin.DefaultWriter = NewWriter(logger, "debug")
+	gin.DefaultErrorWriter = NewWriter(logger, "error")
+}
+
+// GinLogger replaces gin.Logger(), requests are logged at debug level,
+// client errors at warn level and server errors at error level
+func GinLogger(logger appctx.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		startTime := time.Now()
+		path := ctx.Request.URL.Path
+		if ctx.Request.URL.RawQuery != "" {
+			path += "?" + ctx.Request.URL.RawQuery
+		}
+
+		ctx.Next()
+
+		statusCode := ctx.Writer.Status()
+		fields := map[string]interface{}{
+			"protocol":    "http",
+			"method":      ctx.Request.Method,
+			"path":        path,
+			"status_code": statusCode,
+			"duration":    time.Since(startTime).String(),
+			"client_ip":   ctx.ClientIP(),
+			"user_agent":  ctx.Request.UserAgent(),
+		}
+
+		if errs := ctx.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			fields["errors"] = errs
+		}
+
+		var err error
+		if last := ctx.Errors.Last(); last != nil {
+			err = last
+		}
+
+		lg := logger.WithFields(fields)
+		switch {
+		case statusCode >= 500:
+			lg.Error(err, "received a HTTP request")
+		case statusCode >= 400:
+			lg.Warn("received a HTTP request")
+		default:
+			lg.Debug("received a HTTP request")
+		}
+	}
+}
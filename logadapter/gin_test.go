@@ -0,0 +1,46 @@
+package logadapter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hoangtk0100/app-context/logtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGinLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	al, sink := logtest.New(t)
+	router := gin.New()
+	router.Use(GinLogger(al.GetLogger("gin")))
+	router.GET("/ok", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	router.GET("/missing", func(ctx *gin.Context) {
+		ctx.Status(http.StatusNotFound)
+	})
+	router.GET("/fail", func(ctx *gin.Context) {
+		_ = ctx.Error(errors.New("boom"))
+		ctx.Status(http.StatusInternalServerError)
+	})
+
+	for _, path := range []string{"/ok?page=1", "/missing", "/fail"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	entries := sink.Entries("debug", "gin")
+	require.Len(t, entries, 1)
+	require.Equal(t, "/ok?page=1", entries[0].Fields["path"])
+	require.Equal(t, float64(http.StatusOK), entries[0].Fields["status_code"])
+
+	require.Len(t, sink.Entries("warn", "gin"), 1)
+
+	entries = sink.Entries("error", "gin")
+	require.Len(t, entries, 1)
+	require.Equal(t, "boom", entries[0].Fields["error"])
+	require.Contains(t, entries[0].Fields["errors"], "boom")
+}
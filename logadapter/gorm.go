@@ -0,0 +1,79 @@
+package logadapter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	appctx "github.com/hoangtk0100/app-context"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const defaultSlowThreshold = 200 * time.Millisecond
+
+type gormLogger struct {
+	logger        appctx.Logger
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration
+}
+
+// NewGormLogger returns a gorm logger, SQL traces are logged at debug level
+// and slow queries (>= 200ms) at warn level
+func NewGormLogger(logger appctx.Logger) gormlogger.Interface {
+	return &gormLogger{
+		logger:        logger,
+		level:         gormlogger.Warn,
+		slowThreshold: defaultSlowThreshold,
+	}
+}
+
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	newLogger := *l
+	newLogger.level = level
+	return &newLogger
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.logger.Infof(msg, data...)
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.logger.Warnf(msg, data...)
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.logger.Errorf(nil, msg, data...)
+	}
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+
+	fields := func() map[string]interface{} {
+		sql, rows := fc()
+		return map[string]interface{}{
+			"sql":      sql,
+			"rows":     rows,
+			"duration": elapsed.String(),
+		}
+	}
+
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		l.logger.WithFields(fields()).Error(err, "SQL error")
+	case l.slowThreshold != 0 && elapsed > l.slowThreshold && l.level >= gormlogger.Warn:
+		l.logger.WithFields(fields()).Warnf("Slow SQL >= %v", l.slowThreshold)
+	case l.level >= gormlogger.Info:
+		l.logger.WithFields(fields()).Debug("SQL")
+	}
+}
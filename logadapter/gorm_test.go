@@ -0,0 +1,54 @@
+package logadapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hoangtk0100/app-context/logtest"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestGormLogger(t *testing.T) {
+	al, sink := logtest.New(t)
+	ctx := context.Background()
+	query := func() (string, int64) {
+		return "SELECT 1", 1
+	}
+
+	logger := NewGormLogger(al.GetLogger("gorm"))
+
+	// Warn level by default
+	logger.Trace(ctx, time.Now(), query, nil)
+	logger.Info(ctx, "info %d", 1)
+	require.Empty(t, sink.All())
+
+	logger.Trace(ctx, time.Now().Add(-time.Second), query, nil)
+	entries := sink.Entries("warn", "gorm")
+	require.Len(t, entries, 1)
+	require.Equal(t, "SELECT 1", entries[0].Fields["sql"])
+
+	logger.Trace(ctx, time.Now(), query, errors.New("boom"))
+	entries = sink.Entries("error", "gorm")
+	require.Len(t, entries, 1)
+	require.Equal(t, "SQL error", entries[0].Message)
+	require.Equal(t, "boom", entries[0].Fields["error"])
+
+	// Record not found is not an error
+	sink.Reset()
+	logger.Trace(ctx, time.Now(), query, gorm.ErrRecordNotFound)
+	require.Empty(t, sink.All())
+
+	logger = logger.LogMode(gormlogger.Info)
+	logger.Trace(ctx, time.Now(), query, nil)
+	logger.Info(ctx, "info %d", 1)
+	require.Len(t, sink.Entries("debug", "gorm"), 1)
+	require.Equal(t, "info 1", sink.Entries("info", "gorm")[0].Message)
+
+	sink.Reset()
+	logger.LogMode(gormlogger.Silent).Trace(ctx, time.Now(), query, errors.New("boom"))
+	require.Empty(t, sink.All())
+}
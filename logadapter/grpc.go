@@ -0,0 +1,99 @@
+package logadapter
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	appctx "github.com/hoangtk0100/app-context"
+	"google.golang.org/grpc/grpclog"
+)
+
+var (
+	// Installed once as grpclog.SetLoggerV2 is not goroutine-safe, SetGrpcLogger swaps its logger
+	globalGrpcLogger  = new(grpcLogger)
+	grpcLoggerInstall sync.Once
+)
+
+// grpcLogger maps the (verbose) grpc INFO level to debug
+type grpcLogger struct {
+	logger    atomic.Value
+	verbosity int
+}
+
+func NewGrpcLogger(logger appctx.Logger, verbosity int) grpclog.LoggerV2 {
+	l := &grpcLogger{verbosity: verbosity}
+	l.logger.Store(loggerValue{logger})
+
+	return l
+}
+
+// SetGrpcLogger routes the grpclog logs to logger, the last call wins. Safe for concurrent use
+func SetGrpcLogger(logger appctx.Logger) {
+	globalGrpcLogger.logger.Store(loggerValue{logger})
+
+	grpcLoggerInstall.Do(func() {
+		grpclog.SetLoggerV2(globalGrpcLogger)
+	})
+}
+
+func (l *grpcLogger) get() appctx.Logger {
+	return l.logger.Load().(loggerValue).Logger
+}
+
+func (l *grpcLogger) Info(args ...interface{}) {
+	l.get().Debug(args...)
+}
+
+func (l *grpcLogger) Infoln(args ...interface{}) {
+	l.get().Debug(sprintln(args...))
+}
+
+func (l *grpcLogger) Infof(format string, args ...interface{}) {
+	l.get().Debugf(format, args...)
+}
+
+func (l *grpcLogger) Warning(args ...interface{}) {
+	l.get().Warn(args...)
+}
+
+func (l *grpcLogger) Warningln(args ...interface{}) {
+	l.get().Warn(sprintln(args...))
+}
+
+func (l *grpcLogger) Warningf(format string, args ...interface{}) {
+	l.get().Warnf(format, args...)
+}
+
+func (l *grpcLogger) Error(args ...interface{}) {
+	l.get().Error(nil, args...)
+}
+
+func (l *grpcLogger) Errorln(args ...interface{}) {
+	l.get().Error(nil, sprintln(args...))
+}
+
+func (l *grpcLogger) Errorf(format string, args ...interface{}) {
+	l.get().Errorf(nil, format, args...)
+}
+
+func (l *grpcLogger) Fatal(args ...interface{}) {
+	l.get().Fatal(nil, args...)
+}
+
+func (l *grpcLogger) Fatalln(args ...interface{}) {
+	l.get().Fatal(nil, sprintln(args...))
+}
+
+func (l *grpcLogger) Fatalf(format string, args ...interface{}) {
+	l.get().Fatalf(nil, format, args...)
+}
+
+func (l *grpcLogger) V(level int) bool {
+	return level <= l.verbosity
+}
+
+func sprintln(args ...interface{}) string {
+	msg := fmt.Sprintln(args...)
+	return msg[:len(msg)-1]
+}
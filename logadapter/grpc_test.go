@@ -0,0 +1,43 @@
+package logadapter
+
+import (
+	"testing"
+
+	"github.com/hoangtk0100/app-context/logtest"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/grpclog"
+)
+
+func TestGrpcLogger(t *testing.T) {
+	al, sink := logtest.New(t)
+	logger := NewGrpcLogger(al.GetLogger("grpc"), 1)
+
+	logger.Info("connecting")
+	logger.Infoln("picked", "subconn")
+	logger.Warningf("retry %d", 2)
+	logger.Errorln("transport", "closed")
+
+	entries := sink.Entries("debug", "grpc")
+	require.Len(t, entries, 2)
+	require.Equal(t, "picked subconn", entries[1].Message)
+
+	require.Equal(t, "retry 2", sink.Entries("warn", "grpc")[0].Message)
+	require.Equal(t, "transport closed", sink.Entries("error", "grpc")[0].Message)
+
+	require.True(t, logger.V(1))
+	require.False(t, logger.V(2))
+}
+
+func TestSetGrpcLogger(t *testing.T) {
+	first, firstSink := logtest.New(t)
+	second, secondSink := logtest.New(t)
+
+	// A second app context re-points the global logger
+	SetGrpcLogger(first.GetLogger("grpc"))
+	grpclog.Warning("first")
+	SetGrpcLogger(second.GetLogger("grpc"))
+	grpclog.Warning("second")
+
+	require.Len(t, firstSink.Entries("warn", "grpc"), 1)
+	require.Equal(t, "second", secondSink.Entries("warn", "grpc")[0].Message)
+}
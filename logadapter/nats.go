@@ -0,0 +1,45 @@
+package logadapter
+
+import (
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/nats-io/nats.go"
+)
+
+// NatsOptions returns the connection event handlers logging through the logger
+func NatsOptions(logger appctx.Logger) []nats.Option {
+	return []nats.Option{
+		nats.ErrorHandler(func(nc *nats.Conn, sub *nats.Subscription, err error) {
+			if sub != nil {
+				logger.WithFields(map[string]interface{}{"subject": sub.Subject}).Error(err, "NATS async error")
+				return
+			}
+
+			logger.Error(err, "NATS async error")
+		}),
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			if err != nil {
+				logger.Warn("NATS disconnected due to: ", err)
+				return
+			}
+
+			logger.Info("NATS disconnected")
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			logger.Infof("NATS reconnected [%s]", nc.ConnectedUrl())
+		}),
+		nats.ClosedHandler(func(nc *nats.Conn) {
+			if err := nc.LastError(); err != nil {
+				logger.Error(err, "NATS connection closed")
+				return
+			}
+
+			logger.Info("NATS connection closed")
+		}),
+		nats.DiscoveredServersHandler(func(nc *nats.Conn) {
+			logger.Debugf("NATS discovered servers %v", nc.DiscoveredServers())
+		}),
+		nats.LameDuckModeHandler(func(nc *nats.Conn) {
+			logger.Warnf("NATS server [%s] entered lame duck mode", nc.ConnectedUrl())
+		}),
+	}
+}
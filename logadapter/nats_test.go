@@ -0,0 +1,37 @@
+package logadapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hoangtk0100/app-context/logtest"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNatsOptions(t *testing.T) {
+	ns, err := server.NewServer(&server.Options{
+		Host:   "127.0.0.1",
+		Port:   server.RANDOM_PORT,
+		NoLog:  true,
+		NoSigs: true,
+	})
+	require.NoError(t, err)
+
+	go ns.Start()
+	require.True(t, ns.ReadyForConnections(time.Second*5))
+	defer ns.Shutdown()
+
+	al, sink := logtest.New(t)
+	conn, err := nats.Connect(ns.ClientURL(), NatsOptions(al.GetLogger("nats"))...)
+	require.NoError(t, err)
+
+	conn.Close()
+
+	require.Eventually(t, func() bool {
+		return sink.Logged("NATS connection closed")
+	}, time.Second, time.Millisecond*10)
+	require.Len(t, sink.Entries("info", "nats"), 2)
+	sink.AssertLogged(t, "NATS disconnected")
+}
@@ -0,0 +1,41 @@
+package logadapter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// Installed once as redis.SetLogger is not goroutine-safe, SetRedisLogger swaps its logger
+	globalRedisLogger  = new(redisLogger)
+	redisLoggerInstall sync.Once
+)
+
+// go-redis only logs connection and pool issues
+type redisLogger struct {
+	logger atomic.Value
+}
+
+func NewRedisLogger(logger appctx.Logger) *redisLogger {
+	l := new(redisLogger)
+	l.logger.Store(loggerValue{logger})
+
+	return l
+}
+
+// SetRedisLogger routes the go-redis global logs to logger, the last call wins. Safe for concurrent use
+func SetRedisLogger(logger appctx.Logger) {
+	globalRedisLogger.logger.Store(loggerValue{logger})
+
+	redisLoggerInstall.Do(func() {
+		redis.SetLogger(globalRedisLogger)
+	})
+}
+
+func (l *redisLogger) Printf(ctx context.Context, format string, v ...interface{}) {
+	l.logger.Load().(loggerValue).Warnf(format, v...)
+}
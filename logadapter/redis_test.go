@@ -0,0 +1,32 @@
+package logadapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hoangtk0100/app-context/logtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisLogger(t *testing.T) {
+	al, sink := logtest.New(t)
+
+	NewRedisLogger(al.GetLogger("redis")).Printf(context.Background(), "redis: pool %s", "exhausted")
+
+	entries := sink.Entries("warn", "redis")
+	require.Len(t, entries, 1)
+	require.Equal(t, "redis: pool exhausted", entries[0].Message)
+}
+
+func TestSetRedisLogger(t *testing.T) {
+	first, firstSink := logtest.New(t)
+	second, secondSink := logtest.New(t)
+
+	SetRedisLogger(first.GetLogger("redis"))
+	globalRedisLogger.Printf(context.Background(), "first")
+	SetRedisLogger(second.GetLogger("redis"))
+	globalRedisLogger.Printf(context.Background(), "second")
+
+	require.Len(t, firstSink.Entries("warn", "redis"), 1)
+	require.Equal(t, "second", secondSink.Entries("warn", "redis")[0].Message)
+}
@@ -0,0 +1,52 @@
+// Package logadapter routes logging of third-party libraries through appctx.Logger
+package logadapter
+
+import (
+	"bytes"
+	"io"
+
+	appctx "github.com/hoangtk0100/app-context"
+)
+
+// loggerValue keeps the same concrete type in the atomic values of the global loggers
+type loggerValue struct {
+	appctx.Logger
+}
+
+type writer struct {
+	logger appctx.Logger
+	level  string
+}
+
+// NewWriter returns an io.Writer logging every written line at given level
+// (trace | debug | info | warn | error)
+func NewWriter(logger appctx.Logger, level string) io.Writer {
+	return &writer{
+		logger: logger,
+		level:  level,
+	}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimSpace(p), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		msg := string(bytes.TrimSpace(line))
+		switch w.level {
+		case "trace":
+			w.logger.Trace(msg)
+		case "debug":
+			w.logger.Debug(msg)
+		case "warn":
+			w.logger.Warn(msg)
+		case "error":
+			w.logger.Error(nil, msg)
+		default:
+			w.logger.Info(msg)
+		}
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,35 @@
+package logadapter
+
+import (
+	"testing"
+
+	"github.com/hoangtk0100/app-context/logtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	al, sink := logtest.New(t)
+	logger := al.GetLogger("writer")
+
+	input := []byte("first line\n\n  second line  \n")
+	n, err := NewWriter(logger, "warn").Write(input)
+	require.NoError(t, err)
+	require.Equal(t, len(input), n)
+
+	entries := sink.Entries("warn", "writer")
+	require.Len(t, entries, 2)
+	require.Equal(t, "first line", entries[0].Message)
+	require.Equal(t, "second line", entries[1].Message)
+
+	for level, expected := range map[string]string{
+		"trace": "trace",
+		"debug": "debug",
+		"error": "error",
+		"":      "info",
+	} {
+		sink.Reset()
+		_, err = NewWriter(logger, level).Write([]byte("line"))
+		require.NoError(t, err)
+		require.Len(t, sink.Entries(expected, "writer"), 1)
+	}
+}
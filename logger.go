@@ -8,10 +8,17 @@ import (
 
 type Logger interface {
 	GetLevel() string
+	// Enabled reports whether a message at level (trace | debug | info | warn | error | fatal | panic)
+	// would be logged, to skip building expensive messages
+	Enabled(level string) bool
+	WithFields(fields map[string]interface{}) Logger
 
 	Print(args ...interface{})
 	Printf(format string, args ...interface{})
 
+	Trace(args ...interface{})
+	Tracef(format string, args ...interface{})
+
 	Debug(args ...interface{})
 	Debugf(format string, args ...interface{})
 
@@ -21,29 +28,65 @@ type Logger interface {
 	Warn(args ...interface{})
 	Warnf(format string, args ...interface{})
 
+	// Error logs err as the "error" field, a nil err is omitted.
+	// The message defaults to the error message if args are empty.
 	Error(err error, args ...interface{})
 	Errorf(err error, format string, args ...interface{})
 
 	Fatal(err error, args ...interface{})
 	Fatalf(err error, format string, args ...interface{})
+
+	Panic(err error, args ...interface{})
+	Panicf(err error, format string, args ...interface{})
 }
 
 type logger struct {
 	*zerolog.Logger
 }
 
+func (lg *logger) level() zerolog.Level {
+	level := zerolog.GlobalLevel()
+	if lg.Logger.GetLevel() > level {
+		level = lg.Logger.GetLevel()
+	}
+
+	return level
+}
+
 func (lg *logger) GetLevel() string {
-	return zerolog.GlobalLevel().String()
+	return lg.level().String()
+}
+
+func (lg *logger) Enabled(level string) bool {
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil || lvl == zerolog.NoLevel {
+		return false
+	}
+
+	return lvl >= lg.level()
+}
+
+func (lg *logger) WithFields(fields map[string]interface{}) Logger {
+	lgr := lg.Logger.With().Fields(fields).Logger()
+	return &logger{Logger: &lgr}
 }
 
 func (lg *logger) Print(args ...interface{}) {
-	lg.Debug(fmt.Sprint(args...))
+	lg.Debug(args...)
 }
 
 func (lg *logger) Printf(format string, args ...interface{}) {
 	lg.Debugf(format, args...)
 }
 
+func (lg *logger) Trace(args ...interface{}) {
+	lg.Logger.Trace().Msg(fmt.Sprint(args...))
+}
+
+func (lg *logger) Tracef(format string, args ...interface{}) {
+	lg.Logger.Trace().Msgf(format, args...)
+}
+
 func (lg *logger) Debug(args ...interface{}) {
 	lg.Logger.Debug().Msg(fmt.Sprint(args...))
 }
@@ -65,21 +108,45 @@ func (lg *logger) Warn(args ...interface{}) {
 }
 
 func (lg *logger) Warnf(format string, args ...interface{}) {
-	lg.Logger.Warn().Msgf(format, fmt.Sprint(args...))
+	lg.Logger.Warn().Msgf(format, args...)
 }
 
 func (lg *logger) Error(err error, args ...interface{}) {
-	lg.Logger.Error().Err(err).Msg(fmt.Sprint(args...))
+	withError(lg.Logger.Error(), err).Msg(errorMessage(err, args))
 }
 
 func (lg *logger) Errorf(err error, format string, args ...interface{}) {
-	lg.Logger.Error().Err(err).Msgf(format, fmt.Sprint(args...))
+	withError(lg.Logger.Error(), err).Msgf(format, args...)
 }
 
 func (lg *logger) Fatal(err error, args ...interface{}) {
-	lg.Logger.Fatal().Err(err).Msg(fmt.Sprint(args...))
+	withError(lg.Logger.Fatal(), err).Msg(errorMessage(err, args))
 }
 
 func (lg *logger) Fatalf(err error, format string, args ...interface{}) {
-	lg.Logger.Fatal().Err(err).Msgf(format, fmt.Sprint(args...))
+	withError(lg.Logger.Fatal(), err).Msgf(format, args...)
+}
+
+func (lg *logger) Panic(err error, args ...interface{}) {
+	withError(lg.Logger.Panic(), err).Msg(errorMessage(err, args))
+}
+
+func (lg *logger) Panicf(err error, format string, args ...interface{}) {
+	withError(lg.Logger.Panic(), err).Msgf(format, args...)
+}
+
+func withError(event *zerolog.Event, err error) *zerolog.Event {
+	if err == nil {
+		return event
+	}
+
+	return event.Err(err)
+}
+
+func errorMessage(err error, args []interface{}) string {
+	if len(args) == 0 && err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprint(args...)
 }
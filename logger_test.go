@@ -0,0 +1,69 @@
+package appctx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readEntries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		entry := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+
+	buf.Reset()
+	return entries
+}
+
+func TestLoggerFormatting(t *testing.T) {
+	buf := new(bytes.Buffer)
+	lg := NewAppLogger("core", buf).GetLogger("test")
+
+	lg.Warnf("%s has %d items", "cart", 3)
+	lg.Errorf(errors.New("boom"), "%s failed after %d retries", "job", 2)
+	lg.Error(nil, "no error")
+	lg.Error(errors.New("only error"))
+	lg.Tracef("trace %d", 1)
+
+	entries := readEntries(t, buf)
+	require.Len(t, entries, 5)
+
+	require.Equal(t, "warn", entries[0]["level"])
+	require.Equal(t, "cart has 3 items", entries[0]["message"])
+
+	require.Equal(t, "job failed after 2 retries", entries[1]["message"])
+	require.Equal(t, "boom", entries[1]["error"])
+
+	require.Equal(t, "no error", entries[2]["message"])
+	require.NotContains(t, entries[2], "error")
+
+	require.Equal(t, "only error", entries[3]["message"])
+	require.Equal(t, "only error", entries[3]["error"])
+
+	require.Equal(t, "trace", entries[4]["level"])
+	require.Equal(t, "core.test", entries[4]["prefix"])
+
+	require.Panics(t, func() {
+		lg.Panicf(errors.New("boom"), "panic %d", 1)
+	})
+	require.Equal(t, "panic", readEntries(t, buf)[0]["level"])
+}
+
+func TestLoggerEnabled(t *testing.T) {
+	al := NewAppLogger("core", nil).(*appLogger)
+	al.logger = al.newLogger(nil, nil, parseLogLevel("warn"))
+	lg := al.GetLogger("test")
+
+	require.Equal(t, "warn", lg.GetLevel())
+	require.False(t, lg.Enabled("debug"))
+	require.True(t, lg.Enabled("warn"))
+	require.True(t, lg.Enabled("error"))
+	require.False(t, lg.Enabled("unknown"))
+}
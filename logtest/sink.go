@@ -0,0 +1,174 @@
+// Package logtest provides an in-memory log sink to assert on log output in tests.
+package logtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	appctx "github.com/hoangtk0100/app-context"
+)
+
+const (
+	levelFieldName   = "level"
+	prefixFieldName  = "prefix"
+	messageFieldName = "message"
+)
+
+type Entry struct {
+	Level   string
+	Prefix  string
+	Message string
+	Fields  map[string]interface{}
+}
+
+func (e Entry) String() string {
+	return fmt.Sprintf("[%s] %s: %s %v", e.Level, e.Prefix, e.Message, e.Fields)
+}
+
+func (e Entry) contains(substr string) bool {
+	if strings.Contains(e.Message, substr) {
+		return true
+	}
+
+	for _, value := range e.Fields {
+		if strings.Contains(fmt.Sprint(value), substr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sink records log entries written by an AppLogger
+type Sink struct {
+	entries []Entry
+	locker  *sync.RWMutex
+}
+
+func NewSink() *Sink {
+	return &Sink{
+		locker: new(sync.RWMutex),
+	}
+}
+
+// New creates an isolated AppLogger with a Sink installed, safe for parallel tests.
+// Use appctx.WithAppLogger to plug it into an AppContext.
+func New(t testing.TB) (appctx.AppLogger, *Sink) {
+	al := appctx.NewAppLogger("core", nil)
+	sink := NewSink()
+	t.Cleanup(sink.Install(al))
+
+	return al, sink
+}
+
+// Install adds the sink to an AppLogger, the returned func uninstalls it.
+// It panics if the AppLogger does not accept sinks (appctx.SinkAppLogger)
+func (s *Sink) Install(al appctx.AppLogger) (uninstall func()) {
+	sal, ok := al.(appctx.SinkAppLogger)
+	if !ok {
+		panic(fmt.Sprintf("logtest: %T does not accept sinks", al))
+	}
+
+	return sal.AddSink(s)
+}
+
+func (s *Sink) Write(p []byte) (int, error) {
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(p, &fields); err != nil {
+		return 0, err
+	}
+
+	entry := Entry{Fields: fields}
+	entry.Level, _ = fields[levelFieldName].(string)
+	entry.Prefix, _ = fields[prefixFieldName].(string)
+	entry.Message, _ = fields[messageFieldName].(string)
+
+	delete(fields, levelFieldName)
+	delete(fields, prefixFieldName)
+	delete(fields, messageFieldName)
+
+	s.locker.Lock()
+	s.entries = append(s.entries, entry)
+	s.locker.Unlock()
+
+	return len(p), nil
+}
+
+// Entries returns the recorded entries matching level and prefix, empty values match all.
+// The prefix matches with or without the base prefix of the logger (ex: "service" or "core.service").
+func (s *Sink) Entries(level, prefix string) []Entry {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+
+	var result []Entry
+	for _, entry := range s.entries {
+		if level != "" && entry.Level != level {
+			continue
+		}
+
+		if prefix != "" && entry.Prefix != prefix && !strings.HasSuffix(entry.Prefix, "."+prefix) {
+			continue
+		}
+
+		result = append(result, entry)
+	}
+
+	return result
+}
+
+func (s *Sink) All() []Entry {
+	return s.Entries("", "")
+}
+
+func (s *Sink) Reset() {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	s.entries = nil
+}
+
+// Logged reports whether any entry contains substr in its message or fields
+func (s *Sink) Logged(substr string) bool {
+	for _, entry := range s.All() {
+		if entry.contains(substr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Sink) AssertLogged(t testing.TB, substr string) bool {
+	t.Helper()
+
+	if !s.Logged(substr) {
+		t.Errorf("expected log entry containing %q, got:\n%s", substr, s.dump())
+		return false
+	}
+
+	return true
+}
+
+func (s *Sink) AssertNotLogged(t testing.TB, substr string) bool {
+	t.Helper()
+
+	if s.Logged(substr) {
+		t.Errorf("unexpected log entry containing %q, got:\n%s", substr, s.dump())
+		return false
+	}
+
+	return true
+}
+
+func (s *Sink) dump() string {
+	var sb strings.Builder
+	for _, entry := range s.All() {
+		sb.WriteString(entry.String())
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
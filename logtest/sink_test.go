@@ -0,0 +1,102 @@
+package logtest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	appctx "github.com/hoangtk0100/app-context"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSink(t *testing.T) {
+	t.Parallel()
+
+	al, sink := New(t)
+
+	logger := al.GetLogger("service")
+	logger.Info("Service started")
+	logger.Error(errors.New("boom"), "Cannot connect")
+	al.GetLogger("other").Info("login password=hunter2")
+
+	require.Len(t, sink.All(), 3)
+	require.Len(t, sink.Entries("info", ""), 2)
+	require.Len(t, sink.Entries("", "service"), 2)
+
+	entries := sink.Entries("error", "core.service")
+	require.Len(t, entries, 1)
+	require.Equal(t, "Cannot connect", entries[0].Message)
+	require.Equal(t, "boom", entries[0].Fields["error"])
+
+	sink.AssertLogged(t, "Service started")
+	sink.AssertLogged(t, "boom")
+	sink.AssertNotLogged(t, "hunter2")
+
+	sink.Reset()
+	require.Empty(t, sink.All())
+}
+
+func TestSinkIsolated(t *testing.T) {
+	t.Parallel()
+
+	al, sink := New(t)
+	other := NewSink()
+	uninstall := other.Install(al)
+
+	al.GetLogger("service").Info("first")
+	uninstall()
+	al.GetLogger("service").Info("second")
+
+	require.Len(t, sink.All(), 2)
+	require.Len(t, other.All(), 1)
+	other.AssertNotLogged(t, "second")
+}
+
+type testComponent struct {
+	value string
+}
+
+func (c *testComponent) ID() string {
+	return "test"
+}
+
+func (c *testComponent) InitFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.value, "test-value", "default", "Test value")
+}
+
+func (c *testComponent) Run(ac appctx.AppContext) error {
+	ac.Logger(c.ID()).Infof("Running with %s", c.value)
+	return nil
+}
+
+func (c *testComponent) Stop() error {
+	return nil
+}
+
+func TestWithAppLogger(t *testing.T) {
+	// Each context has its own flags, so several can be created concurrently
+	t.Run("group", func(t *testing.T) {
+		for i := 0; i < 8; i++ {
+			t.Run(fmt.Sprintf("context-%d", i), func(t *testing.T) {
+				t.Parallel()
+
+				al, sink := New(t)
+				ac := appctx.NewAppContext(
+					appctx.WithName("test"),
+					appctx.WithAppLogger(al),
+					appctx.WithComponent(&testComponent{}),
+				)
+
+				require.NoError(t, ac.Load())
+				require.NoError(t, ac.Stop())
+
+				require.Len(t, sink.Entries("info", "test"), 3)
+				sink.AssertLogged(t, "Running with default")
+				sink.AssertLogged(t, "Service context stopped")
+			})
+		}
+	})
+
+	require.Nil(t, pflag.Lookup("test-value"))
+}
@@ -22,3 +22,14 @@ func WithComponent(c Component) Option {
 		}
 	}
 }
+
+// WithAppLogger replaces the global logger of the context, ex: an isolated logger in tests
+func WithAppLogger(l AppLogger) Option {
+	return func(ac *appContext) {
+		ac.appLogger = l
+
+		if c, ok := l.(Component); ok {
+			ac.components[0] = c
+		}
+	}
+}
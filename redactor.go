@@ -0,0 +1,251 @@
+package appctx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+var (
+	defaultRedactKeys = []string{"authorization", "card", "password", "secret", "token"}
+
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// Only the grouped formats (ex: 4111 1111 1111 1111, 3782-822463-10005), so IDs and timestamps
+	// passing the Luhn check are kept. Unseparated card numbers are redacted by their key (default: card)
+	cardNumberPattern = regexp.MustCompile(`\b(?:\d{4}[ \-]\d{4}[ \-]\d{4}[ \-]\d{4}(?:[ \-]\d{3})?|\d{4}[ \-]\d{6}[ \-]\d{4,5})\b`)
+)
+
+// redactor masks sensitive keys and patterns in log entries
+type redactor struct {
+	keys      []string
+	patterns  []*regexp.Regexp
+	kvPattern *regexp.Regexp
+}
+
+func newRedactor(keys []string, patterns []string) (*redactor, error) {
+	r := &redactor{}
+
+	for _, key := range keys {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key != "" {
+			r.keys = append(r.keys, key)
+		}
+	}
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+
+		r.patterns = append(r.patterns, re)
+	}
+
+	if len(r.keys) > 0 {
+		quoted := make([]string, len(r.keys))
+		for i := range r.keys {
+			quoted[i] = regexp.QuoteMeta(r.keys[i])
+		}
+
+		// Ex: password=abc, "token": "abc", Authorization: Bearer abc
+		r.kvPattern = regexp.MustCompile(fmt.Sprintf(
+			`(?i)([\w\-]*(?:%s)[\w\-]*)(["']?\s*[:=]\s*["']?(?:(?:bearer|basic)\s+)?)([^\s"',;&]+)`,
+			strings.Join(quoted, "|"),
+		))
+	}
+
+	return r, nil
+}
+
+func (r *redactor) isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range r.keys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *redactor) redactString(s string) string {
+	if trimmed := strings.TrimSpace(s); len(trimmed) > 1 &&
+		(trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return string(r.redactJSON([]byte(trimmed)))
+	}
+
+	if r.kvPattern != nil {
+		s = r.kvPattern.ReplaceAllString(s, "${1}${2}"+redactedValue)
+	}
+
+	s = emailPattern.ReplaceAllString(s, redactedValue)
+	s = cardNumberPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if isLuhnValid(match) {
+			return redactedValue
+		}
+
+		return match
+	})
+
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, redactedValue)
+	}
+
+	return s
+}
+
+// redactJSON rewrites a JSON document, keeping the order of keys.
+// Input that is not valid JSON is redacted as a plain string.
+func (r *redactor) redactJSON(p []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	if err := r.copyValue(dec, &buf); err != nil {
+		return []byte(r.redactString(string(p)))
+	}
+
+	if bytes.HasSuffix(p, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+func (r *redactor) copyValue(dec *json.Decoder, buf *bytes.Buffer) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch v := tok.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			buf.WriteByte('{')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+
+				keyTok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+
+				key, _ := keyTok.(string)
+				writeJSONString(buf, key)
+				buf.WriteByte(':')
+
+				if r.isSensitiveKey(key) {
+					var raw json.RawMessage
+					if err := dec.Decode(&raw); err != nil {
+						return err
+					}
+
+					writeJSONString(buf, redactedValue)
+					continue
+				}
+
+				if err := r.copyValue(dec, buf); err != nil {
+					return err
+				}
+			}
+
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+
+			buf.WriteByte('}')
+
+		case '[':
+			buf.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+
+				if err := r.copyValue(dec, buf); err != nil {
+					return err
+				}
+			}
+
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+
+			buf.WriteByte(']')
+		}
+
+	case string:
+		writeJSONString(buf, r.redactString(v))
+
+	case json.Number:
+		buf.WriteString(v.String())
+
+	case bool:
+		if v {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+
+	case nil:
+		buf.WriteString("null")
+	}
+
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+
+	// Encode always appends a newline
+	buf.Truncate(buf.Len() - 1)
+}
+
+func isLuhnValid(number string) bool {
+	sum := 0
+	double := false
+
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+
+		sum += digit
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+// redactWriter redacts every entry before passing it to the underlying writer
+type redactWriter struct {
+	redactor *redactor
+	out      io.Writer
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	if _, err := w.out.Write(w.redactor.redactJSON(p)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
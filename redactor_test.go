@@ -0,0 +1,80 @@
+package appctx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor(t *testing.T) {
+	r, err := newRedactor(defaultRedactKeys, []string{`ssn-\d+`})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "SensitiveKey",
+			input:    `{"level":"info","password":"hunter2","message":"login"}`,
+			expected: `{"level":"info","password":"[REDACTED]","message":"login"}`,
+		},
+		{
+			name:     "SensitiveKeyCaseInsensitive",
+			input:    `{"Access_Token":{"value":"abc"},"count":1}`,
+			expected: `{"Access_Token":"[REDACTED]","count":1}`,
+		},
+		{
+			name:     "MessagePatterns",
+			input:    `{"message":"user a.b@example.com paid with 4111 1111 1111 1111, ref 1687430000000000000"}`,
+			expected: `{"message":"user [REDACTED] paid with [REDACTED], ref 1687430000000000000"}`,
+		},
+		{
+			name:     "LuhnValidIDs",
+			input:    `{"message":"order 1234567890123452 created at 1687430000000000003","card":"3782-822463-10005"}`,
+			expected: `{"message":"order 1234567890123452 created at 1687430000000000003","card":"[REDACTED]"}`,
+		},
+		{
+			name:     "CardNumberKey",
+			input:    `{"card_number":"4111111111111111","cardNumber":4111111111111111}`,
+			expected: `{"card_number":"[REDACTED]","cardNumber":"[REDACTED]"}`,
+		},
+		{
+			name:     "MessageCardNumberKey",
+			input:    `{"message":"paid with card=4111111111111111"}`,
+			expected: `{"message":"paid with card=[REDACTED]"}`,
+		},
+		{
+			name:     "MessageKeyValue",
+			input:    `{"message":"Authorization: Bearer xyz token=abc"}`,
+			expected: `{"message":"Authorization: Bearer [REDACTED] token=[REDACTED]"}`,
+		},
+		{
+			name:     "NestedJSONBody",
+			input:    `{"body":"{\"secret\":\"s3cr3t\",\"id\":1}"}`,
+			expected: `{"body":"{\"secret\":\"[REDACTED]\",\"id\":1}"}`,
+		},
+		{
+			name:     "CustomPattern",
+			input:    `{"message":"ssn-123456"}`,
+			expected: `{"message":"[REDACTED]"}`,
+		},
+		{
+			name:     "NotJSON",
+			input:    `secret: abc`,
+			expected: `secret: [REDACTED]`,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, string(r.redactJSON([]byte(tc.input))))
+		})
+	}
+
+	_, err = newRedactor(nil, []string{"("})
+	require.Error(t, err)
+}
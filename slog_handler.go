@@ -0,0 +1,145 @@
+package appctx
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+)
+
+// slogHandler exposes a Logger as a slog.Handler
+type slogHandler struct {
+	logger Logger
+	group  string
+	fields map[string]interface{}
+}
+
+func NewSlogHandler(logger Logger) slog.Handler {
+	return &slogHandler{
+		logger: logger,
+		fields: make(map[string]interface{}),
+	}
+}
+
+// NewSlogLogger returns a slog.Logger writing to the logger with given prefix
+func NewSlogLogger(ac AppContext, prefix string) *slog.Logger {
+	return slog.New(NewSlogHandler(ac.Logger(prefix)))
+}
+
+func slogToZerologLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return zerolog.TraceLevel
+	case level < slog.LevelInfo:
+		return zerolog.DebugLevel
+	case level < slog.LevelWarn:
+		return zerolog.InfoLevel
+	case level < slog.LevelError:
+		return zerolog.WarnLevel
+	}
+
+	return zerolog.ErrorLevel
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.Enabled(slogToZerologLevel(level).String())
+}
+
+func (h *slogHandler) Handle(_ context.Context, record slog.Record) error {
+	fields := make(map[string]interface{}, len(h.fields)+record.NumAttrs())
+	for key, value := range h.fields {
+		fields[key] = value
+	}
+
+	// The 1st error is the error of the entry at error level, a field below
+	level := slogToZerologLevel(record.Level)
+	var err error
+	record.Attrs(func(attr slog.Attr) bool {
+		if e, ok := attr.Value.Any().(error); ok && err == nil && level >= zerolog.ErrorLevel {
+			err = e
+			return true
+		}
+
+		addSlogAttr(fields, h.group, attr)
+		return true
+	})
+
+	logger := h.logger
+	if len(fields) > 0 {
+		logger = logger.WithFields(fields)
+	}
+
+	switch level {
+	case zerolog.TraceLevel:
+		logger.Trace(record.Message)
+	case zerolog.DebugLevel:
+		logger.Debug(record.Message)
+	case zerolog.InfoLevel:
+		logger.Info(record.Message)
+	case zerolog.WarnLevel:
+		logger.Warn(record.Message)
+	default:
+		logger.Error(err, record.Message)
+	}
+
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make(map[string]interface{}, len(h.fields)+len(attrs))
+	for key, value := range h.fields {
+		fields[key] = value
+	}
+
+	for _, attr := range attrs {
+		addSlogAttr(fields, h.group, attr)
+	}
+
+	return &slogHandler{
+		logger: h.logger,
+		group:  h.group,
+		fields: fields,
+	}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &slogHandler{
+		logger: h.logger,
+		group:  joinSlogKey(h.group, name),
+		fields: h.fields,
+	}
+}
+
+func addSlogAttr(fields map[string]interface{}, group string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		subGroup := group
+		if attr.Key != "" {
+			subGroup = joinSlogKey(group, attr.Key)
+		}
+
+		for _, a := range attr.Value.Group() {
+			addSlogAttr(fields, subGroup, a)
+		}
+
+		return
+	}
+
+	fields[joinSlogKey(group, attr.Key)] = attr.Value.Any()
+}
+
+func joinSlogKey(group, key string) string {
+	if group == "" {
+		return key
+	}
+
+	return group + "." + key
+}
@@ -0,0 +1,51 @@
+package appctx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogHandler(t *testing.T) {
+	buf := new(bytes.Buffer)
+	lg := slog.New(NewSlogHandler(NewAppLogger("core", buf).GetLogger("slog")))
+
+	lg.Debug("debug", "count", 1)
+	lg.Info("info", "err", errors.New("not found"))
+	lg.Warn("warn", slog.Group("req", "method", "GET"))
+	lg.With("service", "order").WithGroup("job").Error("error", "err", errors.New("boom"), "name", "sync")
+
+	entries := readEntries(t, buf)
+	require.Len(t, entries, 4)
+
+	require.Equal(t, "debug", entries[0]["level"])
+	require.Equal(t, "core.slog", entries[0]["prefix"])
+	require.Equal(t, float64(1), entries[0]["count"])
+
+	// Kept as a field below error level
+	require.Equal(t, "info", entries[1]["level"])
+	require.Equal(t, "not found", entries[1]["err"])
+
+	require.Equal(t, "warn", entries[2]["level"])
+	require.Equal(t, "GET", entries[2]["req.method"])
+
+	require.Equal(t, "error", entries[3]["level"])
+	require.Equal(t, "boom", entries[3]["error"])
+	require.Equal(t, "order", entries[3]["service"])
+	require.Equal(t, "sync", entries[3]["job.name"])
+	require.NotContains(t, entries[3], "job.err")
+}
+
+func TestSlogHandlerEnabled(t *testing.T) {
+	al := NewAppLogger("core", nil).(*appLogger)
+	al.logger = al.newLogger(nil, nil, parseLogLevel("warn"))
+	handler := NewSlogHandler(al.GetLogger("slog"))
+
+	require.False(t, handler.Enabled(context.Background(), slog.LevelInfo))
+	require.True(t, handler.Enabled(context.Background(), slog.LevelWarn))
+	require.True(t, handler.Enabled(context.Background(), slog.LevelError))
+}
@@ -2,6 +2,7 @@ package asyncjob
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -38,10 +39,34 @@ const (
 
 type JobHandler func(ctx context.Context) error
 
+// Observer is notified about retries and failures of jobs, ex: to collect metrics
+type Observer interface {
+	OnRetry(name string, retryIndex int)
+	OnFailed(name string, err error)
+}
+
+// observerValue keeps the same concrete type in the atomic value
+type observerValue struct {
+	Observer
+}
+
+var defaultObserver atomic.Value
+
+// SetObserver sets the observer of jobs created afterwards, safe for concurrent use
+func SetObserver(observer Observer) {
+	defaultObserver.Store(observerValue{observer})
+}
+
+func getObserver() Observer {
+	observer, _ := defaultObserver.Load().(observerValue)
+	return observer.Observer
+}
+
 type jobConfig struct {
 	name           string
 	maxTimeout     time.Duration
 	retryDurations []time.Duration
+	observer       Observer
 }
 
 type OptionHandler func(*jobConfig)
@@ -58,6 +83,12 @@ func WithRetryDurations(times []time.Duration) OptionHandler {
 	}
 }
 
+func WithObserver(observer Observer) OptionHandler {
+	return func(jc *jobConfig) {
+		jc.observer = observer
+	}
+}
+
 type job struct {
 	config     jobConfig
 	handler    JobHandler
@@ -72,6 +103,7 @@ func NewJob(handler JobHandler, options ...OptionHandler) *job {
 		config: jobConfig{
 			maxTimeout:     defaultMaxTimeout,
 			retryDurations: defaultRetryTimes,
+			observer:       getObserver(),
 		},
 		handler:    handler,
 		retryIndex: -1,
@@ -107,13 +139,28 @@ func (j *job) Execute(ctx context.Context) error {
 }
 
 func (j *job) Retry(ctx context.Context) error {
+	// No retry left, ex: retries disabled by empty retry durations
 	if j.retryIndex == len(j.config.retryDurations)-1 {
+		if j.state == StateFailed {
+			j.state = StateRetryFailed
+
+			if j.config.observer != nil {
+				j.config.observer.OnFailed(j.config.name, j.lastErr)
+			}
+
+			return j.lastErr
+		}
+
 		return nil
 	}
 
 	j.retryIndex++
 	time.Sleep(j.config.retryDurations[j.retryIndex])
 
+	if j.config.observer != nil {
+		j.config.observer.OnRetry(j.config.name, j.retryIndex)
+	}
+
 	err := j.Execute(ctx)
 	if err == nil {
 		j.state = StateCompleted
@@ -123,6 +170,11 @@ func (j *job) Retry(ctx context.Context) error {
 	if j.retryIndex == len(j.config.retryDurations)-1 {
 		j.state = StateRetryFailed
 		j.lastErr = err
+
+		if j.config.observer != nil {
+			j.config.observer.OnFailed(j.config.name, err)
+		}
+
 		return err
 	}
 
@@ -0,0 +1,62 @@
+package asyncjob
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testObserver struct {
+	retries  atomic.Int32
+	failures atomic.Int32
+}
+
+func (o *testObserver) OnRetry(name string, retryIndex int) {
+	o.retries.Add(1)
+}
+
+func (o *testObserver) OnFailed(name string, err error) {
+	o.failures.Add(1)
+}
+
+func TestSetObserver(t *testing.T) {
+	t.Cleanup(func() {
+		SetObserver(nil)
+	})
+
+	// Safe to set while jobs are created
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetObserver(new(testObserver))
+		}()
+		go func() {
+			defer wg.Done()
+			NewJob(func(ctx context.Context) error { return nil })
+		}()
+	}
+	wg.Wait()
+
+	observer := new(testObserver)
+	SetObserver(observer)
+
+	job := NewJob(func(ctx context.Context) error {
+		return errors.New("boom")
+	}, WithName("sync"), WithRetryDurations([]time.Duration{time.Millisecond}))
+
+	require.Error(t, job.Execute(context.Background()))
+	require.Error(t, job.Retry(context.Background()))
+	require.Equal(t, int32(1), observer.retries.Load())
+	require.Equal(t, int32(1), observer.failures.Load())
+
+	// Not observed without observer
+	SetObserver(nil)
+	require.Nil(t, NewJob(func(ctx context.Context) error { return nil }).config.observer)
+}